@@ -0,0 +1,172 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/ed25519"
+	"fmt"
+	"io"
+	"motors-backup/internal/config"
+	"motors-backup/internal/manifest"
+	"motors-backup/internal/metrics"
+	"motors-backup/internal/progress"
+	"motors-backup/motorsbackup"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync/atomic"
+)
+
+// writeBackup 将导出写入 dir 目录，成功后再生成 manifest.json
+func writeBackup(ctx context.Context, dir string, cfg *config.Config, opts *dumpOptions) (*manifest.Manifest, error) {
+	// 在导出之前加载签名私钥，避免导出完成后才发现密钥无效
+	var signKey ed25519.PrivateKey
+	if opts.signKey != "" {
+		var err error
+		signKey, err = manifest.LoadPrivateKey(opts.signKey)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %w", err)
+	}
+
+	var m *manifest.Manifest
+	var files []string
+	var err error
+	if opts.tableFiles() {
+		m, files, err = writeTableFiles(ctx, dir, cfg, opts)
+	} else {
+		m, files, err = writeDumpFile(ctx, dir, cfg, opts)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for _, name := range files {
+		if err := m.AddFile(dir, name); err != nil {
+			return nil, err
+		}
+	}
+	if signKey != nil {
+		m.KeyID = manifest.KeyID(signKey.Public().(ed25519.PublicKey))
+	}
+	if err := m.Write(dir); err != nil {
+		return nil, err
+	}
+	if signKey != nil {
+		if err := manifest.Sign(dir, signKey); err != nil {
+			return nil, err
+		}
+	}
+	return m, nil
+}
+
+// writeDumpFile 将导出写入 <数据库>.sql 或 <数据库>.jsonl，完成后才重命名临时文件
+func writeDumpFile(ctx context.Context, dir string, cfg *config.Config, opts *dumpOptions) (*manifest.Manifest, []string, error) {
+	name := cfg.DBName + ".sql"
+	if !opts.sqlFormat() {
+		name = cfg.DBName + "." + opts.format
+	}
+	path := filepath.Join(dir, name)
+	tmpPath := path + ".tmp"
+
+	file, err := os.Create(tmpPath)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to create backup file: %w", err)
+	}
+
+	writer := bufio.NewWriter(file)
+	m, err := runDump(ctx, writer, cfg, opts)
+	if err == nil {
+		err = writer.Flush()
+	}
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		os.Remove(tmpPath)
+		return nil, nil, err
+	}
+
+	if err := os.Rename(tmpPath, path); err != nil {
+		return nil, nil, fmt.Errorf("failed to rename backup file: %w", err)
+	}
+	return m, []string{name}, nil
+}
+
+// writeTableFiles 将每个表的数据写入 <表名>.<格式>，只重命名成功导出的表的临时文件
+func writeTableFiles(ctx context.Context, dir string, cfg *config.Config, opts *dumpOptions) (*manifest.Manifest, []string, error) {
+	var written atomic.Int64
+	var tmpFiles []string
+	create := func(table string) (io.WriteCloser, error) {
+		path := filepath.Join(dir, table+"."+opts.format+".tmp")
+		file, err := os.Create(path)
+		if err != nil {
+			return nil, err
+		}
+		tmpFiles = append(tmpFiles, path)
+		buffer := bufio.NewWriter(file)
+		return &tableFile{w: metrics.NewWriter(buffer, cfg.DBName), buffer: buffer, file: file, written: &written}, nil
+	}
+
+	dumperOpts := opts.dumperOptions()
+	if opts.progress != progress.ModeNone {
+		dumperOpts.Progress = &progressReporter{mode: opts.progress, interval: opts.progressInterval, bytes: written.Load}
+	}
+	result, err := motorsbackup.New(cfg, dumperOpts).DumpTables(ctx, create)
+
+	runErr := err
+	if runErr == nil && len(result.Failures) > 0 {
+		runErr = fmt.Errorf("%d object(s) failed", len(result.Failures))
+	}
+	metrics.ObserveRun(opts.jobName, cfg.DBName, result.StartedAt, written.Load(), runErr)
+
+	// 失败的表的文件不完整，与导出失败时的所有文件一起删除
+	var files []string
+	for _, path := range tmpFiles {
+		table := strings.TrimSuffix(filepath.Base(path), "."+opts.format+".tmp")
+		exported := slices.ContainsFunc(result.Tables, func(t motorsbackup.TableResult) bool { return t.Name == table })
+		if err != nil || !exported {
+			os.Remove(path)
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(path), ".tmp")
+		if renameErr := os.Rename(path, filepath.Join(dir, name)); renameErr != nil {
+			return nil, nil, fmt.Errorf("failed to rename backup file: %w", renameErr)
+		}
+		files = append(files, name)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	m := newManifest(result)
+	m.Format = opts.format
+	return m, files, nil
+}
+
+// tableFile 缓冲写入一个表的文件，写入的字节数计入 written 用于显示进度
+type tableFile struct {
+	w       io.Writer
+	buffer  *bufio.Writer
+	file    *os.File
+	written *atomic.Int64
+}
+
+func (f *tableFile) Write(p []byte) (int, error) {
+	n, err := f.w.Write(p)
+	f.written.Add(int64(n))
+	return n, err
+}
+
+func (f *tableFile) Close() error {
+	err := f.buffer.Flush()
+	if closeErr := f.file.Close(); err == nil {
+		err = closeErr
+	}
+	return err
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"math/rand/v2"
+	"motors-backup/internal/binlog"
+	"motors-backup/internal/config"
+	"motors-backup/internal/log"
+	"motors-backup/internal/manifest"
+	"motors-backup/internal/retry"
+	"os"
+	"time"
+)
+
+// runBinlogArchive 作为复制客户端持续归档 binlog，用于基于时间点的恢复
+func runBinlogArchive(args []string) error {
+	fs := flag.NewFlagSet("binlog-archive", flag.ExitOnError)
+	dir := fs.String("dir", "", "Directory of the archived binary log files (required)")
+	startFile := fs.String("start-file", "", "Binary log file to start from when the archive is empty")
+	startGTID := fs.String("start-gtid", "", "Start after this GTID set when the archive is empty, instead of --start-file")
+	startBackup := fs.String("start-backup", "", "Start at the binary log position recorded by a backup taken with --source-data")
+	serverID := fs.Uint("server-id", 0, "Replica server id, must be unique among the replicas of the server (default: random)")
+	heartbeat := fs.Duration("heartbeat", 30*time.Second, "Heartbeat interval requested from the server, the connection is considered lost after three missed heartbeats")
+	syncInterval := fs.Duration("sync-interval", time.Second, "Maximum time archived events stay unsynced to disk")
+	stopAtEnd := fs.Bool("stop-at-end", false, "Exit once the end of the binary log is reached instead of waiting for new events")
+	retries := fs.Int("retries", 10, "Reconnect attempts after a lost connection, the count starts over once events are received")
+	retryDelay := fs.Duration("retry-delay", time.Second, "Wait before the first reconnect, doubled on every further attempt")
+	retryMaxDelay := fs.Duration("retry-max-delay", time.Minute, "Maximum wait between reconnects")
+	configPath := fs.String("config", os.Getenv("MOTORS_BACKUP_CONFIG"), "YAML config file with named profiles")
+	profileName := fs.String("profile", os.Getenv("MOTORS_BACKUP_PROFILE"), "Profile of the config file to use")
+	fs.Usage = func() {
+		fmt.Println("Usage: motors-backup binlog-archive --dir=<dir> [options]")
+		fmt.Println()
+		fmt.Println("Streams the binary log as a replica and stores it as files identical to the ones on the")
+		fmt.Println("server. Completed files are compressed with gzip, the current one is kept as <file>.partial.")
+		fmt.Println("A restarted archive resumes after the last archived event, the start options are only")
+		fmt.Println("used for an empty archive. The user needs the REPLICATION SLAVE privilege.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Examples:")
+		fmt.Println("  motors-backup binlog-archive --dir=/backups/binlog --start-backup=/backups/2024-06-01")
+		fmt.Println("  motors-backup binlog-archive --dir=/backups/binlog --start-file=binlog.000042")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("binlog-archive takes no arguments")
+	}
+
+	if *dir == "" {
+		return fmt.Errorf("--dir is required")
+	}
+	if *retries < 0 {
+		return fmt.Errorf("--retries must not be negative")
+	}
+	if *serverID > 1<<32-1 {
+		return fmt.Errorf("--server-id must be less than 4294967296")
+	}
+
+	start := manifest.BinlogPosition{File: *startFile, GTIDSet: *startGTID}
+	if *startBackup != "" {
+		if *startFile != "" || *startGTID != "" {
+			return fmt.Errorf("--start-backup can not be combined with --start-file or --start-gtid")
+		}
+		m, err := manifest.Load(*startBackup)
+		if err != nil {
+			return err
+		}
+		if m.Binlog == nil {
+			return fmt.Errorf("backup %s has no binary log position, it was taken without --source-data", *startBackup)
+		}
+		// 归档总是从文件开头开始，备份记录的位置由恢复时的 Reader 跳过
+		start = manifest.BinlogPosition{File: m.Binlog.File, GTIDSet: m.Binlog.GTIDSet}
+	}
+
+	profile, err := loadProfile(*configPath, *profileName)
+	if err != nil {
+		return err
+	}
+	cfg, err := config.Load(profile, nil)
+	if err != nil {
+		return err
+	}
+
+	id := uint32(*serverID)
+	if id == 0 {
+		// 随机选择较大的 server id，避免与已有的副本冲突
+		id = 1<<30 + rand.Uint32N(1<<30)
+	}
+
+	ctx, stop := notifyInterrupt()
+	defer stop()
+
+	err = binlog.Run(ctx, cfg, binlog.ArchiveOptions{
+		Dir:          *dir,
+		Start:        start,
+		ServerID:     id,
+		Heartbeat:    *heartbeat,
+		SyncInterval: *syncInterval,
+		StopAtEnd:    *stopAtEnd,
+		Retry:        retry.Policy{Retries: *retries, Delay: *retryDelay, MaxDelay: *retryMaxDelay},
+	})
+	// 收到信号时已写入的事件都已同步到磁盘，属于正常退出
+	var interrupted *interruptedError
+	if err != nil && errors.As(context.Cause(ctx), &interrupted) {
+		log.Logger.Info("binary log archive stopped", "signal", interrupted.signal.String())
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"motors-backup/internal"
+	"motors-backup/internal/config"
+	"motors-backup/internal/plan"
+	"motors-backup/internal/schema"
+)
+
+// runDryRun 按与实际导出相同的规则解析表列表，只输出导出计划而不读取任何数据
+func runDryRun(ctx context.Context, w io.Writer, cfg *config.Config, opts *dumpOptions) error {
+	return internal.StartExport(ctx, cfg, opts.retry, func(database *sql.DB, info *internal.MySQLInfo) error {
+		allTables, err := schema.ListAllTables(ctx, database)
+		if err != nil {
+			return fmt.Errorf("error listing all tables: %w", err)
+		}
+
+		tableNames, err := resolveTables(allTables, cfg.DBName, opts)
+		if err != nil {
+			return err
+		}
+
+		// CSV、TSV 和 JSON Lines 只导出数据
+		withStructure := opts.sqlFormat() && !opts.noCreateInfo
+		plans, err := plan.Build(ctx, database, info.Server, cfg.DBName, tableNames, withStructure, func(table string) bool {
+			return !opts.noData && !opts.ignoreTableData.Contains(table)
+		}, opts.whereFor)
+		if err != nil {
+			return err
+		}
+
+		fmt.Fprintf(w, "Dry run for database `%s` on %s (server %s), no data was read\n\n", cfg.DBName, cfg.Address(), info.Version)
+		plan.Print(w, plans, opts.estimateRowsPerSec)
+
+		invalid := 0
+		for _, p := range plans {
+			if p.WhereError != nil {
+				invalid++
+			}
+		}
+		if invalid > 0 {
+			return fmt.Errorf("WHERE condition is invalid for %d table(s)", invalid)
+		}
+		return nil
+	})
+}
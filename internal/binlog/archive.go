@@ -0,0 +1,376 @@
+package binlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"motors-backup/internal/log"
+	"motors-backup/internal/manifest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+)
+
+// 归档目录中的文件后缀：正在写入的文件为 .partial，完成后压缩为 .gz
+const (
+	PartialSuffix    = ".partial"
+	CompressedSuffix = ".gz"
+)
+
+// Archive writes binary log files into a directory. Every archived file is a byte for byte copy of the
+// file on the server, so it can be read by mysqlbinlog after decompressing it. The file being written
+// is kept uncompressed with a .partial suffix and compressed with gzip once the server rotates to the
+// next file
+type Archive struct {
+	dir          string
+	syncInterval time.Duration
+
+	file     *os.File
+	name     string
+	position uint64
+	lastSync time.Time
+}
+
+// OpenArchive opens or creates an archive directory. Files left uncompressed by an earlier run are
+// compressed, and a torn event at the end of the current file is truncated
+func OpenArchive(dir string, syncInterval time.Duration) (*Archive, error) {
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("failed to create archive directory: %w", err)
+	}
+	a := &Archive{dir: dir, syncInterval: syncInterval}
+
+	partials, err := a.list(PartialSuffix)
+	if err != nil {
+		return nil, err
+	}
+	// 除最新的文件外，其余未压缩的文件都已完成
+	for i, name := range partials {
+		if i == len(partials)-1 {
+			break
+		}
+		if err := a.complete(name); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(partials) > 0 {
+		name := partials[len(partials)-1]
+		if _, err := os.Stat(a.path(name, CompressedSuffix)); err == nil {
+			// 压缩完成后退出前未来得及删除
+			if err := os.Remove(a.path(name, PartialSuffix)); err != nil {
+				return nil, err
+			}
+		} else {
+			return a, a.resume(name)
+		}
+	}
+
+	// 没有正在写入的文件时，从最后一个压缩文件末尾的 rotate 事件得到下一个文件
+	completed, err := a.list(CompressedSuffix)
+	if err != nil || len(completed) == 0 {
+		return a, err
+	}
+	last := completed[len(completed)-1]
+	next, err := a.nextFile(last)
+	if err != nil {
+		return nil, err
+	}
+	return a, a.create(next)
+}
+
+// Position returns where streaming resumes, ok is false for an empty archive
+func (a *Archive) Position() (manifest.BinlogPosition, bool) {
+	if a.file == nil {
+		return manifest.BinlogPosition{}, false
+	}
+	return manifest.BinlogPosition{File: a.name, Position: a.position}, true
+}
+
+// Handle writes an event received from the server. Rotate events start the next file, artificial
+// events such as heartbeats are not part of the binary log and are skipped
+func (a *Archive) Handle(event *Event) error {
+	if event.Header.Type == RotateEvent {
+		next, _, err := event.Rotate()
+		if err != nil {
+			return err
+		}
+		if event.IsArtificial() {
+			// 复制开始时服务器发送的 rotate 事件只说明当前文件名
+			if next == a.name {
+				return nil
+			}
+			return a.rotate(next)
+		}
+		if err := a.write(event); err != nil {
+			return err
+		}
+		return a.rotate(next)
+	}
+
+	if event.IsArtificial() {
+		return nil
+	}
+	if a.file == nil {
+		return fmt.Errorf("received %s event before the binary log file name", event.Header.Type)
+	}
+	return a.write(event)
+}
+
+// Close syncs and closes the current file, it is resumed by the next OpenArchive
+func (a *Archive) Close() error {
+	if a.file == nil {
+		return nil
+	}
+	err := a.file.Sync()
+	if closeErr := a.file.Close(); err == nil {
+		err = closeErr
+	}
+	a.file = nil
+	return err
+}
+
+func (a *Archive) write(event *Event) error {
+	if err := event.VerifyChecksum(); err != nil {
+		return err
+	}
+	if _, err := a.file.Write(event.Raw); err != nil {
+		return fmt.Errorf("failed to write %s: %w", a.name, err)
+	}
+	a.position = uint64(event.Header.LogPos)
+
+	if time.Since(a.lastSync) >= a.syncInterval {
+		if err := a.file.Sync(); err != nil {
+			return fmt.Errorf("failed to sync %s: %w", a.name, err)
+		}
+		a.lastSync = time.Now()
+	}
+	return nil
+}
+
+// rotate 先创建下一个文件再压缩当前文件，中途退出时下次启动仍能找到续传的位置
+func (a *Archive) rotate(next string) error {
+	previous := a.name
+	if err := a.Close(); err != nil {
+		return err
+	}
+	if err := a.create(next); err != nil {
+		return err
+	}
+	if previous == "" {
+		return nil
+	}
+	return a.complete(previous)
+}
+
+// create 创建只包含文件头的 .partial 文件
+func (a *Archive) create(name string) error {
+	file, err := os.OpenFile(a.path(name, PartialSuffix), os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return fmt.Errorf("failed to create %s: %w", name, err)
+	}
+	if _, err := file.Write(FileMagic); err != nil {
+		file.Close()
+		return fmt.Errorf("failed to write %s: %w", name, err)
+	}
+	a.file, a.name, a.position, a.lastSync = file, name, uint64(len(FileMagic)), time.Now()
+	log.Logger.Info("archiving binary log", "file", name)
+	return nil
+}
+
+// resume 打开已有的 .partial 文件继续写入，截掉末尾不完整的事件
+func (a *Archive) resume(name string) error {
+	path := a.path(name, PartialSuffix)
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	scan, err := scanEvents(file)
+	file.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	// 文件已以 rotate 事件结束，只是尚未压缩
+	if scan.next != "" {
+		if err := a.create(scan.next); err != nil {
+			return err
+		}
+		return a.complete(name)
+	}
+
+	if scan.length < scan.size {
+		log.Logger.Warn("truncating incomplete event at the end of the archived binary log",
+			"file", name, "size", scan.size, "length", scan.length)
+		if err := os.Truncate(path, scan.length); err != nil {
+			return err
+		}
+	}
+	a.file, err = os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o640)
+	if err != nil {
+		return err
+	}
+	a.name, a.position, a.lastSync = name, scan.position, time.Now()
+	log.Logger.Info("resuming binary log archive", "file", name, "position", a.position)
+	return nil
+}
+
+// complete 将完成的文件压缩为 .gz 并删除 .partial 文件
+func (a *Archive) complete(name string) error {
+	source := a.path(name, PartialSuffix)
+	target := a.path(name, CompressedSuffix)
+	in, err := os.Open(source)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	tmp := target + ".tmp"
+	out, err := os.OpenFile(tmp, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o640)
+	if err != nil {
+		return err
+	}
+	zw := gzip.NewWriter(out)
+	zw.Name = name
+	_, err = io.Copy(zw, in)
+	if closeErr := zw.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = out.Sync()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, target)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to compress %s: %w", name, err)
+	}
+
+	if err := os.Remove(source); err != nil {
+		return err
+	}
+	if info, err := os.Stat(target); err == nil {
+		log.Logger.Info("binary log archived", "file", name, "compressed_bytes", info.Size())
+	}
+	return nil
+}
+
+// nextFile 读取压缩文件末尾的 rotate 事件
+func (a *Archive) nextFile(name string) (string, error) {
+	file, err := os.Open(a.path(name, CompressedSuffix))
+	if err != nil {
+		return "", err
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	scan, err := scanEvents(zr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %w", name, err)
+	}
+	if scan.next == "" {
+		return "", fmt.Errorf("archived binary log %s does not end with a rotate event", name)
+	}
+	return scan.next, nil
+}
+
+func (a *Archive) path(name, suffix string) string {
+	return filepath.Join(a.dir, name+suffix)
+}
+
+// list 返回带有指定后缀的文件名，按 binlog 序号排序
+func (a *Archive) list(suffix string) ([]string, error) {
+	entries, err := os.ReadDir(a.dir)
+	if err != nil {
+		return nil, err
+	}
+	var names []string
+	for _, entry := range entries {
+		if name, ok := strings.CutSuffix(entry.Name(), suffix); ok && entry.Type().IsRegular() {
+			names = append(names, name)
+		}
+	}
+	SortFiles(names)
+	return names, nil
+}
+
+// SortFiles sorts binary log file names by their sequence number, a longer number sorts after a shorter one
+func SortFiles(names []string) {
+	slices.SortFunc(names, func(a, b string) int {
+		if len(a) != len(b) {
+			return len(a) - len(b)
+		}
+		return strings.Compare(a, b)
+	})
+}
+
+// scanResult 描述一个 binlog 文件中完整的事件
+type scanResult struct {
+	// size 为读取的字节数，length 为完整事件的结尾
+	size, length int64
+	position     uint64
+	// next 为文件以 rotate 事件结尾时下一个文件的文件名
+	next string
+}
+
+// scanEvents 依次读取事件头，找到最后一个完整事件的位置
+func scanEvents(r io.Reader) (*scanResult, error) {
+	magic := make([]byte, len(FileMagic))
+	if _, err := io.ReadFull(r, magic); err != nil || !bytes.Equal(magic, FileMagic) {
+		return nil, fmt.Errorf("not a binary log file")
+	}
+	result := &scanResult{size: int64(len(magic)), length: int64(len(magic)), position: uint64(len(magic))}
+
+	checksum := false
+	for {
+		header := make([]byte, HeaderSize)
+		n, err := io.ReadFull(r, header)
+		result.size += int64(n)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		h, err := ParseHeader(header)
+		if err != nil {
+			return result, nil
+		}
+
+		data := make([]byte, h.Size)
+		copy(data, header)
+		n, err = io.ReadFull(r, data[HeaderSize:])
+		result.size += int64(n)
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			return result, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		event, err := ParseEvent(data, checksum)
+		if err != nil {
+			return nil, err
+		}
+		if event.Header.Type == FormatDescriptionEvent {
+			checksum = event.Checksum
+		}
+		result.length += int64(h.Size)
+		result.position = uint64(h.LogPos)
+		result.next = ""
+		if h.Type == RotateEvent {
+			if result.next, _, err = event.Rotate(); err != nil {
+				return nil, err
+			}
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package binlog
+
+import (
+	"bytes"
+	"compress/gzip"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+// binlogFile 按服务器的方式构造一个 binlog 文件中的事件，LogPos 为事件结束的位置
+type binlogFile struct {
+	position uint32
+	data     []byte
+}
+
+func newBinlogFile() *binlogFile {
+	return &binlogFile{position: uint32(len(FileMagic)), data: slices.Clone(FileMagic)}
+}
+
+func (f *binlogFile) add(t *testing.T, eventType EventType, body []byte) *Event {
+	t.Helper()
+	f.position += uint32(HeaderSize + len(body) + checksumSize)
+	raw := newEvent(eventType, f.position, 0, body, true)
+	f.data = append(f.data, raw...)
+	event, err := ParseEvent(raw, true)
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	return event
+}
+
+func artificialRotate(t *testing.T, name string) *Event {
+	t.Helper()
+	event, err := ParseEvent(newEvent(RotateEvent, 0, artificialFlag, rotateBody(name, 4), true), true)
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	return event
+}
+
+func handle(t *testing.T, archive *Archive, events ...*Event) {
+	t.Helper()
+	for _, event := range events {
+		if err := archive.Handle(event); err != nil {
+			t.Fatalf("Handle(%s) returned error: %v", event.Header.Type, err)
+		}
+	}
+}
+
+func readCompressed(t *testing.T, path string) []byte {
+	t.Helper()
+	file, err := os.Open(path)
+	if err != nil {
+		t.Fatalf("failed to open %s: %v", path, err)
+	}
+	defer file.Close()
+	zr, err := gzip.NewReader(file)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	data, err := io.ReadAll(zr)
+	if err != nil {
+		t.Fatalf("failed to read %s: %v", path, err)
+	}
+	return data
+}
+
+func TestArchiveRotate(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := OpenArchive(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenArchive returned error: %v", err)
+	}
+	if _, ok := archive.Position(); ok {
+		t.Fatal("empty archive should have no position")
+	}
+
+	first := newBinlogFile()
+	handle(t, archive,
+		artificialRotate(t, "binlog.000001"),
+		first.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true)),
+		first.add(t, QueryEvent, []byte("BEGIN")),
+	)
+	// 心跳不写入文件
+	handle(t, archive, &Event{Header: Header{Type: HeartbeatEvent}})
+	handle(t, archive, first.add(t, RotateEvent, rotateBody("binlog.000002", 4)))
+
+	second := newBinlogFile()
+	handle(t, archive,
+		artificialRotate(t, "binlog.000002"),
+		second.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true)),
+		second.add(t, QueryEvent, []byte("COMMIT")),
+	)
+
+	position, ok := archive.Position()
+	if !ok || position.File != "binlog.000002" || position.Position != uint64(second.position) {
+		t.Errorf("Position() = %+v, want binlog.000002:%d", position, second.position)
+	}
+	if err := archive.Close(); err != nil {
+		t.Fatalf("Close returned error: %v", err)
+	}
+
+	if got := readCompressed(t, filepath.Join(dir, "binlog.000001"+CompressedSuffix)); !bytes.Equal(got, first.data) {
+		t.Error("compressed binlog.000001 differs from the server file")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "binlog.000001"+PartialSuffix)); !os.IsNotExist(err) {
+		t.Error("binlog.000001.partial should be removed after compression")
+	}
+	got, err := os.ReadFile(filepath.Join(dir, "binlog.000002"+PartialSuffix))
+	if err != nil || !bytes.Equal(got, second.data) {
+		t.Errorf("binlog.000002.partial differs from the server file: %v", err)
+	}
+}
+
+func TestArchiveResume(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := OpenArchive(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenArchive returned error: %v", err)
+	}
+	file := newBinlogFile()
+	handle(t, archive,
+		artificialRotate(t, "binlog.000007"),
+		file.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true)),
+		file.add(t, QueryEvent, []byte("BEGIN")),
+	)
+	complete := file.position
+	archive.Close()
+
+	// 模拟写入一半时退出
+	path := filepath.Join(dir, "binlog.000007"+PartialSuffix)
+	torn := file.add(t, QueryEvent, []byte("COMMIT"))
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.Write(torn.Raw[:HeaderSize+2])
+	f.Close()
+
+	archive, err = OpenArchive(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenArchive returned error: %v", err)
+	}
+	defer archive.Close()
+	position, ok := archive.Position()
+	if !ok || position.File != "binlog.000007" || position.Position != uint64(complete) {
+		t.Errorf("Position() = %+v, want binlog.000007:%d", position, complete)
+	}
+	info, err := os.Stat(path)
+	if err != nil || info.Size() != int64(complete) {
+		t.Errorf("partial file was not truncated to %d bytes: %v", complete, err)
+	}
+
+	// 续传时服务器从该位置重新发送事件
+	handle(t, archive, artificialRotate(t, "binlog.000007"), torn)
+	if got, _ := os.ReadFile(path); !bytes.Equal(got, file.data) {
+		t.Error("resumed file differs from the server file")
+	}
+}
+
+func TestOpenArchiveAfterRotate(t *testing.T) {
+	dir := t.TempDir()
+	archive, err := OpenArchive(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenArchive returned error: %v", err)
+	}
+	file := newBinlogFile()
+	handle(t, archive,
+		artificialRotate(t, "binlog.000009"),
+		file.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true)),
+		file.add(t, RotateEvent, rotateBody("binlog.000010", 4)),
+	)
+	archive.Close()
+	// 只留下压缩文件，下一个文件由末尾的 rotate 事件得出
+	os.Remove(filepath.Join(dir, "binlog.000010"+PartialSuffix))
+
+	archive, err = OpenArchive(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenArchive returned error: %v", err)
+	}
+	defer archive.Close()
+	position, ok := archive.Position()
+	if !ok || position.File != "binlog.000010" || position.Position != uint64(len(FileMagic)) {
+		t.Errorf("Position() = %+v, want binlog.000010:4", position)
+	}
+}
+
+func TestSortFiles(t *testing.T) {
+	names := []string{"binlog.1000000", "binlog.000010", "binlog.999999", "binlog.000002"}
+	SortFiles(names)
+	want := []string{"binlog.000002", "binlog.000010", "binlog.999999", "binlog.1000000"}
+	if !slices.Equal(names, want) {
+		t.Errorf("SortFiles() = %v, want %v", names, want)
+	}
+}
@@ -0,0 +1,172 @@
+package binlog
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+)
+
+// 支持的认证插件
+const (
+	nativePassword      = "mysql_native_password"
+	cachingSHA2Password = "caching_sha2_password"
+	sha256Password      = "sha256_password"
+)
+
+// 认证过程中的数据包
+const (
+	authMoreData        = 0x01
+	requestPublicKey    = 0x02
+	cachingSHA2FastOK   = 0x03
+	cachingSHA2FullAuth = 0x04
+	authSwitchRequest   = packetEOF
+	scrambleLength      = 20
+)
+
+// scrambleNativePassword computes SHA1(password) XOR SHA1(scramble + SHA1(SHA1(password)))
+func scrambleNativePassword(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+	stage1 := sha1.Sum([]byte(password))
+	stage2 := sha1.Sum(stage1[:])
+
+	h := sha1.New()
+	h.Write(scramble[:min(len(scramble), scrambleLength)])
+	h.Write(stage2[:])
+	result := h.Sum(nil)
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+	return result
+}
+
+// scrambleSHA256Password computes SHA256(password) XOR SHA256(SHA256(SHA256(password)) + scramble)
+func scrambleSHA256Password(scramble []byte, password string) []byte {
+	if password == "" {
+		return nil
+	}
+	stage1 := sha256.Sum256([]byte(password))
+	stage2 := sha256.Sum256(stage1[:])
+
+	h := sha256.New()
+	h.Write(stage2[:])
+	h.Write(scramble[:min(len(scramble), scrambleLength)])
+	result := h.Sum(nil)
+	for i := range result {
+		result[i] ^= stage1[i]
+	}
+	return result
+}
+
+// encryptPassword 用服务器的 RSA 公钥加密密码，用于未加密连接上的完整认证
+func encryptPassword(publicKey []byte, scramble []byte, password string) ([]byte, error) {
+	block, _ := pem.Decode(publicKey)
+	if block == nil {
+		return nil, fmt.Errorf("server sent an invalid public key")
+	}
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse server public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("server public key is not an RSA key")
+	}
+
+	plain := append([]byte(password), 0)
+	scramble = scramble[:min(len(scramble), scrambleLength)]
+	for i := range plain {
+		plain[i] ^= scramble[i%len(scramble)]
+	}
+	return rsa.EncryptOAEP(sha1.New(), rand.Reader, rsaKey, plain, nil)
+}
+
+// authResponse returns the first authentication data sent for plugin
+func (c *Client) authResponse(plugin string, scramble []byte, password string) ([]byte, error) {
+	switch plugin {
+	case nativePassword:
+		return scrambleNativePassword(scramble, password), nil
+	case cachingSHA2Password:
+		return scrambleSHA256Password(scramble, password), nil
+	case sha256Password:
+		if password == "" {
+			return []byte{0}, nil
+		}
+		if c.secure {
+			return append([]byte(password), 0), nil
+		}
+		return []byte{authMoreData}, nil
+	default:
+		return nil, fmt.Errorf("unsupported authentication plugin %s", plugin)
+	}
+}
+
+// authenticate 处理握手响应之后的认证交互，直到收到 OK 或错误
+func (c *Client) authenticate(plugin string, scramble []byte, password string) error {
+	for {
+		data, err := c.pc.readPacket()
+		if err != nil {
+			return fmt.Errorf("failed to read authentication result: %w", err)
+		}
+		if len(data) == 0 {
+			return fmt.Errorf("empty authentication packet")
+		}
+
+		switch data[0] {
+		case packetOK:
+			return nil
+		case packetERR:
+			return parseError(data)
+		case authSwitchRequest:
+			// 服务器要求改用其他认证插件
+			var rest []byte
+			plugin, rest = readNullString(data[1:])
+			scramble = bytes.TrimSuffix(rest, []byte{0})
+			response, err := c.authResponse(plugin, scramble, password)
+			if err != nil {
+				return err
+			}
+			if err := c.pc.writePacket(response); err != nil {
+				return err
+			}
+		case authMoreData:
+			if err := c.authMoreData(plugin, data[1:], scramble, password); err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("unexpected authentication packet 0x%02x", data[0])
+		}
+	}
+}
+
+// authMoreData 处理 caching_sha2_password 和 sha256_password 的后续交互
+func (c *Client) authMoreData(plugin string, data, scramble []byte, password string) error {
+	if plugin == cachingSHA2Password && len(data) == 1 {
+		switch data[0] {
+		case cachingSHA2FastOK:
+			// 服务器缓存中有该用户，随后会收到 OK
+			return nil
+		case cachingSHA2FullAuth:
+			if c.secure {
+				return c.pc.writePacket(append([]byte(password), 0))
+			}
+			return c.pc.writePacket([]byte{requestPublicKey})
+		}
+	}
+
+	// 其余情况为服务器发送的公钥
+	if plugin != cachingSHA2Password && plugin != sha256Password {
+		return fmt.Errorf("unexpected authentication data for %s", plugin)
+	}
+	encrypted, err := encryptPassword(data, scramble, password)
+	if err != nil {
+		return err
+	}
+	return c.pc.writePacket(encrypted)
+}
@@ -0,0 +1,388 @@
+package binlog
+
+import (
+	"context"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"motors-backup/internal/config"
+	"motors-backup/internal/db"
+	"motors-backup/internal/exporter"
+	"motors-backup/internal/flavor"
+	"motors-backup/internal/manifest"
+	"motors-backup/internal/tunnel"
+	"net"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 客户端能力标志
+const (
+	clientLongPassword         = 0x00000001
+	clientLongFlag             = 0x00000004
+	clientProtocol41           = 0x00000200
+	clientSSL                  = 0x00000800
+	clientTransactions         = 0x00002000
+	clientSecureConnection     = 0x00008000
+	clientPluginAuth           = 0x00080000
+	clientPluginAuthLenencData = 0x00200000
+)
+
+// 复制相关的命令和标志
+const (
+	comQuery                   byte = 0x03
+	comBinlogDump              byte = 0x12
+	comBinlogDumpGTID          byte = 0x1e
+	binlogDumpNonBlock              = 0x01
+	binlogThroughGTID               = 0x04
+	mariaDBSlaveCapabilityGTID      = 4
+)
+
+const (
+	defaultCharset          = 45 // utf8mb4_general_ci
+	defaultHandshakeTimeout = 30 * time.Second
+)
+
+// ErrEndOfStream is returned by ReadEvent when a non-blocking dump reached the end of the binary log
+var ErrEndOfStream = errors.New("end of binary log stream")
+
+// Client is a replication connection that streams binary log events. It implements the parts of the
+// client/server protocol needed for COM_BINLOG_DUMP, as database/sql can not send replication commands
+type Client struct {
+	pc       *packetConn
+	tunnel   *tunnel.Tunnel
+	server   flavor.Server
+	secure   bool
+	checksum bool
+	// checksumKnown 在收到第一个格式描述事件后为 true
+	checksumKnown bool
+	// heartbeat 为服务器发送心跳的间隔，读取超时为其三倍
+	heartbeat time.Duration
+}
+
+// Dial connects and authenticates with the connection settings of cfg, including TLS and the SSH tunnel
+func Dial(ctx context.Context, cfg *config.Config) (*Client, error) {
+	mysqlCfg, err := db.NewMySQLConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	c := new(Client)
+	c.tunnel, err = db.OpenTunnel(cfg)
+	if err != nil {
+		return nil, err
+	}
+	dial := (&net.Dialer{Timeout: cfg.ConnectTimeout}).DialContext
+	if c.tunnel != nil {
+		dial = c.tunnel.DialContext
+	}
+	conn, err := dial(ctx, mysqlCfg.Net, mysqlCfg.Addr)
+	if err != nil {
+		c.Close()
+		return nil, fmt.Errorf("failed to connect to %s: %w", cfg.Address(), err)
+	}
+	c.pc = newPacketConn(conn)
+	// Unix socket 上的明文密码不会经过网络
+	c.secure = mysqlCfg.Net == "unix"
+
+	timeout := cfg.ConnectTimeout
+	if timeout <= 0 {
+		timeout = defaultHandshakeTimeout
+	}
+	conn.SetDeadline(time.Now().Add(timeout))
+	if err := c.handshake(mysqlCfg); err != nil {
+		c.Close()
+		return nil, err
+	}
+	c.pc.conn.SetDeadline(time.Time{})
+	return c, nil
+}
+
+// Server returns the flavor and version announced by the server
+func (c *Client) Server() flavor.Server {
+	return c.server
+}
+
+// Close closes the connection and its SSH tunnel
+func (c *Client) Close() error {
+	var err error
+	if c.pc != nil {
+		err = c.pc.conn.Close()
+	}
+	if c.tunnel != nil {
+		c.tunnel.Close()
+	}
+	return err
+}
+
+// handshake 读取服务器的握手包，按需升级为 TLS 并完成认证
+func (c *Client) handshake(cfg *mysql.Config) error {
+	data, err := c.pc.readPacket()
+	if err != nil {
+		return fmt.Errorf("failed to read handshake: %w", err)
+	}
+	if len(data) > 0 && data[0] == packetERR {
+		return parseError(data)
+	}
+	h, err := parseHandshake(data)
+	if err != nil {
+		return err
+	}
+	c.server = flavor.Parse(h.serverVersion, "")
+	if h.capabilities&clientProtocol41 == 0 || h.capabilities&clientSecureConnection == 0 {
+		return fmt.Errorf("server %s does not support protocol 4.1", h.serverVersion)
+	}
+
+	capabilities := uint32(clientLongPassword | clientLongFlag | clientProtocol41 | clientTransactions | clientSecureConnection | clientPluginAuth)
+	capabilities |= h.capabilities & clientPluginAuthLenencData
+
+	if cfg.TLS != nil {
+		switch {
+		case h.capabilities&clientSSL != 0:
+			capabilities |= clientSSL
+			if err := c.pc.writePacket(handshakeHeader(capabilities)); err != nil {
+				return err
+			}
+			tlsConn := tls.Client(c.pc.conn, cfg.TLS)
+			if err := tlsConn.Handshake(); err != nil {
+				return fmt.Errorf("TLS handshake failed: %w", err)
+			}
+			c.pc.setConn(tlsConn)
+			c.secure = true
+		case !cfg.AllowFallbackToPlaintext:
+			return fmt.Errorf("server does not support TLS")
+		}
+	}
+
+	plugin := h.authPlugin
+	if plugin == "" {
+		plugin = nativePassword
+	}
+	response, err := c.authResponse(plugin, h.scramble, cfg.Passwd)
+	if err != nil {
+		return err
+	}
+
+	packet := handshakeHeader(capabilities)
+	packet = append(append(packet, cfg.User...), 0)
+	if capabilities&clientPluginAuthLenencData != 0 {
+		packet = appendLengthEncodedInt(packet, uint64(len(response)))
+	} else {
+		packet = append(packet, byte(len(response)))
+	}
+	packet = append(packet, response...)
+	packet = append(append(packet, plugin...), 0)
+	if err := c.pc.writePacket(packet); err != nil {
+		return err
+	}
+
+	if err := c.authenticate(plugin, h.scramble, cfg.Passwd); err != nil {
+		return fmt.Errorf("authentication failed: %w", err)
+	}
+	return nil
+}
+
+// handshakeHeader 返回握手响应和 SSL 请求共同的开头部分
+func handshakeHeader(capabilities uint32) []byte {
+	b := binary.LittleEndian.AppendUint32(nil, capabilities)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = append(b, defaultCharset)
+	return append(b, make([]byte, 23)...)
+}
+
+type handshake struct {
+	serverVersion string
+	capabilities  uint32
+	scramble      []byte
+	authPlugin    string
+}
+
+// parseHandshake 解析 HandshakeV10 数据包
+func parseHandshake(data []byte) (*handshake, error) {
+	if len(data) == 0 || data[0] != 10 {
+		return nil, fmt.Errorf("unsupported protocol version")
+	}
+	h := new(handshake)
+	var rest []byte
+	h.serverVersion, rest = readNullString(data[1:])
+	// 连接 ID、8 字节的 scramble 和填充字节
+	if len(rest) < 4+8+1+2 {
+		return nil, fmt.Errorf("handshake packet is too short")
+	}
+	h.scramble = append(h.scramble, rest[4:12]...)
+	h.capabilities = uint32(binary.LittleEndian.Uint16(rest[13:15]))
+	rest = rest[15:]
+
+	if len(rest) >= 1+2+2+1+10 {
+		h.capabilities |= uint32(binary.LittleEndian.Uint16(rest[3:5])) << 16
+		scrambleLen := int(rest[5])
+		rest = rest[16:]
+		if h.capabilities&clientSecureConnection != 0 {
+			n := max(13, scrambleLen-8)
+			if len(rest) < n {
+				return nil, fmt.Errorf("handshake packet is too short")
+			}
+			// 第二部分 scramble 以 0 结尾
+			part := rest[:n]
+			if part[len(part)-1] == 0 {
+				part = part[:len(part)-1]
+			}
+			h.scramble = append(h.scramble, part...)
+			rest = rest[n:]
+		}
+		if h.capabilities&clientPluginAuth != 0 {
+			h.authPlugin, _ = readNullString(rest)
+		}
+	}
+	return h, nil
+}
+
+// exec runs a statement that returns no result set
+func (c *Client) exec(query string) error {
+	if err := c.pc.writeCommand(append([]byte{comQuery}, query...)); err != nil {
+		return err
+	}
+	data, err := c.pc.readPacket()
+	if err != nil {
+		return err
+	}
+	switch {
+	case len(data) > 0 && data[0] == packetOK:
+		return nil
+	case len(data) > 0 && data[0] == packetERR:
+		return fmt.Errorf("%s: %w", query, parseError(data))
+	default:
+		return fmt.Errorf("%s: unexpected result set", query)
+	}
+}
+
+// DumpOptions describes where the binary log stream starts
+type DumpOptions struct {
+	// ServerID must be unique among the replicas of the server
+	ServerID uint32
+	// Start 为起始的文件和位置，GTIDSet 不为空时从其之后的第一个事务开始
+	Start manifest.BinlogPosition
+	// Heartbeat is the interval of the heartbeats sent by the server while no events are written
+	Heartbeat time.Duration
+	// NonBlocking ends the stream with ErrEndOfStream at the end of the binary log instead of waiting
+	NonBlocking bool
+}
+
+// StartDump asks the server to stream the binary log
+func (c *Client) StartDump(opts DumpOptions) error {
+	// 告诉服务器客户端能处理校验和，否则服务器会拒绝开启了 binlog_checksum 的复制流
+	if err := c.exec("SET @master_binlog_checksum = @@global.binlog_checksum, @source_binlog_checksum = @@global.binlog_checksum"); err != nil {
+		return err
+	}
+	if opts.Heartbeat > 0 {
+		period := opts.Heartbeat.Nanoseconds()
+		if err := c.exec(fmt.Sprintf("SET @master_heartbeat_period = %d, @source_heartbeat_period = %d", period, period)); err != nil {
+			return err
+		}
+		c.heartbeat = opts.Heartbeat
+	}
+
+	var flags uint16
+	if opts.NonBlocking {
+		flags |= binlogDumpNonBlock
+	}
+
+	if c.server.Flavor == flavor.MariaDB {
+		if err := c.exec(fmt.Sprintf("SET @mariadb_slave_capability = %d", mariaDBSlaveCapabilityGTID)); err != nil {
+			return err
+		}
+		if opts.Start.GTIDSet != "" {
+			// MariaDB 通过用户变量传递 GTID 位置，随后的 COM_BINLOG_DUMP 不需要文件名
+			for _, query := range []string{
+				"SET @slave_connect_state = " + exporter.EscapeSQLString(opts.Start.GTIDSet),
+				"SET @slave_gtid_strict_mode = 0",
+				"SET @slave_gtid_ignore_duplicates = 0",
+			} {
+				if err := c.exec(query); err != nil {
+					return err
+				}
+			}
+			return c.pc.writeCommand(binlogDumpCommand(flags, opts.ServerID, "", 4))
+		}
+	} else if opts.Start.GTIDSet != "" {
+		set, err := ParseGTIDSet(opts.Start.GTIDSet)
+		if err != nil {
+			return err
+		}
+		return c.pc.writeCommand(binlogDumpGTIDCommand(flags, opts.ServerID, set))
+	}
+
+	if opts.Start.File == "" {
+		return fmt.Errorf("binary log file or GTID set is required")
+	}
+	position := opts.Start.Position
+	if position < uint64(len(FileMagic)) {
+		position = uint64(len(FileMagic))
+	}
+	if position > 1<<32-1 {
+		return fmt.Errorf("binary log position %d is too large", position)
+	}
+	return c.pc.writeCommand(binlogDumpCommand(flags, opts.ServerID, opts.Start.File, uint32(position)))
+}
+
+func binlogDumpCommand(flags uint16, serverID uint32, file string, position uint32) []byte {
+	b := []byte{comBinlogDump}
+	b = binary.LittleEndian.AppendUint32(b, position)
+	b = binary.LittleEndian.AppendUint16(b, flags)
+	b = binary.LittleEndian.AppendUint32(b, serverID)
+	return append(b, file...)
+}
+
+// binlogDumpGTIDCommand 从 GTID 集合之后开始，服务器会从包含第一个缺少的事务的文件开头发送
+func binlogDumpGTIDCommand(flags uint16, serverID uint32, set GTIDSet) []byte {
+	data := set.Encode()
+	b := []byte{comBinlogDumpGTID}
+	b = binary.LittleEndian.AppendUint16(b, flags|binlogThroughGTID)
+	b = binary.LittleEndian.AppendUint32(b, serverID)
+	b = binary.LittleEndian.AppendUint32(b, 0)
+	b = binary.LittleEndian.AppendUint64(b, 4)
+	b = binary.LittleEndian.AppendUint32(b, uint32(len(data)))
+	return append(b, data...)
+}
+
+// ReadEvent returns the next event of the stream
+func (c *Client) ReadEvent() (*Event, error) {
+	if c.heartbeat > 0 {
+		c.pc.conn.SetReadDeadline(time.Now().Add(3 * c.heartbeat))
+	}
+	data, err := c.pc.readPacket()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read binary log event: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("empty binary log packet")
+	}
+
+	switch data[0] {
+	case packetOK:
+		event, err := ParseEvent(data[1:], c.checksum)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case event.Header.Type == FormatDescriptionEvent:
+			c.checksum, c.checksumKnown = event.Checksum, true
+		case !c.checksumKnown:
+			// 格式描述事件之前的 rotate 事件按服务器的 binlog_checksum 带有校验和，只能由其内容判断
+			event.Checksum = true
+			if event.VerifyChecksum() != nil {
+				event.Checksum = false
+			}
+		}
+		return event, nil
+	case packetERR:
+		return nil, parseError(data)
+	case packetEOF:
+		if len(data) < 9 {
+			return nil, ErrEndOfStream
+		}
+	}
+	return nil, fmt.Errorf("unexpected binary log packet 0x%02x", data[0])
+}
@@ -0,0 +1,275 @@
+package binlog
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"errors"
+	"motors-backup/internal/config"
+	"motors-backup/internal/manifest"
+	"motors-backup/internal/retry"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+var testScramble = []byte("abcdefgh0123456789AB")
+
+// fakeServer 实现复制连接所需的最少协议：握手、SET 语句和 COM_BINLOG_DUMP
+type fakeServer struct {
+	t        *testing.T
+	listener net.Listener
+	password string
+	// events 为 COM_BINLOG_DUMP 之后发送的事件
+	events  [][]byte
+	queries []string
+	dump    []byte
+}
+
+func newFakeServer(t *testing.T, password string, events [][]byte) *fakeServer {
+	t.Helper()
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %v", err)
+	}
+	s := &fakeServer{t: t, listener: listener, password: password, events: events}
+	t.Cleanup(func() { listener.Close() })
+	return s
+}
+
+func (s *fakeServer) config() *config.Config {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return &config.Config{
+		DBHost:     addr.IP.String(),
+		DBPort:     addr.Port,
+		DBUser:     "repl",
+		DBPassword: s.password,
+		SSLMode:    config.SSLDisabled,
+	}
+}
+
+// serve 处理一个连接，返回时连接已关闭
+func (s *fakeServer) serve() error {
+	conn, err := s.listener.Accept()
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	pc := newPacketConn(conn)
+
+	handshake := []byte{10}
+	handshake = append(append(handshake, "8.0.36"...), 0)
+	handshake = binary.LittleEndian.AppendUint32(handshake, 7)
+	handshake = append(append(handshake, testScramble[:8]...), 0)
+	capabilities := uint32(clientLongPassword | clientProtocol41 | clientSecureConnection | clientPluginAuth)
+	handshake = binary.LittleEndian.AppendUint16(handshake, uint16(capabilities))
+	handshake = append(handshake, defaultCharset, 2, 0)
+	handshake = binary.LittleEndian.AppendUint16(handshake, uint16(capabilities>>16))
+	handshake = append(handshake, byte(len(testScramble)+1))
+	handshake = append(handshake, make([]byte, 10)...)
+	handshake = append(append(handshake, testScramble[8:]...), 0)
+	handshake = append(append(handshake, nativePassword...), 0)
+	if err := pc.writePacket(handshake); err != nil {
+		return err
+	}
+
+	response, err := pc.readPacket()
+	if err != nil {
+		return err
+	}
+	user, rest := readNullString(response[32:])
+	want := scrambleNativePassword(testScramble, s.password)
+	if user != "repl" || len(rest) < 1+len(want) || !bytes.Equal(rest[1:1+len(want)], want) {
+		return pc.writePacket(append([]byte{packetERR, 0x15, 0x04}, "#28000Access denied"...))
+	}
+	if err := pc.writePacket([]byte{packetOK, 0, 0, 2, 0, 0, 0}); err != nil {
+		return err
+	}
+
+	for {
+		pc.seq = 0
+		command, err := pc.readPacket()
+		if err != nil {
+			return err
+		}
+		switch command[0] {
+		case comQuery:
+			s.queries = append(s.queries, string(command[1:]))
+			if err := pc.writePacket([]byte{packetOK, 0, 0, 2, 0, 0, 0}); err != nil {
+				return err
+			}
+		case comBinlogDump, comBinlogDumpGTID:
+			s.dump = command
+			for _, event := range s.events {
+				if err := pc.writePacket(append([]byte{packetOK}, event...)); err != nil {
+					return err
+				}
+			}
+			return pc.writePacket([]byte{packetEOF, 0, 0, 2, 0})
+		}
+	}
+}
+
+func TestRunStopAtEnd(t *testing.T) {
+	first := newBinlogFile()
+	fde := first.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true))
+	begin := first.add(t, QueryEvent, []byte("BEGIN"))
+	rotate := first.add(t, RotateEvent, rotateBody("binlog.000004", 4))
+	second := newBinlogFile()
+	secondFDE := second.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true))
+	commit := second.add(t, QueryEvent, []byte("COMMIT"))
+
+	events := [][]byte{
+		artificialRotate(t, "binlog.000003").Raw,
+		fde.Raw, begin.Raw, rotate.Raw,
+		artificialRotate(t, "binlog.000004").Raw,
+		secondFDE.Raw, commit.Raw,
+	}
+	server := newFakeServer(t, "secret", events)
+	done := make(chan error, 1)
+	go func() { done <- server.serve() }()
+
+	dir := t.TempDir()
+	err := Run(context.Background(), server.config(), ArchiveOptions{
+		Dir:       dir,
+		Start:     manifest.BinlogPosition{File: "binlog.000003", Position: 4},
+		ServerID:  4242,
+		Heartbeat: time.Minute,
+		StopAtEnd: true,
+	})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("fake server returned error: %v", err)
+	}
+
+	if len(server.queries) == 0 || !strings.Contains(server.queries[0], "@master_binlog_checksum") {
+		t.Errorf("checksum capability was not announced: %v", server.queries)
+	}
+	wantDump := binlogDumpCommand(binlogDumpNonBlock, 4242, "binlog.000003", 4)
+	if !bytes.Equal(server.dump, wantDump) {
+		t.Errorf("COM_BINLOG_DUMP = %x, want %x", server.dump, wantDump)
+	}
+
+	if got := readCompressed(t, filepath.Join(dir, "binlog.000003"+CompressedSuffix)); !bytes.Equal(got, first.data) {
+		t.Error("archived binlog.000003 differs from the server file")
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "binlog.000004"+PartialSuffix)); err != nil || !bytes.Equal(got, second.data) {
+		t.Errorf("archived binlog.000004 differs from the server file: %v", err)
+	}
+}
+
+// TestRunRejectsStartPosition 不支持从文件中间开始归档
+func TestRunRejectsStartPosition(t *testing.T) {
+	dir := t.TempDir()
+	err := Run(context.Background(), &config.Config{}, ArchiveOptions{
+		Dir:   dir,
+		Start: manifest.BinlogPosition{File: "binlog.000007", Position: 157},
+	})
+	if err == nil || !strings.Contains(err.Error(), "position 157 is not supported") {
+		t.Fatalf("Run error = %v, want position 157 is not supported", err)
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("Run wrote %d files to the archive, want none", len(entries))
+	}
+}
+
+// TestRunRefusedConnection 第一次连接被拒绝时不重试
+func TestRunRefusedConnection(t *testing.T) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	addr := listener.Addr().(*net.TCPAddr)
+	listener.Close()
+
+	cfg := &config.Config{DBHost: addr.IP.String(), DBPort: addr.Port, DBUser: "repl", SSLMode: config.SSLDisabled}
+	err = Run(context.Background(), cfg, ArchiveOptions{
+		Dir:   t.TempDir(),
+		Start: manifest.BinlogPosition{File: "binlog.000007"},
+		Retry: retry.Policy{Retries: 3, Delay: time.Hour},
+	})
+	if !errors.Is(err, syscall.ECONNREFUSED) {
+		t.Fatalf("Run error = %v, want connection refused", err)
+	}
+}
+
+// TestRunStartMidFile 归档整个起始文件，恢复时由 Reader 跳到备份记录的位置
+func TestRunStartMidFile(t *testing.T) {
+	file := newBinlogFile()
+	fde := file.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true))
+	begin := file.add(t, QueryEvent, []byte("BEGIN"))
+	commit := file.add(t, QueryEvent, []byte("COMMIT"))
+
+	events := [][]byte{artificialRotate(t, "binlog.000007").Raw, fde.Raw, begin.Raw, commit.Raw}
+	server := newFakeServer(t, "secret", events)
+	done := make(chan error, 1)
+	go func() { done <- server.serve() }()
+
+	dir := t.TempDir()
+	start := manifest.BinlogPosition{File: "binlog.000007", Position: uint64(begin.Header.LogPos)}
+	err := Run(context.Background(), server.config(), ArchiveOptions{
+		Dir:       dir,
+		Start:     manifest.BinlogPosition{File: start.File},
+		ServerID:  4242,
+		StopAtEnd: true,
+	})
+	if err != nil {
+		t.Fatalf("Run returned error: %v", err)
+	}
+	if err := <-done; err != nil {
+		t.Fatalf("fake server returned error: %v", err)
+	}
+
+	wantDump := binlogDumpCommand(binlogDumpNonBlock, 4242, "binlog.000007", 4)
+	if !bytes.Equal(server.dump, wantDump) {
+		t.Errorf("COM_BINLOG_DUMP = %x, want %x", server.dump, wantDump)
+	}
+	if got, err := os.ReadFile(filepath.Join(dir, "binlog.000007"+PartialSuffix)); err != nil || !bytes.Equal(got, file.data) {
+		t.Errorf("archived binlog.000007 differs from the server file: %v", err)
+	}
+
+	r, err := OpenReader(dir, start)
+	if err != nil {
+		t.Fatalf("OpenReader returned error: %v", err)
+	}
+	defer r.Close()
+	types, err := readAll(t, r)
+	if err != nil {
+		t.Fatalf("Next returned error: %v", err)
+	}
+	if len(types) != 2 || types[0] != FormatDescriptionEvent || types[1] != QueryEvent {
+		t.Errorf("events = %v, want the format description event and COMMIT", types)
+	}
+}
+
+func TestDialWrongPassword(t *testing.T) {
+	server := newFakeServer(t, "secret", nil)
+	go server.serve()
+
+	cfg := server.config()
+	cfg.DBPassword = "wrong"
+	if _, err := Dial(context.Background(), cfg); err == nil || !strings.Contains(err.Error(), "Access denied") {
+		t.Errorf("Dial returned %v, want access denied", err)
+	}
+}
+
+func TestBinlogDumpGTIDCommand(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	if err != nil {
+		t.Fatal(err)
+	}
+	command := binlogDumpGTIDCommand(0, 9, set)
+	if command[0] != comBinlogDumpGTID || binary.LittleEndian.Uint16(command[1:]) != binlogThroughGTID {
+		t.Errorf("unexpected command header %x", command[:3])
+	}
+	data := set.Encode()
+	if size := binary.LittleEndian.Uint32(command[19:]); int(size) != len(data) || !bytes.Equal(command[23:], data) {
+		t.Errorf("GTID set is not encoded at the end of the command")
+	}
+}
@@ -0,0 +1,304 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// Query 事件 post-header 的长度和 status vars 的类型
+const (
+	queryPostHeaderSize = 13
+
+	statusFlags2                     = 0
+	statusSQLMode                    = 1
+	statusCatalog                    = 2
+	statusAutoIncrement              = 3
+	statusCharset                    = 4
+	statusTimeZone                   = 5
+	statusCatalogNZ                  = 6
+	statusLCTimeNames                = 7
+	statusCharsetDatabase            = 8
+	statusTableMapForUpdate          = 9
+	statusMasterDataWritten          = 10
+	statusInvoker                    = 11
+	statusUpdatedDBNames             = 12
+	statusMicroseconds               = 13
+	statusExplicitDefaultsTimestamp  = 16
+	statusDDLLoggedWithXID           = 17
+	statusDefaultCollationForUTF8MB4 = 18
+	statusSQLRequirePrimaryKey       = 19
+	statusDefaultTableEncryption     = 20
+	statusMariaDBHRNow               = 128
+	statusMariaDBXID                 = 129
+	statusMariaDBGTIDFlags3          = 130
+
+	// overMaxDBs 表示语句更新的数据库过多，没有记录数据库名
+	overMaxDBs = 254
+)
+
+// Query 事件 flags2 中影响会话的选项
+const (
+	OptionAutoIsNull          = 0x00004000
+	OptionNotAutocommit       = 0x00080000
+	OptionNoForeignKeyChecks  = 0x04000000
+	OptionRelaxedUniqueChecks = 0x08000000
+)
+
+// 其余事件的字段长度和标志
+const (
+	tableIDSize             = 6
+	rowsPostHeaderSize      = tableIDSize + 2
+	tableMapPostHeaderSize  = tableIDSize + 2
+	rowsStatementEnd        = 0x0001
+	gtidEventMinSize        = 1 + 16 + 8
+	mariaDBGTIDEventMinSize = 8 + 4 + 1
+	mariaDBGTIDStandalone   = 0x01
+	intvarEventSize         = 1 + 8
+	intvarLastInsertID      = 1
+	intvarInsertID          = 2
+	randEventSize           = 8 + 8
+)
+
+// Query is a decoded Query event, the session settings are nil when the event does not carry them
+type Query struct {
+	ThreadID  uint32
+	ErrorCode uint16
+	// Database is the default database of the statement
+	Database string
+	SQL      string
+
+	Flags2        *uint32
+	SQLMode       *uint64
+	AutoIncrement *[2]uint16
+	// Charset holds character_set_client, collation_connection and collation_server
+	Charset  *[3]uint16
+	TimeZone *string
+	// Microseconds is added to the event timestamp, -1 when it is not recorded
+	Microseconds int
+}
+
+// Query decodes a Query event
+func (e *Event) Query() (*Query, error) {
+	body := e.Body()
+	if e.Header.Type != QueryEvent || len(body) < queryPostHeaderSize {
+		return nil, fmt.Errorf("not a query event")
+	}
+	q := &Query{
+		ThreadID:     binary.LittleEndian.Uint32(body),
+		ErrorCode:    binary.LittleEndian.Uint16(body[9:]),
+		Microseconds: -1,
+	}
+	databaseLen := int(body[8])
+	statusLen := int(binary.LittleEndian.Uint16(body[11:]))
+	rest := body[queryPostHeaderSize:]
+	if len(rest) < statusLen+databaseLen+1 {
+		return nil, fmt.Errorf("query event at position %d is truncated", e.Header.LogPos)
+	}
+	q.parseStatusVars(rest[:statusLen])
+	rest = rest[statusLen:]
+	q.Database = string(rest[:databaseLen])
+	q.SQL = string(rest[databaseLen+1:])
+	return q, nil
+}
+
+// parseStatusVars 读取能识别的 status vars，遇到未知类型时停止，其长度无法得知
+func (q *Query) parseStatusVars(data []byte) {
+	for len(data) > 0 {
+		code := data[0]
+		data = data[1:]
+		var n int
+		switch code {
+		case statusFlags2:
+			n = 4
+			if len(data) >= n {
+				flags := binary.LittleEndian.Uint32(data)
+				q.Flags2 = &flags
+			}
+		case statusSQLMode:
+			n = 8
+			if len(data) >= n {
+				mode := binary.LittleEndian.Uint64(data)
+				q.SQLMode = &mode
+			}
+		case statusAutoIncrement:
+			n = 4
+			if len(data) >= n {
+				q.AutoIncrement = &[2]uint16{binary.LittleEndian.Uint16(data), binary.LittleEndian.Uint16(data[2:])}
+			}
+		case statusCharset:
+			n = 6
+			if len(data) >= n {
+				q.Charset = &[3]uint16{binary.LittleEndian.Uint16(data), binary.LittleEndian.Uint16(data[2:]), binary.LittleEndian.Uint16(data[4:])}
+			}
+		case statusTimeZone:
+			if len(data) > 0 {
+				n = 1 + int(data[0])
+				if len(data) >= n {
+					zone := string(data[1:n])
+					q.TimeZone = &zone
+				}
+			}
+		case statusCatalog:
+			if len(data) > 0 {
+				n = 1 + int(data[0]) + 1
+			}
+		case statusCatalogNZ:
+			if len(data) > 0 {
+				n = 1 + int(data[0])
+			}
+		case statusInvoker:
+			if len(data) > 0 {
+				n = 1 + int(data[0])
+				if len(data) > n {
+					n += 1 + int(data[n])
+				}
+			}
+		case statusUpdatedDBNames:
+			if len(data) == 0 {
+				return
+			}
+			n = 1
+			if count := int(data[0]); count != overMaxDBs {
+				for i := 0; i < count && n < len(data); i++ {
+					end := bytes.IndexByte(data[n:], 0)
+					if end < 0 {
+						return
+					}
+					n += end + 1
+				}
+			}
+		case statusLCTimeNames, statusCharsetDatabase, statusDefaultCollationForUTF8MB4:
+			n = 2
+		case statusMicroseconds, statusMariaDBHRNow:
+			n = 3
+			if len(data) >= n {
+				q.Microseconds = int(data[0]) | int(data[1])<<8 | int(data[2])<<16
+			}
+		case statusMasterDataWritten:
+			n = 4
+		case statusTableMapForUpdate, statusDDLLoggedWithXID, statusMariaDBXID:
+			n = 8
+		case statusExplicitDefaultsTimestamp, statusSQLRequirePrimaryKey, statusDefaultTableEncryption, statusMariaDBGTIDFlags3:
+			n = 1
+		default:
+			return
+		}
+		if n == 0 || len(data) < n {
+			return
+		}
+		data = data[n:]
+	}
+}
+
+// GTID identifies a transaction, SID is set for MySQL and Domain and ServerID for MariaDB
+type GTID struct {
+	SID      [16]byte
+	GNO      uint64
+	Domain   uint32
+	ServerID uint32
+	MariaDB  bool
+	// Standalone is set for a MariaDB transaction without BEGIN, such as a DDL statement
+	Standalone bool
+}
+
+func (g GTID) String() string {
+	if g.MariaDB {
+		return fmt.Sprintf("%d-%d-%d", g.Domain, g.ServerID, g.GNO)
+	}
+	return fmt.Sprintf("%s:%d", FormatSID(g.SID), g.GNO)
+}
+
+// GTID decodes a MySQL or MariaDB GTID event
+func (e *Event) GTID() (GTID, error) {
+	body := e.Body()
+	switch {
+	case e.Header.Type == GTIDEvent && len(body) >= gtidEventMinSize:
+		var g GTID
+		copy(g.SID[:], body[1:17])
+		g.GNO = binary.LittleEndian.Uint64(body[17:])
+		return g, nil
+	case e.Header.Type == MariaDBGTIDEvent && len(body) >= mariaDBGTIDEventMinSize:
+		return GTID{
+			GNO:        binary.LittleEndian.Uint64(body),
+			Domain:     binary.LittleEndian.Uint32(body[8:]),
+			ServerID:   e.Header.ServerID,
+			MariaDB:    true,
+			Standalone: body[12]&mariaDBGTIDStandalone != 0,
+		}, nil
+	}
+	return GTID{}, fmt.Errorf("not a GTID event")
+}
+
+// TableMap returns the table id and the table a Table_map event describes
+func (e *Event) TableMap() (id uint64, database, table string, err error) {
+	body := e.Body()
+	if e.Header.Type != TableMapEvent || len(body) < tableMapPostHeaderSize+1 {
+		return 0, "", "", fmt.Errorf("not a table map event")
+	}
+	id = tableID(body)
+	rest := body[tableMapPostHeaderSize:]
+	n := int(rest[0])
+	if len(rest) < 1+n+2 {
+		return 0, "", "", fmt.Errorf("table map event at position %d is truncated", e.Header.LogPos)
+	}
+	database = string(rest[1 : 1+n])
+	rest = rest[1+n+1:]
+	n = int(rest[0])
+	if len(rest) < 1+n {
+		return 0, "", "", fmt.Errorf("table map event at position %d is truncated", e.Header.LogPos)
+	}
+	return id, database, string(rest[1 : 1+n]), nil
+}
+
+// IsRows reports whether the event holds row changes
+func (e *Event) IsRows() bool {
+	switch e.Header.Type {
+	case WriteRowsEventV1, UpdateRowsEventV1, DeleteRowsEventV1,
+		WriteRowsEventV2, UpdateRowsEventV2, DeleteRowsEventV2, PartialUpdateRowsEvent:
+		return true
+	}
+	return false
+}
+
+// Rows returns the table id of a rows event and whether it ends the statement
+func (e *Event) Rows() (id uint64, statementEnd bool, err error) {
+	body := e.Body()
+	if !e.IsRows() || len(body) < rowsPostHeaderSize {
+		return 0, false, fmt.Errorf("not a rows event")
+	}
+	flags := binary.LittleEndian.Uint16(body[tableIDSize:])
+	return tableID(body), flags&rowsStatementEnd != 0, nil
+}
+
+// Intvar returns the statement that sets the INSERT_ID or LAST_INSERT_ID of the next query
+func (e *Event) Intvar() (string, error) {
+	body := e.Body()
+	if e.Header.Type != IntvarEvent || len(body) < intvarEventSize {
+		return "", fmt.Errorf("not an intvar event")
+	}
+	value := binary.LittleEndian.Uint64(body[1:])
+	switch body[0] {
+	case intvarLastInsertID:
+		return fmt.Sprintf("SET LAST_INSERT_ID=%d", value), nil
+	case intvarInsertID:
+		return fmt.Sprintf("SET INSERT_ID=%d", value), nil
+	}
+	return "", fmt.Errorf("unknown intvar type %d", body[0])
+}
+
+// Rand returns the statement that seeds RAND() for the next query
+func (e *Event) Rand() (string, error) {
+	body := e.Body()
+	if e.Header.Type != RandEvent || len(body) < randEventSize {
+		return "", fmt.Errorf("not a rand event")
+	}
+	return fmt.Sprintf("SET @@RAND_SEED1=%d, @@RAND_SEED2=%d",
+		binary.LittleEndian.Uint64(body), binary.LittleEndian.Uint64(body[8:])), nil
+}
+
+func tableID(body []byte) uint64 {
+	var b [8]byte
+	copy(b[:], body[:tableIDSize])
+	return binary.LittleEndian.Uint64(b[:])
+}
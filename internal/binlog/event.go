@@ -0,0 +1,225 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"motors-backup/internal/flavor"
+	"strings"
+)
+
+// EventType is the type code of a binary log event
+type EventType uint8
+
+// 归档时需要识别的事件类型
+const (
+	QueryEvent             EventType = 2
+	RotateEvent            EventType = 4
+	FormatDescriptionEvent EventType = 15
+	XIDEvent               EventType = 16
+	HeartbeatEvent         EventType = 27
+	GTIDEvent              EventType = 33
+	PreviousGTIDsEvent     EventType = 35
+	HeartbeatEventV2       EventType = 41
+	MariaDBGTIDEvent       EventType = 162
+	MariaDBGTIDListEvent   EventType = 163
+)
+
+// 恢复时需要处理的事件类型
+const (
+	StopEvent               EventType = 3
+	IntvarEvent             EventType = 5
+	RandEvent               EventType = 13
+	UserVarEvent            EventType = 14
+	TableMapEvent           EventType = 19
+	WriteRowsEventV1        EventType = 23
+	UpdateRowsEventV1       EventType = 24
+	DeleteRowsEventV1       EventType = 25
+	RowsQueryEvent          EventType = 29
+	WriteRowsEventV2        EventType = 30
+	UpdateRowsEventV2       EventType = 31
+	DeleteRowsEventV2       EventType = 32
+	AnonymousGTIDEvent      EventType = 34
+	TransactionContextEvent EventType = 36
+	ViewChangeEvent         EventType = 37
+	PartialUpdateRowsEvent  EventType = 39
+	MariaDBAnnotateRows     EventType = 160
+	MariaDBBinlogCheckpoint EventType = 161
+)
+
+// HeaderSize is the size of the common event header
+const HeaderSize = 19
+
+// checksumSize 为 CRC32 校验和的长度
+const checksumSize = 4
+
+// 事件头中的标志
+const (
+	// artificialFlag 标记服务器为复制流生成、不在 binlog 文件中的事件
+	artificialFlag = 0x20
+	// ignorableFlag 标记不认识时可以跳过的事件
+	ignorableFlag = 0x80
+)
+
+// FileMagic starts every binary log file
+var FileMagic = []byte{0xfe, 'b', 'i', 'n'}
+
+// Header is the common header of every event
+type Header struct {
+	Timestamp uint32
+	Type      EventType
+	ServerID  uint32
+	// Size is the size of the whole event including the header and the checksum
+	Size uint32
+	// LogPos is the position of the next event in the binary log file, 0 for artificial events
+	LogPos uint32
+	Flags  uint16
+}
+
+// ParseHeader parses the common event header
+func ParseHeader(data []byte) (Header, error) {
+	if len(data) < HeaderSize {
+		return Header{}, fmt.Errorf("event is %d bytes, shorter than its header", len(data))
+	}
+	h := Header{
+		Timestamp: binary.LittleEndian.Uint32(data[0:]),
+		Type:      EventType(data[4]),
+		ServerID:  binary.LittleEndian.Uint32(data[5:]),
+		Size:      binary.LittleEndian.Uint32(data[9:]),
+		LogPos:    binary.LittleEndian.Uint32(data[13:]),
+		Flags:     binary.LittleEndian.Uint16(data[17:]),
+	}
+	if h.Size < HeaderSize {
+		return h, fmt.Errorf("invalid event size %d", h.Size)
+	}
+	return h, nil
+}
+
+// Event is a binary log event as it is stored in a binary log file
+type Event struct {
+	Header Header
+	// Raw holds the header, the body and the checksum
+	Raw []byte
+	// Checksum reports whether the event ends with a CRC32 checksum
+	Checksum bool
+}
+
+// ParseEvent parses an event, checksum tells whether the binary log the event belongs to uses checksums
+func ParseEvent(data []byte, checksum bool) (*Event, error) {
+	h, err := ParseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if int(h.Size) != len(data) {
+		return nil, fmt.Errorf("event size %d does not match the %d bytes received", h.Size, len(data))
+	}
+	// 格式描述事件自己声明是否带校验和
+	if h.Type == FormatDescriptionEvent {
+		checksum = formatDescriptionChecksum(data[HeaderSize:])
+	}
+	return &Event{Header: h, Raw: data, Checksum: checksum}, nil
+}
+
+// IsArtificial reports whether the event was generated for the replication stream and is not
+// part of the binary log file, such as the rotate event naming the first file and heartbeats
+func (e *Event) IsArtificial() bool {
+	return e.Header.LogPos == 0 || e.Header.Flags&artificialFlag != 0 ||
+		e.Header.Type == HeartbeatEvent || e.Header.Type == HeartbeatEventV2
+}
+
+// Ignorable reports whether the server marked the event as safe to skip by readers that do not know it
+func (e *Event) Ignorable() bool {
+	return e.Header.Flags&ignorableFlag != 0
+}
+
+// Body returns the event without the header and the checksum
+func (e *Event) Body() []byte {
+	body := e.Raw[HeaderSize:]
+	if e.Checksum && len(body) >= checksumSize {
+		body = body[:len(body)-checksumSize]
+	}
+	return body
+}
+
+// VerifyChecksum checks the CRC32 checksum of the event, events without a checksum always pass
+func (e *Event) VerifyChecksum() error {
+	if !e.Checksum {
+		return nil
+	}
+	if len(e.Raw) < HeaderSize+checksumSize {
+		return fmt.Errorf("event is too short for a checksum")
+	}
+	n := len(e.Raw) - checksumSize
+	if crc32.ChecksumIEEE(e.Raw[:n]) != binary.LittleEndian.Uint32(e.Raw[n:]) {
+		return fmt.Errorf("checksum mismatch in event at position %d", e.Header.LogPos)
+	}
+	return nil
+}
+
+// Rotate returns the file name and position a rotate event points to
+func (e *Event) Rotate() (string, uint64, error) {
+	body := e.Body()
+	if e.Header.Type != RotateEvent || len(body) < 8 {
+		return "", 0, fmt.Errorf("not a rotate event")
+	}
+	name := string(body[8:])
+	// 文件名来自服务器，只允许不含路径的文件名
+	if name == "" || strings.ContainsAny(name, `/\`) || name == "." || name == ".." {
+		return "", 0, fmt.Errorf("invalid binary log file name %q", name)
+	}
+	return name, binary.LittleEndian.Uint64(body), nil
+}
+
+// formatDescriptionChecksum 读取格式描述事件中的校验算法，MySQL 5.6.1 之前没有校验和
+func formatDescriptionChecksum(body []byte) bool {
+	const serverVersionEnd = 2 + 50
+	if len(body) < serverVersionEnd+5 {
+		return false
+	}
+	version, _ := readNullString(body[2:serverVersionEnd])
+	server := flavor.Parse(version, "")
+	if !server.AtLeast(5, 6) || server.Major == 5 && server.Minor == 6 && server.Patch < 1 {
+		return false
+	}
+	// 最后 5 个字节为校验算法和校验和，算法 1 为 CRC32
+	return body[len(body)-5] == 1
+}
+
+func (t EventType) String() string {
+	switch t {
+	case QueryEvent:
+		return "Query"
+	case RotateEvent:
+		return "Rotate"
+	case FormatDescriptionEvent:
+		return "Format_desc"
+	case XIDEvent:
+		return "Xid"
+	case HeartbeatEvent, HeartbeatEventV2:
+		return "Heartbeat"
+	case GTIDEvent, MariaDBGTIDEvent:
+		return "Gtid"
+	case PreviousGTIDsEvent:
+		return "Previous_gtids"
+	case MariaDBGTIDListEvent:
+		return "Gtid_list"
+	case IntvarEvent:
+		return "Intvar"
+	case RandEvent:
+		return "RAND"
+	case UserVarEvent:
+		return "User var"
+	case TableMapEvent:
+		return "Table_map"
+	case WriteRowsEventV1, WriteRowsEventV2:
+		return "Write_rows"
+	case UpdateRowsEventV1, UpdateRowsEventV2, PartialUpdateRowsEvent:
+		return "Update_rows"
+	case DeleteRowsEventV1, DeleteRowsEventV2:
+		return "Delete_rows"
+	case AnonymousGTIDEvent:
+		return "Anonymous_Gtid"
+	default:
+		return fmt.Sprintf("event type %d", uint8(t))
+	}
+}
@@ -0,0 +1,105 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"hash/crc32"
+	"testing"
+)
+
+// newEvent 构造一个事件，checksum 为 true 时追加 CRC32 校验和
+func newEvent(eventType EventType, logPos uint32, flags uint16, body []byte, checksum bool) []byte {
+	size := HeaderSize + len(body)
+	if checksum {
+		size += checksumSize
+	}
+	raw := binary.LittleEndian.AppendUint32(nil, 1760000000)
+	raw = append(raw, byte(eventType))
+	raw = binary.LittleEndian.AppendUint32(raw, 1)
+	raw = binary.LittleEndian.AppendUint32(raw, uint32(size))
+	raw = binary.LittleEndian.AppendUint32(raw, logPos)
+	raw = binary.LittleEndian.AppendUint16(raw, flags)
+	raw = append(raw, body...)
+	if checksum {
+		raw = binary.LittleEndian.AppendUint32(raw, crc32.ChecksumIEEE(raw))
+	}
+	return raw
+}
+
+// formatDescriptionBody 返回格式描述事件的内容，checksum 决定声明的校验算法
+func formatDescriptionBody(serverVersion string, checksum bool) []byte {
+	body := binary.LittleEndian.AppendUint16(nil, 4)
+	version := make([]byte, 50)
+	copy(version, serverVersion)
+	body = append(body, version...)
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = append(body, HeaderSize)
+	body = append(body, make([]byte, 40)...)
+	if checksum {
+		return append(body, 1)
+	}
+	// 关闭校验时仍然保留算法和校验和字段
+	return append(body, 0, 0, 0, 0, 0)
+}
+
+func rotateBody(name string, position uint64) []byte {
+	return append(binary.LittleEndian.AppendUint64(nil, position), name...)
+}
+
+func TestParseEvent(t *testing.T) {
+	fde, err := ParseEvent(newEvent(FormatDescriptionEvent, 126, 0, formatDescriptionBody("8.0.36", true), true), false)
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	if !fde.Checksum {
+		t.Error("format description event of 8.0.36 with CRC32 should enable checksums")
+	}
+	if err := fde.VerifyChecksum(); err != nil {
+		t.Errorf("VerifyChecksum returned error: %v", err)
+	}
+
+	noChecksum, err := ParseEvent(newEvent(FormatDescriptionEvent, 120, 0, formatDescriptionBody("10.6.12-MariaDB", false), false), true)
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	if noChecksum.Checksum {
+		t.Error("format description event with checksum algorithm OFF should disable checksums")
+	}
+
+	rotate, err := ParseEvent(newEvent(RotateEvent, 0, artificialFlag, rotateBody("binlog.000042", 4), true), true)
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	name, position, err := rotate.Rotate()
+	if err != nil || name != "binlog.000042" || position != 4 {
+		t.Errorf("Rotate() = %q, %d, %v, want binlog.000042, 4", name, position, err)
+	}
+	if !rotate.IsArtificial() {
+		t.Error("rotate event with log position 0 should be artificial")
+	}
+
+	corrupted := newEvent(QueryEvent, 300, 0, []byte("BEGIN"), true)
+	corrupted[HeaderSize] ^= 0xff
+	event, err := ParseEvent(corrupted, true)
+	if err != nil {
+		t.Fatalf("ParseEvent returned error: %v", err)
+	}
+	if err := event.VerifyChecksum(); err == nil {
+		t.Error("expected checksum error for a corrupted event")
+	}
+
+	if _, err := ParseEvent(newEvent(QueryEvent, 300, 0, []byte("BEGIN"), false)[:25], false); err == nil {
+		t.Error("expected error for a truncated event")
+	}
+}
+
+func TestRotateRejectsPaths(t *testing.T) {
+	for _, name := range []string{"../etc/passwd", "dir/binlog.000001", ""} {
+		event, err := ParseEvent(newEvent(RotateEvent, 500, 0, rotateBody(name, 4), false), false)
+		if err != nil {
+			t.Fatalf("ParseEvent returned error: %v", err)
+		}
+		if _, _, err := event.Rotate(); err == nil {
+			t.Errorf("Rotate() accepted file name %q", name)
+		}
+	}
+}
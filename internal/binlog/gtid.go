@@ -0,0 +1,167 @@
+package binlog
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Interval is a range of transaction numbers, End is exclusive like in the replication protocol
+type Interval struct {
+	Start, End uint64
+}
+
+// UUIDSet holds the transactions of one source server
+type UUIDSet struct {
+	SID       [16]byte
+	Intervals []Interval
+}
+
+// GTIDSet is a MySQL GTID set such as 3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11
+type GTIDSet []UUIDSet
+
+// ParseGTIDSet parses a MySQL GTID set, tagged GTIDs of MySQL 8.3 are not supported
+func ParseGTIDSet(s string) (GTIDSet, error) {
+	var set GTIDSet
+	for _, part := range strings.Split(strings.ReplaceAll(s, "\n", ""), ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		fields := strings.Split(part, ":")
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid GTID set %q: missing transaction numbers", part)
+		}
+
+		var uuidSet UUIDSet
+		raw, err := hex.DecodeString(strings.ReplaceAll(fields[0], "-", ""))
+		if err != nil || len(raw) != 16 {
+			return nil, fmt.Errorf("invalid GTID set %q: bad server uuid", part)
+		}
+		copy(uuidSet.SID[:], raw)
+
+		for _, field := range fields[1:] {
+			interval, err := parseInterval(field)
+			if err != nil {
+				return nil, fmt.Errorf("invalid GTID set %q: %w", part, err)
+			}
+			uuidSet.Intervals = append(uuidSet.Intervals, interval)
+		}
+		set = append(set, uuidSet)
+	}
+	if len(set) == 0 {
+		return nil, fmt.Errorf("GTID set is empty")
+	}
+	return set, nil
+}
+
+// parseInterval 解析 1-5 或 7 形式的事务号范围
+func parseInterval(s string) (Interval, error) {
+	first, last, isRange := strings.Cut(s, "-")
+	start, err := strconv.ParseUint(first, 10, 64)
+	if err != nil || start == 0 {
+		if err == nil && !isRange {
+			err = fmt.Errorf("transaction number must start at 1")
+		}
+		return Interval{}, fmt.Errorf("%q is not a transaction number or range (tagged GTIDs are not supported)", s)
+	}
+	end := start
+	if isRange {
+		end, err = strconv.ParseUint(last, 10, 64)
+		if err != nil || end < start {
+			return Interval{}, fmt.Errorf("invalid transaction range %q", s)
+		}
+	}
+	return Interval{Start: start, End: end + 1}, nil
+}
+
+// Encode returns the binary form sent with COM_BINLOG_DUMP_GTID
+func (s GTIDSet) Encode() []byte {
+	b := binary.LittleEndian.AppendUint64(nil, uint64(len(s)))
+	for _, uuidSet := range s {
+		b = append(b, uuidSet.SID[:]...)
+		b = binary.LittleEndian.AppendUint64(b, uint64(len(uuidSet.Intervals)))
+		for _, interval := range uuidSet.Intervals {
+			b = binary.LittleEndian.AppendUint64(b, interval.Start)
+			b = binary.LittleEndian.AppendUint64(b, interval.End)
+		}
+	}
+	return b
+}
+
+// Contains reports whether the transaction sid:gno is in the set
+func (s GTIDSet) Contains(sid [16]byte, gno uint64) bool {
+	for _, uuidSet := range s {
+		if uuidSet.SID != sid {
+			continue
+		}
+		for _, interval := range uuidSet.Intervals {
+			if gno >= interval.Start && gno < interval.End {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// FormatSID formats a server uuid like 3e11fa47-71ca-11e1-9e33-c80aa9429562
+func FormatSID(sid [16]byte) string {
+	h := hex.EncodeToString(sid[:])
+	return h[0:8] + "-" + h[8:12] + "-" + h[12:16] + "-" + h[16:20] + "-" + h[20:]
+}
+
+// ParseGTID parses a single transaction, a MySQL GTID such as 3e11fa47-71ca-11e1-9e33-c80aa9429562:23
+// or a MariaDB GTID such as 0-1-100
+func ParseGTID(s string) (GTID, error) {
+	s = strings.TrimSpace(s)
+	if _, gno, ok := strings.Cut(s, ":"); ok {
+		set, err := ParseGTIDSet(s)
+		if err != nil || strings.ContainsAny(gno, "-:,") {
+			return GTID{}, fmt.Errorf("invalid GTID %q, expected uuid:number", s)
+		}
+		return GTID{SID: set[0].SID, GNO: set[0].Intervals[0].Start}, nil
+	}
+
+	fields := strings.Split(s, "-")
+	if len(fields) != 3 {
+		return GTID{}, fmt.Errorf("invalid GTID %q, expected uuid:number or domain-server-sequence", s)
+	}
+	var values [3]uint64
+	for i, field := range fields {
+		v, err := strconv.ParseUint(field, 10, 32+32*(i/2))
+		if err != nil {
+			return GTID{}, fmt.Errorf("invalid GTID %q, expected domain-server-sequence", s)
+		}
+		values[i] = v
+	}
+	return GTID{Domain: uint32(values[0]), ServerID: uint32(values[1]), GNO: values[2], MariaDB: true}, nil
+}
+
+// Includes reports whether a GTID set recorded by a backup includes g. The set is a MySQL GTID set,
+// or for MariaDB the gtid_binlog_pos holding the last transaction of every domain
+func Includes(set string, g GTID) (bool, error) {
+	if strings.TrimSpace(set) == "" {
+		return false, nil
+	}
+	if !g.MariaDB {
+		parsed, err := ParseGTIDSet(set)
+		if err != nil {
+			return false, err
+		}
+		return parsed.Contains(g.SID, g.GNO), nil
+	}
+
+	for _, part := range strings.Split(set, ",") {
+		last, err := ParseGTID(part)
+		if err != nil || !last.MariaDB {
+			return false, fmt.Errorf("invalid MariaDB GTID position %q", set)
+		}
+		// 同一 domain 内的序号递增
+		if last.Domain == g.Domain && last.GNO >= g.GNO {
+			return true, nil
+		}
+	}
+	return false, nil
+}
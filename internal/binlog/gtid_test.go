@@ -0,0 +1,101 @@
+package binlog
+
+import (
+	"bytes"
+	"encoding/binary"
+	"testing"
+)
+
+func TestParseGTIDSet(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5:11,\n4d22fa47-71ca-11e1-9e33-c80aa9429562:7")
+	if err != nil {
+		t.Fatalf("ParseGTIDSet returned error: %v", err)
+	}
+	if len(set) != 2 {
+		t.Fatalf("got %d uuid sets, want 2", len(set))
+	}
+	if got := FormatSID(set[0].SID); got != "3e11fa47-71ca-11e1-9e33-c80aa9429562" {
+		t.Errorf("FormatSID() = %s", got)
+	}
+	want := []Interval{{Start: 1, End: 6}, {Start: 11, End: 12}}
+	if len(set[0].Intervals) != 2 || set[0].Intervals[0] != want[0] || set[0].Intervals[1] != want[1] {
+		t.Errorf("intervals = %+v, want %+v", set[0].Intervals, want)
+	}
+
+	if !set.Contains(set[0].SID, 5) || set.Contains(set[0].SID, 6) || !set.Contains(set[1].SID, 7) {
+		t.Error("Contains() returned wrong results")
+	}
+
+	for _, invalid := range []string{"", "3e11fa47-71ca-11e1-9e33-c80aa9429562", "not-a-uuid:1-5", "3e11fa47-71ca-11e1-9e33-c80aa9429562:5-1",
+		"3e11fa47-71ca-11e1-9e33-c80aa9429562:0", "3e11fa47-71ca-11e1-9e33-c80aa9429562:tag:1-5"} {
+		if _, err := ParseGTIDSet(invalid); err == nil {
+			t.Errorf("ParseGTIDSet(%q) returned no error", invalid)
+		}
+	}
+}
+
+func TestGTIDSetEncode(t *testing.T) {
+	set, err := ParseGTIDSet("3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5")
+	if err != nil {
+		t.Fatalf("ParseGTIDSet returned error: %v", err)
+	}
+
+	want := binary.LittleEndian.AppendUint64(nil, 1)
+	want = append(want, set[0].SID[:]...)
+	want = binary.LittleEndian.AppendUint64(want, 1)
+	want = binary.LittleEndian.AppendUint64(want, 1)
+	want = binary.LittleEndian.AppendUint64(want, 6)
+	if got := set.Encode(); !bytes.Equal(got, want) {
+		t.Errorf("Encode() = %x, want %x", got, want)
+	}
+}
+
+func TestParseGTID(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+		err   bool
+	}{
+		{input: "3E11FA47-71CA-11E1-9E33-C80AA9429562:23", want: "3e11fa47-71ca-11e1-9e33-c80aa9429562:23"},
+		{input: "0-1-100", want: "0-1-100"},
+		{input: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", err: true},
+		{input: "0-1", err: true},
+		{input: "", err: true},
+	}
+	for _, tt := range tests {
+		got, err := ParseGTID(tt.input)
+		if tt.err {
+			if err == nil {
+				t.Errorf("ParseGTID(%q) returned no error", tt.input)
+			}
+			continue
+		}
+		if err != nil || got.String() != tt.want {
+			t.Errorf("ParseGTID(%q) = %s, %v, want %s", tt.input, got, err, tt.want)
+		}
+	}
+}
+
+func TestIncludes(t *testing.T) {
+	tests := []struct {
+		set  string
+		gtid string
+		want bool
+	}{
+		{set: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", gtid: "3e11fa47-71ca-11e1-9e33-c80aa9429562:5", want: true},
+		{set: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5", gtid: "3e11fa47-71ca-11e1-9e33-c80aa9429562:6", want: false},
+		{set: "0-1-100,1-2-7", gtid: "0-1-90", want: true},
+		{set: "0-1-100,1-2-7", gtid: "1-2-8", want: false},
+		{set: "", gtid: "0-1-1", want: false},
+	}
+	for _, tt := range tests {
+		gtid, err := ParseGTID(tt.gtid)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, err := Includes(tt.set, gtid)
+		if err != nil || got != tt.want {
+			t.Errorf("Includes(%q, %s) = %v, %v, want %v", tt.set, tt.gtid, got, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,130 @@
+package binlog
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// maxPacketSize 为单个数据包的最大长度，更长的数据被拆分为多个数据包
+const maxPacketSize = 1<<24 - 1
+
+// 响应数据包的首字节
+const (
+	packetOK  = 0x00
+	packetEOF = 0xfe
+	packetERR = 0xff
+)
+
+// packetConn reads and writes packets of the MySQL client/server protocol
+type packetConn struct {
+	conn net.Conn
+	r    *bufio.Reader
+	seq  uint8
+}
+
+func newPacketConn(conn net.Conn) *packetConn {
+	return &packetConn{conn: conn, r: bufio.NewReaderSize(conn, 64*1024)}
+}
+
+// setConn 在 TLS 握手后替换底层连接
+func (c *packetConn) setConn(conn net.Conn) {
+	c.conn = conn
+	c.r = bufio.NewReaderSize(conn, 64*1024)
+}
+
+// readPacket reads a packet and joins the parts of packets longer than 16MB
+func (c *packetConn) readPacket() ([]byte, error) {
+	var data []byte
+	for {
+		var header [4]byte
+		if _, err := io.ReadFull(c.r, header[:]); err != nil {
+			return nil, err
+		}
+		length := int(header[0]) | int(header[1])<<8 | int(header[2])<<16
+		if header[3] != c.seq {
+			return nil, fmt.Errorf("packet out of order: got sequence %d, want %d", header[3], c.seq)
+		}
+		c.seq++
+
+		start := len(data)
+		data = append(data, make([]byte, length)...)
+		if _, err := io.ReadFull(c.r, data[start:]); err != nil {
+			if errors.Is(err, io.EOF) {
+				err = io.ErrUnexpectedEOF
+			}
+			return nil, err
+		}
+		if length < maxPacketSize {
+			return data, nil
+		}
+	}
+}
+
+// writePacket writes data as one packet, or as several when it is longer than 16MB
+func (c *packetConn) writePacket(data []byte) error {
+	for {
+		n := min(len(data), maxPacketSize)
+		packet := make([]byte, 4, 4+n)
+		packet[0], packet[1], packet[2], packet[3] = byte(n), byte(n>>8), byte(n>>16), c.seq
+		c.seq++
+		if _, err := c.conn.Write(append(packet, data[:n]...)); err != nil {
+			return err
+		}
+		data = data[n:]
+		if n < maxPacketSize {
+			return nil
+		}
+	}
+}
+
+// writeCommand 开始一个新命令，序号从 0 开始
+func (c *packetConn) writeCommand(data []byte) error {
+	c.seq = 0
+	return c.writePacket(data)
+}
+
+// parseError converts an ERR packet into the error type of the mysql driver, so that
+// retry.IsTransient classifies it like errors returned by database/sql
+func parseError(data []byte) error {
+	if len(data) < 3 || data[0] != packetERR {
+		return errors.New("malformed error packet")
+	}
+	err := &mysql.MySQLError{Number: binary.LittleEndian.Uint16(data[1:3])}
+	message := data[3:]
+	if len(message) >= 6 && message[0] == '#' {
+		copy(err.SQLState[:], message[1:6])
+		message = message[6:]
+	}
+	err.Message = string(message)
+	return err
+}
+
+// readNullString 读取以 0 结尾的字符串，没有结尾时读到末尾
+func readNullString(data []byte) (string, []byte) {
+	for i, b := range data {
+		if b == 0 {
+			return string(data[:i]), data[i+1:]
+		}
+	}
+	return string(data), nil
+}
+
+// appendLengthEncodedInt 按长度编码写入整数
+func appendLengthEncodedInt(b []byte, n uint64) []byte {
+	switch {
+	case n < 251:
+		return append(b, byte(n))
+	case n < 1<<16:
+		return append(b, 0xfc, byte(n), byte(n>>8))
+	case n < 1<<24:
+		return append(b, 0xfd, byte(n), byte(n>>8), byte(n>>16))
+	default:
+		return binary.LittleEndian.AppendUint64(append(b, 0xfe), n)
+	}
+}
@@ -0,0 +1,189 @@
+package binlog
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"motors-backup/internal/manifest"
+	"os"
+	"path/filepath"
+)
+
+// Reader reads the events of an archive in order, starting at a binary log position and following
+// rotate events into the next file. It checks that every event starts where the previous one ended,
+// so that a missing or damaged file is reported instead of silently skipped
+type Reader struct {
+	dir string
+
+	file     *os.File
+	r        *bufio.Reader
+	name     string
+	partial  bool
+	checksum bool
+	// position 为已读取事件的结尾，start 之前的事件被跳过
+	position uint64
+	start    uint64
+}
+
+// OpenReader opens the archived file of start and positions the reader at start.Position.
+// The format description event at the beginning of the file is still returned by Next
+func OpenReader(dir string, start manifest.BinlogPosition) (*Reader, error) {
+	if start.File == "" {
+		return nil, fmt.Errorf("binary log file is required")
+	}
+	r := &Reader{dir: dir}
+	if err := r.open(start.File); err != nil {
+		return nil, err
+	}
+	r.start = start.Position
+	return r, nil
+}
+
+// File returns the file the last event was read from
+func (r *Reader) File() string {
+	return r.name
+}
+
+// Position returns the end of the last event read
+func (r *Reader) Position() manifest.BinlogPosition {
+	return manifest.BinlogPosition{File: r.name, Position: r.position}
+}
+
+// Next returns the next event, io.EOF at the end of the archive. An incomplete event at the end
+// of the file being archived is treated as the end of the archive
+func (r *Reader) Next() (*Event, error) {
+	for {
+		event, err := r.read()
+		if err != nil {
+			return nil, err
+		}
+
+		begin := uint64(event.Header.LogPos) - uint64(event.Header.Size)
+		if event.Header.LogPos == 0 || begin != r.position {
+			return nil, fmt.Errorf("%s: event at position %d does not follow the previous event ending at %d",
+				r.name, begin, r.position)
+		}
+		r.position = uint64(event.Header.LogPos)
+		if event.Header.Type == FormatDescriptionEvent {
+			r.checksum = event.Checksum
+		}
+		if err := event.VerifyChecksum(); err != nil {
+			return nil, fmt.Errorf("%s: %w", r.name, err)
+		}
+
+		if event.Header.Type == RotateEvent {
+			if r.start > r.position {
+				return nil, fmt.Errorf("%s: position %d is beyond the end of the file", r.name, r.start)
+			}
+			next, _, err := event.Rotate()
+			if err != nil {
+				return nil, err
+			}
+			if err := r.open(next); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if begin < r.start && event.Header.Type != FormatDescriptionEvent {
+			if r.position > r.start {
+				return nil, fmt.Errorf("%s: position %d is not at the start of an event", r.name, r.start)
+			}
+			continue
+		}
+		return event, nil
+	}
+}
+
+// Close closes the current file
+func (r *Reader) Close() error {
+	if r.file == nil {
+		return nil
+	}
+	err := r.file.Close()
+	r.file = nil
+	return err
+}
+
+func (r *Reader) read() (*Event, error) {
+	header := make([]byte, HeaderSize)
+	n, err := io.ReadFull(r.r, header)
+	if n == 0 && errors.Is(err, io.EOF) {
+		return nil, r.end()
+	}
+	if err != nil {
+		return nil, r.truncated(err)
+	}
+	h, err := ParseHeader(header)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.name, err)
+	}
+	data := make([]byte, h.Size)
+	copy(data, header)
+	if _, err := io.ReadFull(r.r, data[HeaderSize:]); err != nil {
+		return nil, r.truncated(err)
+	}
+	event, err := ParseEvent(data, r.checksum)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", r.name, err)
+	}
+	return event, nil
+}
+
+// end 在文件结尾处调用，只有正在写入的文件可以没有 rotate 事件
+func (r *Reader) end() error {
+	if r.partial {
+		return io.EOF
+	}
+	return fmt.Errorf("archived binary log %s ends without a rotate event", r.name)
+}
+
+// truncated 处理读到一半的事件，正在写入的文件末尾可能有尚未写完的事件
+func (r *Reader) truncated(err error) error {
+	if r.partial && (errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF)) {
+		return io.EOF
+	}
+	return fmt.Errorf("failed to read %s: %w", r.name, err)
+}
+
+// open 打开归档中的文件，优先读取已压缩的文件
+func (r *Reader) open(name string) error {
+	if err := r.Close(); err != nil {
+		return err
+	}
+	path := filepath.Join(r.dir, name+CompressedSuffix)
+	file, err := os.Open(path)
+	partial := false
+	if errors.Is(err, os.ErrNotExist) {
+		path = filepath.Join(r.dir, name+PartialSuffix)
+		file, err = os.Open(path)
+		partial = true
+	}
+	if errors.Is(err, os.ErrNotExist) {
+		return fmt.Errorf("binary log %s is missing from the archive %s", name, r.dir)
+	}
+	if err != nil {
+		return err
+	}
+
+	var in io.Reader = file
+	if !partial {
+		zr, err := gzip.NewReader(file)
+		if err != nil {
+			file.Close()
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		in = zr
+	}
+	r.file, r.r, r.name, r.partial = file, bufio.NewReaderSize(in, 256*1024), name, partial
+	r.checksum, r.start = false, 0
+
+	magic := make([]byte, len(FileMagic))
+	if _, err := io.ReadFull(r.r, magic); err != nil || !bytes.Equal(magic, FileMagic) {
+		return fmt.Errorf("%s is not a binary log file", path)
+	}
+	r.position = uint64(len(FileMagic))
+	return nil
+}
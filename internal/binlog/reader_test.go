@@ -0,0 +1,111 @@
+package binlog
+
+import (
+	"errors"
+	"io"
+	"motors-backup/internal/manifest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// writeArchive 归档两个 binlog 文件，返回第一个文件中 BEGIN 事件结束的位置
+func writeArchive(t *testing.T, dir string) uint32 {
+	t.Helper()
+	archive, err := OpenArchive(dir, 0)
+	if err != nil {
+		t.Fatalf("OpenArchive returned error: %v", err)
+	}
+	defer archive.Close()
+
+	first := newBinlogFile()
+	handle(t, archive,
+		artificialRotate(t, "binlog.000001"),
+		first.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true)),
+		first.add(t, QueryEvent, []byte("BEGIN")),
+	)
+	begin := first.position
+	handle(t, archive,
+		first.add(t, QueryEvent, []byte("COMMIT")),
+		first.add(t, RotateEvent, rotateBody("binlog.000002", 4)),
+	)
+	second := newBinlogFile()
+	handle(t, archive,
+		artificialRotate(t, "binlog.000002"),
+		second.add(t, FormatDescriptionEvent, formatDescriptionBody("8.0.36", true)),
+		second.add(t, QueryEvent, []byte("DROP TABLE t")),
+	)
+	return begin
+}
+
+func readAll(t *testing.T, r *Reader) ([]EventType, error) {
+	t.Helper()
+	var types []EventType
+	for {
+		event, err := r.Next()
+		if errors.Is(err, io.EOF) {
+			return types, nil
+		}
+		if err != nil {
+			return types, err
+		}
+		types = append(types, event.Header.Type)
+	}
+}
+
+func TestReader(t *testing.T) {
+	dir := t.TempDir()
+	begin := writeArchive(t, dir)
+
+	r, err := OpenReader(dir, manifest.BinlogPosition{File: "binlog.000001", Position: uint64(begin)})
+	if err != nil {
+		t.Fatalf("OpenReader returned error: %v", err)
+	}
+	defer r.Close()
+	types, err := readAll(t, r)
+	if err != nil {
+		t.Fatalf("Next returned error: %v", err)
+	}
+	// 起始位置之前只返回格式描述事件，rotate 事件不返回
+	want := []EventType{FormatDescriptionEvent, QueryEvent, FormatDescriptionEvent, QueryEvent}
+	if len(types) != len(want) {
+		t.Fatalf("events = %v, want %v", types, want)
+	}
+	for i := range want {
+		if types[i] != want[i] {
+			t.Fatalf("events = %v, want %v", types, want)
+		}
+	}
+	if r.File() != "binlog.000002" {
+		t.Errorf("File() = %s, want binlog.000002", r.File())
+	}
+}
+
+func TestReaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	begin := writeArchive(t, dir)
+
+	r, err := OpenReader(dir, manifest.BinlogPosition{File: "binlog.000001", Position: uint64(begin) - 3})
+	if err != nil {
+		t.Fatalf("OpenReader returned error: %v", err)
+	}
+	if _, err := readAll(t, r); err == nil || !strings.Contains(err.Error(), "not at the start of an event") {
+		t.Errorf("expected error for a position inside an event, got %v", err)
+	}
+	r.Close()
+
+	if _, err := OpenReader(dir, manifest.BinlogPosition{File: "binlog.000009", Position: 4}); err == nil {
+		t.Error("expected error for a file missing from the archive")
+	}
+
+	os.Remove(filepath.Join(dir, "binlog.000002"+PartialSuffix))
+	r, err = OpenReader(dir, manifest.BinlogPosition{File: "binlog.000001", Position: 4})
+	if err != nil {
+		t.Fatalf("OpenReader returned error: %v", err)
+	}
+	defer r.Close()
+	if _, err := readAll(t, r); err == nil || !strings.Contains(err.Error(), "missing") {
+		t.Errorf("expected error for a gap in the archive, got %v", err)
+	}
+}
@@ -0,0 +1,114 @@
+package binlog
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"motors-backup/internal/config"
+	"motors-backup/internal/log"
+	"motors-backup/internal/manifest"
+	"motors-backup/internal/retry"
+	"time"
+)
+
+// ArchiveOptions describes a continuous binary log archive
+type ArchiveOptions struct {
+	Dir string
+	// Start is used when the archive is empty, otherwise streaming resumes after the last archived event.
+	// The start file is always archived from its beginning, so that it can be read from the first event.
+	// A Position other than 0 or the beginning of the file is rejected
+	Start        manifest.BinlogPosition
+	ServerID     uint32
+	Heartbeat    time.Duration
+	SyncInterval time.Duration
+	// StopAtEnd returns once the end of the binary log is reached instead of waiting for new events
+	StopAtEnd bool
+	Retry     retry.Policy
+}
+
+// Run streams the binary log of the server into the archive until ctx is canceled, the end is reached
+// with StopAtEnd or a fatal error occurs. After a transient error it reconnects and resumes after
+// the last archived event, the retry count starts over once events are received again
+func Run(ctx context.Context, cfg *config.Config, opts ArchiveOptions) error {
+	if p := opts.Start.Position; p != 0 && p != uint64(len(FileMagic)) {
+		return fmt.Errorf("the archive starts at the beginning of %s, position %d is not supported", opts.Start.File, p)
+	}
+	archive, err := OpenArchive(opts.Dir, opts.SyncInterval)
+	if err != nil {
+		return err
+	}
+	defer archive.Close()
+
+	connected := false
+	for attempt := 1; ; attempt++ {
+		received, err := stream(ctx, cfg, archive, opts)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil {
+			return context.Cause(ctx)
+		}
+		if received {
+			attempt = 1
+			connected = true
+		}
+		// 从未收到事件时，未知的主机名和被拒绝的连接说明连接参数错误，不再重试
+		if !connected {
+			err = retry.ConnectError(err)
+		}
+		if !opts.Retry.Wait(ctx, attempt, err, log.KeyStage, "binlog_archive", "file", archive.name) {
+			return err
+		}
+	}
+}
+
+// stream 连接服务器并写入事件，received 表示是否收到过数据
+func stream(ctx context.Context, cfg *config.Config, archive *Archive, opts ArchiveOptions) (received bool, err error) {
+	start, resumed := archive.Position()
+	if !resumed {
+		start = opts.Start
+		if start.File == "" && start.GTIDSet == "" {
+			return false, fmt.Errorf("the archive is empty, a start file or GTID set is required")
+		}
+		// 从文件中间开始时服务器发送的格式描述事件不在文件中，归档文件会缺少开头的事件，因此总是从文件开头请求
+		start.Position = uint64(len(FileMagic))
+	}
+
+	client, err := Dial(ctx, cfg)
+	if err != nil {
+		return false, err
+	}
+	defer client.Close()
+	// 取消时关闭连接，中断阻塞的读取
+	stop := context.AfterFunc(ctx, func() {
+		client.Close()
+	})
+	defer stop()
+
+	log.Logger.Info("streaming binary log", "server", client.Server().String(),
+		"file", start.File, "position", start.Position, "gtid_set", start.GTIDSet)
+	err = client.StartDump(DumpOptions{
+		ServerID:    opts.ServerID,
+		Start:       start,
+		Heartbeat:   opts.Heartbeat,
+		NonBlocking: opts.StopAtEnd,
+	})
+	if err != nil {
+		return false, fmt.Errorf("failed to start binary log dump: %w", err)
+	}
+
+	for {
+		event, err := client.ReadEvent()
+		if errors.Is(err, ErrEndOfStream) {
+			log.Logger.Info("reached the end of the binary log", "file", archive.name, "position", archive.position)
+			return received, nil
+		}
+		if err != nil {
+			return received, err
+		}
+		received = true
+		if err := archive.Handle(event); err != nil {
+			return received, err
+		}
+	}
+}
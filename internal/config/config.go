@@ -1,12 +1,13 @@
 package config
 
 import (
+	"fmt"
 	"github.com/joho/godotenv"
 	"motors-backup/internal/log"
 	"os"
 	"path/filepath"
 	"runtime"
-	"strconv"
+	"time"
 )
 
 // Config holds the configuration for database connection
@@ -16,6 +17,24 @@ type Config struct {
 	DBUser     string
 	DBPassword string
 	DBName     string
+	// DBSocket 为 Unix socket 路径，设置后忽略 DBHost 和 DBPort
+	DBSocket string
+
+	// SSLMode 与 mysql 客户端的 --ssl-mode 相同，为空时按是否指定 CA 选择 preferred 或 verify_ca
+	SSLMode string
+	SSLCA   string
+	SSLCert string
+	SSLKey  string
+
+	ConnectTimeout time.Duration
+	ReadTimeout    time.Duration
+	Compress       bool
+
+	// SSHHost 不为空时经由该 SSH 服务器连接数据库，DBHost 和 DBSocket 由 SSH 服务器解析
+	SSHHost       string
+	SSHUser       string
+	SSHKeyFile    string
+	SSHKnownHosts string
 }
 
 // getEnvOrDefault returns the value of the environment variable or a default value
@@ -27,19 +46,51 @@ func getEnvOrDefault(key, defaultValue string) string {
 	return value
 }
 
-// LoadConfig loads configuration from environment variables
+// LoadConfig loads configuration from environment variables, invalid values are ignored
 func LoadConfig() *Config {
-	port, err := strconv.Atoi(getEnvOrDefault("DB_PORT", "3306"))
+	cfg := defaultConfig()
+	env, _ := connectionFromEnv()
+	env.apply(cfg)
+	return cfg
+}
+
+// Load builds the connection settings from the defaults, the profile, the DB_* environment
+// variables and the command line flags, each layer overrides the previous one.
+// Within a layer the option file is applied first, then the DSN, and the individual settings override both.
+// profile and flags may be nil
+func Load(profile *Profile, flags *Connection) (*Config, error) {
+	cfg := defaultConfig()
+	if profile != nil {
+		if err := profile.Connection.apply(cfg); err != nil {
+			return nil, fmt.Errorf("profile connection: %w", err)
+		}
+	}
+
+	env, err := connectionFromEnv()
 	if err != nil {
-		port = 3306
+		return nil, err
+	}
+	if err := env.apply(cfg); err != nil {
+		return nil, fmt.Errorf("environment: %w", err)
 	}
 
+	if flags != nil {
+		if err := flags.apply(cfg); err != nil {
+			return nil, fmt.Errorf("command line: %w", err)
+		}
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+func defaultConfig() *Config {
 	return &Config{
-		DBHost:     getEnvOrDefault("DB_HOST", "localhost"),
-		DBPort:     port,
-		DBUser:     getEnvOrDefault("DB_USER", "root"),
-		DBPassword: getEnvOrDefault("DB_PASSWORD", ""),
-		DBName:     os.Getenv("DB_NAME"), // DB_NAME is required, no default value
+		DBHost: "localhost",
+		DBPort: 3306,
+		DBUser: "root",
 	}
 }
 
@@ -51,7 +102,7 @@ func GetLocalPath(file string) string {
 func LoadTestConfig() *Config {
 	err := godotenv.Load(GetLocalPath("../../.env"))
 	if err != nil {
-		log.Logger.Errorf("Error loading environment: %s", err)
+		log.Logger.Error("failed to load environment", log.Err(err)...)
 	}
 
 	return &Config{
@@ -0,0 +1,204 @@
+package config
+
+import (
+	"bytes"
+	"fmt"
+	"motors-backup/internal/ddl"
+	"motors-backup/internal/selector"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 输出到 stderr 的进度模式，与 progress 包保持一致
+var progressModes = []string{"auto", "bar", "log", "none"}
+
+// File is the layout of the config file selected with --config or MOTORS_BACKUP_CONFIG
+type File struct {
+	// Default 为未指定 --profile 时使用的 profile
+	Default  string             `yaml:"default"`
+	Profiles map[string]Profile `yaml:"profiles"`
+}
+
+// Profile 描述一组命名的导出配置，命令行参数和环境变量优先于 profile 中的值
+type Profile struct {
+	Connection      Connection `yaml:"connection"`
+	Tables          []string   `yaml:"tables"`
+	IgnoreTables    []string   `yaml:"ignore_tables"`
+	IgnoreTableData []string   `yaml:"ignore_table_data"`
+	CreateDatabase  *bool      `yaml:"create_database"`
+	Where           string     `yaml:"where"`
+	// TableWhere 为单个表指定 WHERE 条件，优先于 Where
+	TableWhere map[string]string `yaml:"table_where"`
+	// Masking maps a table to its masked columns and the SQL expression selected instead of each column
+	Masking map[string]map[string]string `yaml:"masking"`
+	// DDL 改写 CREATE 语句，便于导入其他版本或配置的服务器
+	DDL    ddl.Transformer `yaml:"ddl"`
+	Output Output          `yaml:"output"`
+	Retry  Retry           `yaml:"retry"`
+}
+
+// Output 描述导出结果的去向
+type Output struct {
+	Dir             string `yaml:"dir"`
+	SignKey         string `yaml:"sign_key"`
+	Progress        string `yaml:"progress"`
+	MetricsTextfile string `yaml:"metrics_textfile"`
+}
+
+// Retry 描述遇到临时错误时的重试次数和退避时间
+type Retry struct {
+	// Retries 为 0 时不重试，未设置时使用默认值
+	Retries  *int          `yaml:"retries"`
+	Delay    time.Duration `yaml:"delay"`
+	MaxDelay time.Duration `yaml:"max_delay"`
+}
+
+// LoadFile reads and validates a config file, unknown keys are rejected
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	file := new(File)
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	if err := decoder.Decode(file); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	if err := file.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid config file %s: %w", path, err)
+	}
+	return file, nil
+}
+
+// Validate checks every profile, the error names the offending key
+func (f *File) Validate() error {
+	if len(f.Profiles) == 0 {
+		return fmt.Errorf("profiles: at least one profile is required")
+	}
+	if f.Default != "" {
+		if _, ok := f.Profiles[f.Default]; !ok {
+			return fmt.Errorf("default: profile %q is not defined", f.Default)
+		}
+	}
+
+	for _, name := range f.names() {
+		profile := f.Profiles[name]
+		if err := profile.validate("profiles." + name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Profile returns the named profile, an empty name selects the default profile
+// or the only profile of the file
+func (f *File) Profile(name string) (*Profile, error) {
+	if name == "" {
+		name = f.Default
+	}
+	if name == "" && len(f.Profiles) == 1 {
+		name = f.names()[0]
+	}
+	if name == "" {
+		return nil, fmt.Errorf("no profile selected and no default profile, available profiles: %s", strings.Join(f.names(), ", "))
+	}
+
+	profile, ok := f.Profiles[name]
+	if !ok {
+		return nil, fmt.Errorf("profile %q not found, available profiles: %s", name, strings.Join(f.names(), ", "))
+	}
+	return &profile, nil
+}
+
+func (f *File) names() []string {
+	names := make([]string, 0, len(f.Profiles))
+	for name := range f.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// validate 检查 profile 中的值，prefix 为 profile 在配置文件中的路径
+func (p *Profile) validate(prefix string) error {
+	if err := p.Connection.validate(prefix + ".connection"); err != nil {
+		return err
+	}
+
+	lists := map[string][]string{
+		"tables":            p.Tables,
+		"ignore_tables":     p.IgnoreTables,
+		"ignore_table_data": p.IgnoreTableData,
+	}
+	for _, key := range []string{"tables", "ignore_tables", "ignore_table_data"} {
+		for i, table := range lists[key] {
+			if strings.TrimSpace(table) == "" {
+				return fmt.Errorf("%s.%s[%d]: table name must not be empty", prefix, key, i)
+			}
+			if _, err := selector.Parse(table); err != nil {
+				return fmt.Errorf("%s.%s[%d]: %w", prefix, key, i, err)
+			}
+		}
+	}
+
+	for _, table := range sortedKeys(p.TableWhere) {
+		if strings.TrimSpace(p.TableWhere[table]) == "" {
+			return fmt.Errorf("%s.table_where.%s: condition must not be empty", prefix, table)
+		}
+	}
+
+	for _, table := range sortedKeys(p.Masking) {
+		if len(p.Masking[table]) == 0 {
+			return fmt.Errorf("%s.masking.%s: at least one column is required", prefix, table)
+		}
+		for _, column := range sortedKeys(p.Masking[table]) {
+			if strings.TrimSpace(p.Masking[table][column]) == "" {
+				return fmt.Errorf("%s.masking.%s.%s: expression must not be empty", prefix, table, column)
+			}
+		}
+	}
+
+	if err := p.DDL.Validate(); err != nil {
+		return fmt.Errorf("%s.ddl: %w", prefix, err)
+	}
+
+	if p.Output.Progress != "" && !contains(progressModes, p.Output.Progress) {
+		return fmt.Errorf("%s.output.progress: invalid mode %q, expected %s", prefix, p.Output.Progress, strings.Join(progressModes, ", "))
+	}
+
+	if p.Retry.Retries != nil && *p.Retry.Retries < 0 {
+		return fmt.Errorf("%s.retry.retries: must not be negative", prefix)
+	}
+	if p.Retry.Delay < 0 {
+		return fmt.Errorf("%s.retry.delay: must not be negative", prefix)
+	}
+	if p.Retry.MaxDelay < 0 {
+		return fmt.Errorf("%s.retry.max_delay: must not be negative", prefix)
+	}
+	return nil
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func contains(list []string, value string) bool {
+	for _, item := range list {
+		if item == value {
+			return true
+		}
+	}
+	return false
+}
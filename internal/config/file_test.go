@@ -0,0 +1,174 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeConfigFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "motors-backup.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeConfigFile(t, `
+default: production
+profiles:
+  production:
+    connection:
+      host: db.internal
+      port: 3307
+      user: backup
+      password: secret
+      database: shop
+    tables: [users, orders]
+    ignore_table_data: [logs]
+    create_database: false
+    table_where:
+      orders: "created_at > '2024-01-01'"
+    masking:
+      users:
+        email: "CONCAT(id, '@example.invalid')"
+    ddl:
+      strip_auto_increment: true
+      charsets:
+        utf8mb3: utf8mb4
+      definer: strip
+    output:
+      dir: /backups/shop
+      progress: log
+    retry:
+      retries: 5
+      delay: 2s
+  staging:
+    connection:
+      host: staging.internal
+`)
+
+	file, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile returned error: %v", err)
+	}
+
+	profile, err := file.Profile("")
+	if err != nil {
+		t.Fatalf("Profile returned error: %v", err)
+	}
+	if profile.Connection.Host != "db.internal" || profile.Connection.Port != 3307 {
+		t.Errorf("unexpected connection: %+v", profile.Connection)
+	}
+	if profile.CreateDatabase == nil || *profile.CreateDatabase {
+		t.Errorf("CreateDatabase = %v, want false", profile.CreateDatabase)
+	}
+	if profile.TableWhere["orders"] == "" || profile.Masking["users"]["email"] == "" {
+		t.Errorf("unexpected table_where or masking: %+v", profile)
+	}
+	if !profile.DDL.StripAutoIncrement || profile.DDL.Charsets["utf8mb3"] != "utf8mb4" || profile.DDL.Definer != "strip" {
+		t.Errorf("unexpected ddl: %+v", profile.DDL)
+	}
+	if profile.Output.Dir != "/backups/shop" || profile.Output.Progress != "log" {
+		t.Errorf("unexpected output: %+v", profile.Output)
+	}
+	if profile.Retry.Retries == nil || *profile.Retry.Retries != 5 || profile.Retry.Delay != 2*time.Second {
+		t.Errorf("unexpected retry: %+v", profile.Retry)
+	}
+
+	staging, err := file.Profile("staging")
+	if err != nil || staging.Connection.Host != "staging.internal" {
+		t.Errorf("Profile(staging) = %+v, %v", staging, err)
+	}
+	if _, err := file.Profile("missing"); err == nil {
+		t.Error("expected error for unknown profile")
+	}
+}
+
+func TestLoadFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "no profiles",
+			content: "default: production\n",
+			wantErr: "profiles: at least one profile is required",
+		},
+		{
+			name:    "unknown default",
+			content: "default: prod\nprofiles:\n  production: {}\n",
+			wantErr: `default: profile "prod" is not defined`,
+		},
+		{
+			name:    "unknown key",
+			content: "profiles:\n  production:\n    connection:\n      hots: db\n",
+			wantErr: "field hots not found",
+		},
+		{
+			name:    "invalid port",
+			content: "profiles:\n  production:\n    connection:\n      port: 70000\n",
+			wantErr: "profiles.production.connection.port",
+		},
+		{
+			name:    "empty table name",
+			content: "profiles:\n  production:\n    ignore_tables: [logs, '']\n",
+			wantErr: "profiles.production.ignore_tables[1]",
+		},
+		{
+			name:    "invalid table regexp",
+			content: "profiles:\n  production:\n    tables: ['re:(']\n",
+			wantErr: "profiles.production.tables[0]: invalid table selector",
+		},
+		{
+			name:    "empty where",
+			content: "profiles:\n  production:\n    table_where:\n      orders: ''\n",
+			wantErr: "profiles.production.table_where.orders",
+		},
+		{
+			name:    "empty masking expression",
+			content: "profiles:\n  production:\n    masking:\n      users:\n        email: ''\n",
+			wantErr: "profiles.production.masking.users.email",
+		},
+		{
+			name:    "invalid definer policy",
+			content: "profiles:\n  production:\n    ddl:\n      definer: root\n",
+			wantErr: "profiles.production.ddl: invalid definer policy",
+		},
+		{
+			name:    "invalid progress",
+			content: "profiles:\n  production:\n    output:\n      progress: fancy\n",
+			wantErr: "profiles.production.output.progress",
+		},
+		{
+			name:    "negative retries",
+			content: "profiles:\n  production:\n    retry:\n      retries: -1\n",
+			wantErr: "profiles.production.retry.retries",
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadFile(writeConfigFile(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("LoadFile() error = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestProfileSelection(t *testing.T) {
+	single := &File{Profiles: map[string]Profile{"only": {}}}
+	if _, err := single.Profile(""); err != nil {
+		t.Errorf("the only profile should be selected by default: %v", err)
+	}
+
+	multiple := &File{Profiles: map[string]Profile{"a": {}, "b": {}}}
+	if _, err := multiple.Profile(""); err == nil {
+		t.Error("expected error when no profile is selected")
+	}
+}
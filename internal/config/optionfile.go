@@ -0,0 +1,151 @@
+package config
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 读取的选项组，后面的组优先，与 mysql 客户端读取 [client] 和程序自身的组相同
+var optionGroups = []string{"client", "motors-backup"}
+
+// LoadOptionFile reads the connection settings from the [client] and [motors-backup] groups of a
+// MySQL option file such as ~/.my.cnf, other groups and unknown options are ignored
+func LoadOptionFile(path string) (*Connection, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read option file: %w", err)
+	}
+	// 与 mysql 客户端一致，不读取所有人可写的选项文件
+	if info.Mode().Perm()&0o002 != 0 {
+		return nil, fmt.Errorf("option file %s is world-writable", path)
+	}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read option file: %w", err)
+	}
+	defer file.Close()
+
+	groups := make(map[string]map[string]string)
+	group := ""
+	scanner := bufio.NewScanner(file)
+	for lineNo := 1; scanner.Scan(); lineNo++ {
+		line := strings.TrimSpace(scanner.Text())
+		switch {
+		case line == "" || line[0] == '#' || line[0] == ';':
+			continue
+		case line[0] == '!':
+			return nil, fmt.Errorf("%s:%d: include directives are not supported", path, lineNo)
+		case line[0] == '[':
+			end := strings.IndexByte(line, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("%s:%d: invalid group header", path, lineNo)
+			}
+			group = strings.ToLower(strings.TrimSpace(line[1:end]))
+			continue
+		}
+
+		if group == "" {
+			return nil, fmt.Errorf("%s:%d: option outside of a group", path, lineNo)
+		}
+		key, value, err := parseOption(line)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, lineNo, err)
+		}
+		if groups[group] == nil {
+			groups[group] = make(map[string]string)
+		}
+		groups[group][key] = value
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read option file: %w", err)
+	}
+
+	options := make(map[string]string)
+	for _, name := range optionGroups {
+		for key, value := range groups[name] {
+			options[key] = value
+		}
+	}
+	return optionsToConnection(options)
+}
+
+// parseOption 解析 key = value 或单独的 key，键名中的 _ 与 - 等价
+func parseOption(line string) (string, string, error) {
+	key, value, hasValue := strings.Cut(line, "=")
+	key = strings.ReplaceAll(strings.ToLower(strings.TrimSpace(key)), "_", "-")
+	if key == "" {
+		return "", "", fmt.Errorf("missing option name")
+	}
+	if !hasValue {
+		return key, "", nil
+	}
+
+	value = strings.TrimSpace(value)
+	if len(value) > 0 && (value[0] == '"' || value[0] == '\'') {
+		end := strings.IndexByte(value[1:], value[0])
+		if end < 0 {
+			return "", "", fmt.Errorf("unterminated quote in %s", key)
+		}
+		return key, unescapeOption(value[1 : end+1]), nil
+	}
+	// 未加引号时 # 之后为注释
+	if i := strings.IndexByte(value, '#'); i >= 0 {
+		value = strings.TrimSpace(value[:i])
+	}
+	return key, unescapeOption(value), nil
+}
+
+var optionUnescaper = strings.NewReplacer(`\b`, "\b", `\t`, "\t", `\n`, "\n", `\r`, "\r", `\s`, " ", `\\`, `\`)
+
+func unescapeOption(value string) string {
+	return optionUnescaper.Replace(value)
+}
+
+func optionsToConnection(options map[string]string) (*Connection, error) {
+	c := &Connection{
+		Host:     options["host"],
+		User:     options["user"],
+		Password: options["password"],
+		Database: options["database"],
+		Socket:   options["socket"],
+		SSLMode:  options["ssl-mode"],
+		SSLCA:    options["ssl-ca"],
+		SSLCert:  options["ssl-cert"],
+		SSLKey:   options["ssl-key"],
+	}
+
+	if value, ok := options["port"]; ok {
+		port, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid port %q", value)
+		}
+		c.Port = port
+	}
+	// mysql 客户端的 connect-timeout 以秒为单位
+	if value, ok := options["connect-timeout"]; ok {
+		seconds, err := strconv.Atoi(value)
+		if err != nil {
+			return nil, fmt.Errorf("invalid connect-timeout %q", value)
+		}
+		c.ConnectTimeout = time.Duration(seconds) * time.Second
+	}
+	if value, ok := options["compress"]; ok {
+		compress := true
+		if value != "" {
+			var err error
+			if compress, err = strconv.ParseBool(value); err != nil {
+				return nil, fmt.Errorf("invalid compress %q", value)
+			}
+		}
+		c.Compress = &compress
+	}
+	if c.SSLMode != "" && !contains(sslModes, NormalizeSSLMode(c.SSLMode)) {
+		return nil, fmt.Errorf("invalid ssl-mode %q, expected %s", c.SSLMode, strings.Join(sslModes, ", "))
+	}
+	return c, nil
+}
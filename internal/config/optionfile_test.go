@@ -0,0 +1,118 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeOptionFile(t *testing.T, content string, perm os.FileMode) string {
+	path := filepath.Join(t.TempDir(), "my.cnf")
+	if err := os.WriteFile(path, []byte(content), perm); err != nil {
+		t.Fatalf("Failed to write option file: %v", err)
+	}
+	if err := os.Chmod(path, perm); err != nil {
+		t.Fatalf("Failed to chmod option file: %v", err)
+	}
+	return path
+}
+
+func TestLoadOptionFile(t *testing.T) {
+	path := writeOptionFile(t, `
+# 共用的客户端设置
+[client]
+host = db.internal
+port=3307
+user = backup
+password = "p#ss word"
+ssl_mode = VERIFY_IDENTITY
+connect-timeout = 5
+compress
+
+[mysqldump]
+user = ignored
+
+[motors-backup]
+user = motors   # 覆盖 [client]
+`, 0o600)
+
+	c, err := LoadOptionFile(path)
+	if err != nil {
+		t.Fatalf("LoadOptionFile returned error: %v", err)
+	}
+	if c.Host != "db.internal" || c.Port != 3307 || c.User != "motors" || c.Password != "p#ss word" {
+		t.Errorf("unexpected connection: %+v", c)
+	}
+	if c.SSLMode != "VERIFY_IDENTITY" || c.ConnectTimeout != 5*time.Second || c.Compress == nil || !*c.Compress {
+		t.Errorf("unexpected options: %+v", c)
+	}
+}
+
+func TestLoadOptionFileErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		perm    os.FileMode
+		wantErr string
+	}{
+		{"world writable", "[client]\nuser=root\n", 0o666, "world-writable"},
+		{"include", "!include /etc/mysql/common.cnf\n", 0o600, "include directives"},
+		{"option outside group", "user=root\n", 0o600, ":1: option outside of a group"},
+		{"invalid port", "[client]\nport=abc\n", 0o600, "invalid port"},
+		{"unterminated quote", "[client]\npassword='secret\n", 0o600, "unterminated quote"},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadOptionFile(writeOptionFile(t, tc.content, tc.perm))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("LoadOptionFile() error = %v, want it to contain %q", err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestPasswordSources(t *testing.T) {
+	for _, key := range []string{"DB_DEFAULTS_FILE", "DB_DSN", "DB_HOST", "DB_PORT", "DB_USER", "DB_PASSWORD", "DB_PASSWORD_FILE", "DB_NAME", "DB_SOCKET",
+		"DB_SSL_MODE", "DB_SSL_CA", "DB_SSL_CERT", "DB_SSL_KEY", "DB_CONNECT_TIMEOUT", "DB_READ_TIMEOUT", "DB_COMPRESS"} {
+		t.Setenv(key, "")
+	}
+
+	dir := t.TempDir()
+	secret := filepath.Join(dir, "db_password")
+	if err := os.WriteFile(secret, []byte("from-secret\n"), 0o600); err != nil {
+		t.Fatalf("Failed to write secret: %v", err)
+	}
+	optionFile := writeOptionFile(t, "[client]\nuser=backup\npassword=from-option-file\n", 0o600)
+
+	// 选项文件位于同一层的最底部
+	cfg, err := Load(nil, &Connection{DefaultsFile: optionFile})
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.DBUser != "backup" || cfg.DBPassword != "from-option-file" {
+		t.Errorf("option file not applied: %+v", cfg)
+	}
+
+	// DB_PASSWORD_FILE 读取 secret 文件并去掉末尾的换行
+	t.Setenv("DB_PASSWORD_FILE", secret)
+	cfg, err = Load(nil, nil)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if cfg.DBPassword != "from-secret" {
+		t.Errorf("DBPassword = %q, want the trimmed secret", cfg.DBPassword)
+	}
+
+	t.Setenv("DB_PASSWORD", "inline")
+	if _, err := Load(nil, nil); err == nil {
+		t.Error("expected error when DB_PASSWORD and DB_PASSWORD_FILE are both set")
+	}
+
+	t.Setenv("DB_PASSWORD", "")
+	t.Setenv("DB_PASSWORD_FILE", filepath.Join(dir, "missing"))
+	if _, err := Load(nil, nil); err == nil {
+		t.Error("expected error for a missing password file")
+	}
+}
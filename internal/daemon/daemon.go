@@ -0,0 +1,202 @@
+package daemon
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand/v2"
+	"motors-backup/internal/log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+// RunFunc executes one backup job and returns the path of the produced backup
+type RunFunc func(job Job) (string, error)
+
+// Status 记录任务的最近一次运行情况
+type Status struct {
+	Name         string    `json:"name"`
+	Schedule     string    `json:"schedule"`
+	TimeZone     string    `json:"time_zone,omitempty"`
+	Running      bool      `json:"running"`
+	NextRun      time.Time `json:"next_run"`
+	LastStart    time.Time `json:"last_start,omitempty"`
+	LastEnd      time.Time `json:"last_end,omitempty"`
+	LastDuration string    `json:"last_duration,omitempty"`
+	LastResult   string    `json:"last_result,omitempty"`
+	LastError    string    `json:"last_error,omitempty"`
+	LastOutput   string    `json:"last_output,omitempty"`
+	Successes    int       `json:"successes"`
+	Failures     int       `json:"failures"`
+	Skipped      int       `json:"skipped"`
+}
+
+const (
+	ResultSuccess = "success"
+	ResultFailed  = "failed"
+	ResultSkipped = "skipped"
+)
+
+// Daemon 按 cron 表达式调度备份任务
+type Daemon struct {
+	cron    *cron.Cron
+	run     RunFunc
+	jobs    map[string]Job
+	entries map[string]cron.EntryID
+
+	mu       sync.Mutex
+	status   map[string]*Status
+	wg       sync.WaitGroup
+	stopping chan struct{}
+}
+
+// New creates a daemon for the given jobs, the jobs are not started until Start is called
+func New(jobs []Job, run RunFunc) (*Daemon, error) {
+	d := &Daemon{
+		cron:     cron.New(),
+		run:      run,
+		jobs:     make(map[string]Job),
+		entries:  make(map[string]cron.EntryID),
+		status:   make(map[string]*Status),
+		stopping: make(chan struct{}),
+	}
+
+	for _, job := range jobs {
+		if err := job.Validate(); err != nil {
+			return nil, err
+		}
+		if _, ok := d.jobs[job.Name]; ok {
+			return nil, fmt.Errorf("duplicate job name %q", job.Name)
+		}
+
+		name := job.Name
+		id, err := d.cron.AddFunc(job.Spec(), func() { d.trigger(name) })
+		if err != nil {
+			return nil, fmt.Errorf("job %q: %w", name, err)
+		}
+
+		d.jobs[name] = job
+		d.entries[name] = id
+		d.status[name] = &Status{
+			Name:     name,
+			Schedule: job.Schedule,
+			TimeZone: job.TimeZone,
+		}
+	}
+
+	return d, nil
+}
+
+// Start starts the scheduler in the background
+func (d *Daemon) Start() {
+	d.cron.Start()
+	for name := range d.jobs {
+		log.Logger.Info("job scheduled", log.KeyJob, name, "next_run", d.cron.Entry(d.entries[name]).Next)
+	}
+}
+
+// Stop stops scheduling new runs and waits for running jobs to finish
+func (d *Daemon) Stop() {
+	close(d.stopping)
+	<-d.cron.Stop().Done()
+	d.wg.Wait()
+}
+
+// trigger 由 cron 调用，负责防止同一任务重叠执行并加入随机延迟
+func (d *Daemon) trigger(name string) {
+	d.mu.Lock()
+	status := d.status[name]
+	if status.Running {
+		status.Skipped++
+		d.mu.Unlock()
+		log.Logger.Warn("job is still running, skipping this run", log.KeyJob, name)
+		return
+	}
+	status.Running = true
+	d.wg.Add(1)
+	d.mu.Unlock()
+	defer d.wg.Done()
+
+	job := d.jobs[name]
+	if job.Jitter > 0 {
+		delay := rand.N(job.Jitter)
+		select {
+		case <-time.After(delay):
+		case <-d.stopping:
+			d.mu.Lock()
+			status.Running = false
+			d.mu.Unlock()
+			return
+		}
+	}
+
+	d.execute(job, status)
+}
+
+// execute 执行任务并更新状态
+func (d *Daemon) execute(job Job, status *Status) {
+	start := time.Now()
+	d.mu.Lock()
+	status.LastStart = start
+	d.mu.Unlock()
+
+	log.Logger.Info("job started", log.KeyJob, job.Name, log.KeyDatabase, job.Database)
+	output, err := d.run(job)
+	end := time.Now()
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	status.Running = false
+	status.LastEnd = end
+	status.LastDuration = end.Sub(start).Round(time.Millisecond).String()
+	status.LastOutput = output
+	if err != nil {
+		status.LastResult = ResultFailed
+		status.LastError = err.Error()
+		status.Failures++
+		log.Logger.Error("job failed", append(log.Err(err), log.KeyJob, job.Name, log.Duration(end.Sub(start)))...)
+		return
+	}
+	status.LastResult = ResultSuccess
+	status.LastError = ""
+	status.Successes++
+	log.Logger.Info("job finished", log.KeyJob, job.Name, log.Duration(end.Sub(start)), "output", output)
+}
+
+// Statuses returns a snapshot of all job statuses sorted by name
+func (d *Daemon) Statuses() []Status {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	statuses := make([]Status, 0, len(d.status))
+	for name, status := range d.status {
+		snapshot := *status
+		snapshot.NextRun = d.cron.Entry(d.entries[name]).Next
+		statuses = append(statuses, snapshot)
+	}
+	sort.Slice(statuses, func(i, j int) bool {
+		return statuses[i].Name < statuses[j].Name
+	})
+	return statuses
+}
+
+// Handler returns the HTTP handler exposing /status and /healthz
+func (d *Daemon) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/status", func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		if err := encoder.Encode(d.Statuses()); err != nil {
+			log.Logger.Error("failed to encode status", log.Err(err)...)
+		}
+	})
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprintln(w, "ok")
+	})
+	return mux
+}
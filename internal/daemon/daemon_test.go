@@ -0,0 +1,64 @@
+package daemon
+
+import (
+	"errors"
+	"sync"
+	"testing"
+)
+
+func TestDaemonSkipsOverlappingRuns(t *testing.T) {
+	release := make(chan struct{})
+	started := make(chan struct{})
+	d, err := New([]Job{{Name: "a", Destination: "/tmp", Schedule: "@daily"}}, func(job Job) (string, error) {
+		close(started)
+		<-release
+		return "/tmp/a.sql", nil
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		d.trigger("a")
+	}()
+	<-started
+
+	// 第一次运行尚未结束，第二次触发应被跳过
+	d.trigger("a")
+	close(release)
+	wg.Wait()
+
+	status := d.Statuses()[0]
+	if status.Successes != 1 || status.Skipped != 1 {
+		t.Errorf("Successes = %d, Skipped = %d, want 1 and 1", status.Successes, status.Skipped)
+	}
+	if status.LastResult != ResultSuccess || status.LastOutput != "/tmp/a.sql" || status.Running {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestDaemonRecordsFailures(t *testing.T) {
+	d, err := New([]Job{{Name: "a", Destination: "/tmp", Schedule: "@daily"}}, func(job Job) (string, error) {
+		return "", errors.New("connection refused")
+	})
+	if err != nil {
+		t.Fatalf("New returned error: %v", err)
+	}
+
+	d.trigger("a")
+
+	status := d.Statuses()[0]
+	if status.Failures != 1 || status.LastResult != ResultFailed || status.LastError != "connection refused" {
+		t.Errorf("unexpected status: %+v", status)
+	}
+}
+
+func TestNewRejectsInvalidJobs(t *testing.T) {
+	_, err := New([]Job{{Name: "a", Destination: "/tmp", Schedule: "not a schedule"}}, nil)
+	if err == nil {
+		t.Error("Expected error for invalid schedule, got nil")
+	}
+}
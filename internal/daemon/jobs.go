@@ -0,0 +1,114 @@
+package daemon
+
+import (
+	"fmt"
+	"motors-backup/internal/selector"
+	"os"
+	"time"
+
+	"github.com/robfig/cron/v3"
+	"gopkg.in/yaml.v3"
+)
+
+// Job 描述一个定时备份任务
+type Job struct {
+	Name            string        `yaml:"name"`
+	Database        string        `yaml:"database"`
+	Tables          []string      `yaml:"tables"`
+	IgnoreTables    []string      `yaml:"ignore_tables"`
+	IgnoreTableData []string      `yaml:"ignore_table_data"`
+	Where           string        `yaml:"where"`
+	CreateDatabase  *bool         `yaml:"create_database"`
+	Destination     string        `yaml:"destination"`
+	SignKey         string        `yaml:"sign_key"`
+	SourceData      int           `yaml:"source_data"`
+	Schedule        string        `yaml:"schedule"`
+	TimeZone        string        `yaml:"time_zone"`
+	Jitter          time.Duration `yaml:"jitter"`
+}
+
+// File is the layout of the jobs file read by the serve command
+type File struct {
+	Listen string `yaml:"listen"`
+	Jobs   []Job  `yaml:"jobs"`
+}
+
+// LoadFile reads and validates a jobs file
+func LoadFile(path string) (*File, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read jobs file: %w", err)
+	}
+
+	file := new(File)
+	if err := yaml.Unmarshal(data, file); err != nil {
+		return nil, fmt.Errorf("failed to parse jobs file %s: %w", path, err)
+	}
+
+	if len(file.Jobs) == 0 {
+		return nil, fmt.Errorf("no jobs defined in %s", path)
+	}
+
+	names := make(map[string]bool)
+	for i, job := range file.Jobs {
+		if err := job.Validate(); err != nil {
+			return nil, fmt.Errorf("jobs[%d]: %w", i, err)
+		}
+		if names[job.Name] {
+			return nil, fmt.Errorf("jobs[%d]: duplicate job name %q", i, job.Name)
+		}
+		names[job.Name] = true
+	}
+
+	return file, nil
+}
+
+// Validate checks that the job has everything it needs to be scheduled
+func (j *Job) Validate() error {
+	if j.Name == "" {
+		return fmt.Errorf("name is required")
+	}
+	if j.Destination == "" {
+		return fmt.Errorf("job %q: destination is required", j.Name)
+	}
+	for _, list := range [][]string{j.Tables, j.IgnoreTables, j.IgnoreTableData} {
+		if _, err := selector.ParseAll(list); err != nil {
+			return fmt.Errorf("job %q: %w", j.Name, err)
+		}
+	}
+	if j.SourceData < 0 || j.SourceData > 2 {
+		return fmt.Errorf("job %q: source_data must be 0, 1 or 2", j.Name)
+	}
+	if j.Jitter < 0 {
+		return fmt.Errorf("job %q: jitter must not be negative", j.Name)
+	}
+	if _, err := j.schedule(); err != nil {
+		return fmt.Errorf("job %q: %w", j.Name, err)
+	}
+	return nil
+}
+
+// Spec returns the cron spec including the time zone prefix
+func (j *Job) Spec() string {
+	if j.TimeZone == "" {
+		return j.Schedule
+	}
+	return fmt.Sprintf("CRON_TZ=%s %s", j.TimeZone, j.Schedule)
+}
+
+// schedule 解析 cron 表达式和时区
+func (j *Job) schedule() (cron.Schedule, error) {
+	if j.Schedule == "" {
+		return nil, fmt.Errorf("schedule is required")
+	}
+	if j.TimeZone != "" {
+		if _, err := time.LoadLocation(j.TimeZone); err != nil {
+			return nil, fmt.Errorf("invalid time_zone %q: %w", j.TimeZone, err)
+		}
+	}
+	schedule, err := cron.ParseStandard(j.Spec())
+	if err != nil {
+		return nil, fmt.Errorf("invalid schedule %q: %w", j.Schedule, err)
+	}
+	return schedule, nil
+}
@@ -0,0 +1,113 @@
+package daemon
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeJobsFile(t *testing.T, content string) string {
+	path := filepath.Join(t.TempDir(), "jobs.yml")
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write jobs file: %v", err)
+	}
+	return path
+}
+
+func TestLoadFile(t *testing.T) {
+	path := writeJobsFile(t, `
+listen: ":9000"
+jobs:
+  - name: nightly
+    database: shop
+    tables: [users, orders]
+    ignore_table_data: [logs]
+    where: "id>100"
+    create_database: false
+    destination: /backups/shop
+    schedule: "30 2 * * *"
+    time_zone: Asia/Hong_Kong
+    jitter: 5m
+`)
+
+	file, err := LoadFile(path)
+	if err != nil {
+		t.Fatalf("LoadFile returned error: %v", err)
+	}
+
+	if file.Listen != ":9000" {
+		t.Errorf("Listen = %s, want :9000", file.Listen)
+	}
+	if len(file.Jobs) != 1 {
+		t.Fatalf("Jobs length = %d, want 1", len(file.Jobs))
+	}
+
+	job := file.Jobs[0]
+	if job.Database != "shop" || len(job.Tables) != 2 || job.Where != "id>100" {
+		t.Errorf("unexpected job: %+v", job)
+	}
+	if job.CreateDatabase == nil || *job.CreateDatabase {
+		t.Errorf("CreateDatabase = %v, want false", job.CreateDatabase)
+	}
+	if job.Jitter != 5*time.Minute {
+		t.Errorf("Jitter = %s, want 5m", job.Jitter)
+	}
+	if job.Spec() != "CRON_TZ=Asia/Hong_Kong 30 2 * * *" {
+		t.Errorf("Spec = %s", job.Spec())
+	}
+}
+
+func TestLoadFileInvalid(t *testing.T) {
+	testCases := []struct {
+		name    string
+		content string
+		wantErr string
+	}{
+		{
+			name:    "no jobs",
+			content: "listen: \":9000\"\n",
+			wantErr: "no jobs defined",
+		},
+		{
+			name:    "missing destination",
+			content: "jobs:\n  - name: a\n    schedule: \"@daily\"\n",
+			wantErr: "destination is required",
+		},
+		{
+			name:    "invalid schedule",
+			content: "jobs:\n  - name: a\n    destination: /tmp\n    schedule: \"61 * * * *\"\n",
+			wantErr: "invalid schedule",
+		},
+		{
+			name:    "invalid time zone",
+			content: "jobs:\n  - name: a\n    destination: /tmp\n    schedule: \"@daily\"\n    time_zone: Mars/Olympus\n",
+			wantErr: "invalid time_zone",
+		},
+		{
+			name:    "invalid table selector",
+			content: "jobs:\n  - name: a\n    destination: /tmp\n    schedule: \"@daily\"\n    ignore_tables: ['re:[']\n",
+			wantErr: "invalid table selector",
+		},
+		{
+			name:    "invalid source data",
+			content: "jobs:\n  - name: a\n    destination: /tmp\n    schedule: \"@daily\"\n    source_data: 3\n",
+			wantErr: "source_data must be 0, 1 or 2",
+		},
+		{
+			name:    "duplicate names",
+			content: "jobs:\n  - name: a\n    destination: /tmp\n    schedule: \"@daily\"\n  - name: a\n    destination: /tmp\n    schedule: \"@daily\"\n",
+			wantErr: "duplicate job name",
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			_, err := LoadFile(writeJobsFile(t, tc.content))
+			if err == nil || !strings.Contains(err.Error(), tc.wantErr) {
+				t.Errorf("LoadFile error = %v, want %q", err, tc.wantErr)
+			}
+		})
+	}
+}
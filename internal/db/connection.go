@@ -1,38 +1,215 @@
 package db
 
 import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
 	"database/sql"
+	"errors"
 	"fmt"
 	"motors-backup/internal/config"
+	"motors-backup/internal/tunnel"
+	"net"
+	"os"
+	"strconv"
+	"sync"
 
-	_ "github.com/go-sql-driver/mysql"
+	"github.com/go-sql-driver/mysql"
 )
 
-// Connect establishes a connection to the MySQL database
-func Connect(cfg *config.Config) (*sql.DB, error) {
-	// 构建数据库连接字符串
-	dsn := fmt.Sprintf("%s:%s@tcp(%s:%d)/%s?charset=utf8mb4&parseTime=false",
-		cfg.DBUser,
-		cfg.DBPassword,
-		cfg.DBHost,
-		cfg.DBPort,
-		cfg.DBName)
-
-	// 打开数据库连接
-	db, err := sql.Open("mysql", dsn)
+// tunnels 记录经由 SSH 隧道建立的连接池，Close 时一并关闭隧道
+var (
+	tunnelsMu sync.Mutex
+	tunnels   = make(map[*sql.DB]*tunnel.Tunnel)
+)
+
+// Querier runs queries on a connection pool, a single connection or a transaction, exports inside a
+// consistent snapshot use the connection holding the snapshot
+type Querier interface {
+	QueryContext(ctx context.Context, query string, args ...any) (*sql.Rows, error)
+	QueryRowContext(ctx context.Context, query string, args ...any) *sql.Row
+}
+
+// Connect establishes a connection to the MySQL database, through an SSH tunnel when cfg.SSHHost is set
+func Connect(ctx context.Context, cfg *config.Config) (*sql.DB, error) {
+	mysqlCfg, err := NewMySQLConfig(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	tun, err := OpenTunnel(cfg)
+	if err != nil {
+		return nil, err
+	}
+	if tun != nil {
+		// 驱动的所有连接都经由 SSH 服务器拨号，不需要外部的 ssh -L 进程
+		mysqlCfg.DialFunc = tun.DialContext
+	}
+
+	connector, err := mysql.NewConnector(mysqlCfg)
 	if err != nil {
+		closeTunnel(tun)
 		return nil, fmt.Errorf("failed to open database: %w", err)
 	}
+	db := sql.OpenDB(connector)
 
 	// 测试连接
-	if err := db.Ping(); err != nil {
+	if err := db.PingContext(ctx); err != nil {
+		db.Close()
+		closeTunnel(tun)
 		return nil, fmt.Errorf("failed to ping database: %w", err)
 	}
 
+	if tun != nil {
+		tunnelsMu.Lock()
+		tunnels[db] = tun
+		tunnelsMu.Unlock()
+	}
 	return db, nil
 }
 
-// Close closes the database connection
+// OpenTunnel opens the SSH tunnel configured by cfg.SSHHost, it returns nil when no tunnel is configured
+func OpenTunnel(cfg *config.Config) (*tunnel.Tunnel, error) {
+	if cfg.SSHHost == "" {
+		return nil, nil
+	}
+	tun, err := tunnel.Open(tunnel.Config{
+		Host:           cfg.SSHHost,
+		User:           cfg.SSHUser,
+		KeyFile:        cfg.SSHKeyFile,
+		KnownHostsFile: cfg.SSHKnownHosts,
+		Timeout:        cfg.ConnectTimeout,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to open ssh tunnel: %w", err)
+	}
+	return tun, nil
+}
+
+func closeTunnel(tun *tunnel.Tunnel) {
+	if tun != nil {
+		tun.Close()
+	}
+}
+
+// NewMySQLConfig builds the driver configuration, so that passwords with special characters
+// need no escaping
+func NewMySQLConfig(cfg *config.Config) (*mysql.Config, error) {
+	mysqlCfg := mysql.NewConfig()
+	mysqlCfg.User = cfg.DBUser
+	mysqlCfg.Passwd = cfg.DBPassword
+	mysqlCfg.DBName = cfg.DBName
+	mysqlCfg.Timeout = cfg.ConnectTimeout
+	mysqlCfg.ReadTimeout = cfg.ReadTimeout
+	if cfg.DBSocket != "" {
+		mysqlCfg.Net = "unix"
+		mysqlCfg.Addr = cfg.DBSocket
+	} else {
+		mysqlCfg.Net = "tcp"
+		mysqlCfg.Addr = net.JoinHostPort(cfg.DBHost, strconv.Itoa(cfg.DBPort))
+	}
+
+	if err := mysqlCfg.Apply(
+		mysql.Charset("utf8mb4", ""),
+		mysql.EnableCompression(cfg.Compress),
+	); err != nil {
+		return nil, err
+	}
+
+	if err := configureTLS(mysqlCfg, cfg); err != nil {
+		return nil, err
+	}
+	return mysqlCfg, nil
+}
+
+// configureTLS 按 ssl mode 设置 TLS，行为与 mysql 客户端的 --ssl-mode 一致
+func configureTLS(mysqlCfg *mysql.Config, cfg *config.Config) error {
+	mode := cfg.EffectiveSSLMode()
+	if mode == config.SSLDisabled {
+		mysqlCfg.TLSConfig = "false"
+		return nil
+	}
+
+	tlsCfg := &tls.Config{}
+	if cfg.SSLCert != "" {
+		cert, err := tls.LoadX509KeyPair(cfg.SSLCert, cfg.SSLKey)
+		if err != nil {
+			return fmt.Errorf("failed to load ssl cert and key: %w", err)
+		}
+		tlsCfg.Certificates = []tls.Certificate{cert}
+	}
+
+	var roots *x509.CertPool
+	if cfg.SSLCA != "" {
+		pem, err := os.ReadFile(cfg.SSLCA)
+		if err != nil {
+			return fmt.Errorf("failed to read ssl ca: %w", err)
+		}
+		roots = x509.NewCertPool()
+		if !roots.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("no certificate found in ssl ca %s", cfg.SSLCA)
+		}
+	}
+
+	switch mode {
+	case config.SSLPreferred:
+		// 服务器不支持 TLS 时使用明文连接
+		tlsCfg.InsecureSkipVerify = true
+		mysqlCfg.AllowFallbackToPlaintext = true
+	case config.SSLRequired:
+		tlsCfg.InsecureSkipVerify = true
+	case config.SSLVerifyCA:
+		// 只校验证书链，不校验主机名
+		tlsCfg.InsecureSkipVerify = true
+		tlsCfg.VerifyPeerCertificate = verifyChain(roots)
+	case config.SSLVerifyIdentity:
+		tlsCfg.RootCAs = roots
+		tlsCfg.ServerName = cfg.DBHost
+	default:
+		return fmt.Errorf("invalid ssl mode %q", mode)
+	}
+
+	mysqlCfg.TLS = tlsCfg
+	return nil
+}
+
+// verifyChain returns a verifier checking the server certificate against roots without the host name,
+// nil roots means the system pool
+func verifyChain(roots *x509.CertPool) func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+	return func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+		if len(rawCerts) == 0 {
+			return errors.New("server sent no certificate")
+		}
+		certs := make([]*x509.Certificate, len(rawCerts))
+		for i, raw := range rawCerts {
+			cert, err := x509.ParseCertificate(raw)
+			if err != nil {
+				return fmt.Errorf("failed to parse server certificate: %w", err)
+			}
+			certs[i] = cert
+		}
+
+		intermediates := x509.NewCertPool()
+		for _, cert := range certs[1:] {
+			intermediates.AddCert(cert)
+		}
+		_, err := certs[0].Verify(x509.VerifyOptions{Roots: roots, Intermediates: intermediates})
+		return err
+	}
+}
+
+// Close closes the database connection and its SSH tunnel
 func Close(db *sql.DB) error {
-	return db.Close()
+	err := db.Close()
+
+	tunnelsMu.Lock()
+	tun := tunnels[db]
+	delete(tunnels, db)
+	tunnelsMu.Unlock()
+	if tun != nil {
+		if tunnelErr := tun.Close(); err == nil {
+			err = tunnelErr
+		}
+	}
+	return err
 }
@@ -0,0 +1,100 @@
+package db
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"motors-backup/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestNewMySQLConfig(t *testing.T) {
+	cfg := &config.Config{
+		DBHost:     "db.internal",
+		DBPort:     3307,
+		DBUser:     "backup",
+		DBPassword: "p@ss/w:rd",
+		DBName:     "shop",
+		Compress:   true,
+	}
+
+	mysqlCfg, err := NewMySQLConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewMySQLConfig returned error: %v", err)
+	}
+	if mysqlCfg.Net != "tcp" || mysqlCfg.Addr != "db.internal:3307" {
+		t.Errorf("unexpected address %s(%s)", mysqlCfg.Net, mysqlCfg.Addr)
+	}
+	if mysqlCfg.TLS == nil || !mysqlCfg.TLS.InsecureSkipVerify || !mysqlCfg.AllowFallbackToPlaintext {
+		t.Errorf("default ssl mode should be preferred: %+v", mysqlCfg.TLS)
+	}
+
+	// 密码中的特殊字符不会破坏 DSN
+	parsed, err := mysql.ParseDSN(mysqlCfg.FormatDSN())
+	if err != nil {
+		t.Fatalf("FormatDSN produced an invalid DSN: %v", err)
+	}
+	if parsed.Passwd != cfg.DBPassword || parsed.DBName != "shop" {
+		t.Errorf("password or database changed: %q %q", parsed.Passwd, parsed.DBName)
+	}
+
+	cfg.DBSocket = "/var/run/mysqld/mysqld.sock"
+	mysqlCfg, err = NewMySQLConfig(cfg)
+	if err != nil {
+		t.Fatalf("NewMySQLConfig returned error: %v", err)
+	}
+	if mysqlCfg.Net != "unix" || mysqlCfg.Addr != cfg.DBSocket || mysqlCfg.TLS != nil {
+		t.Errorf("unexpected socket config %s(%s) tls=%v", mysqlCfg.Net, mysqlCfg.Addr, mysqlCfg.TLS)
+	}
+}
+
+func TestConfigureTLS(t *testing.T) {
+	server := httptest.NewTLSServer(http.NotFoundHandler())
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0o644); err != nil {
+		t.Fatalf("Failed to write CA: %v", err)
+	}
+
+	// verify_identity 校验主机名
+	mysqlCfg := mysql.NewConfig()
+	err := configureTLS(mysqlCfg, &config.Config{DBHost: "db.internal", SSLMode: config.SSLVerifyIdentity, SSLCA: caFile})
+	if err != nil {
+		t.Fatalf("configureTLS returned error: %v", err)
+	}
+	if mysqlCfg.TLS.InsecureSkipVerify || mysqlCfg.TLS.ServerName != "db.internal" || mysqlCfg.TLS.RootCAs == nil {
+		t.Errorf("verify_identity should verify the host name: %+v", mysqlCfg.TLS)
+	}
+
+	// verify_ca 只校验证书链
+	mysqlCfg = mysql.NewConfig()
+	if err := configureTLS(mysqlCfg, &config.Config{DBHost: "db.internal", SSLCA: caFile}); err != nil {
+		t.Fatalf("configureTLS returned error: %v", err)
+	}
+	verify := mysqlCfg.TLS.VerifyPeerCertificate
+	if verify == nil {
+		t.Fatal("verify_ca should install a chain verifier")
+	}
+	if err := verify([][]byte{server.Certificate().Raw}, nil); err != nil {
+		t.Errorf("certificate signed by the CA should be accepted: %v", err)
+	}
+	if err := verifyChain(x509.NewCertPool())([][]byte{server.Certificate().Raw}, nil); err == nil {
+		t.Error("certificate not signed by the CA should be rejected")
+	}
+
+	mysqlCfg = mysql.NewConfig()
+	if err := configureTLS(mysqlCfg, &config.Config{SSLMode: config.SSLDisabled}); err != nil || mysqlCfg.TLS != nil {
+		t.Errorf("disabled should not configure TLS: %v %+v", err, mysqlCfg.TLS)
+	}
+
+	if err := configureTLS(mysql.NewConfig(), &config.Config{SSLCA: filepath.Join(t.TempDir(), "missing.pem")}); err == nil {
+		t.Error("expected error for missing CA file")
+	}
+}
@@ -0,0 +1,270 @@
+// Package ddl rewrites the DDL read from the server so that a dump can be loaded into other servers
+package ddl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Kind 为 DDL 所属的对象类型，表选项只对表生效
+type Kind int
+
+const (
+	KindDatabase Kind = iota
+	KindTable
+	KindView
+	KindTrigger
+	KindRoutine
+)
+
+// DEFINER 的处理方式
+const (
+	// DefinerCurrentUser replaces the definer with CURRENT_USER(), the user loading the dump
+	DefinerCurrentUser = "current_user"
+	// DefinerKeep keeps the definer of the source server, loading requires SET_USER_ID or SUPER
+	DefinerKeep = "keep"
+	// DefinerStrip removes the DEFINER clause, the server then uses the user loading the dump
+	DefinerStrip = "strip"
+)
+
+// DefinerPolicies lists the values accepted by Transformer.Definer
+var DefinerPolicies = []string{DefinerCurrentUser, DefinerKeep, DefinerStrip}
+
+// Transformer describes how CREATE statements are rewritten before they are written to the dump,
+// the zero value only replaces definers with CURRENT_USER()
+type Transformer struct {
+	// StripAutoIncrement removes the AUTO_INCREMENT=N table option
+	StripAutoIncrement bool `yaml:"strip_auto_increment"`
+	// Engine replaces the storage engine of tables and partitions, e.g. InnoDB
+	Engine string `yaml:"engine"`
+	// StripRowFormat removes the ROW_FORMAT table option
+	StripRowFormat bool `yaml:"strip_row_format"`
+	// StripDataDirectory removes the DATA DIRECTORY and INDEX DIRECTORY options of tables and partitions
+	StripDataDirectory bool `yaml:"strip_data_directory"`
+	// StripTablespace removes the TABLESPACE options of tables and partitions
+	StripTablespace bool `yaml:"strip_tablespace"`
+	// Charsets maps character sets, e.g. utf8mb3 to utf8mb4. Collations of a mapped character set
+	// get the new prefix unless Collations maps them
+	Charsets map[string]string `yaml:"charsets"`
+	// Collations maps collations, e.g. utf8mb4_0900_ai_ci to utf8mb4_unicode_ci
+	Collations map[string]string `yaml:"collations"`
+	// Definer is current_user (the default), keep or strip and applies to views, triggers and routines
+	Definer string `yaml:"definer"`
+}
+
+var identifierPattern = regexp.MustCompile(`^\w+$`)
+
+// Validate checks the engine, the mappings and the definer policy
+func (t *Transformer) Validate() error {
+	if t.Engine != "" && !identifierPattern.MatchString(t.Engine) {
+		return fmt.Errorf("invalid engine %q", t.Engine)
+	}
+	for kind, mapping := range map[string]map[string]string{"charset": t.Charsets, "collation": t.Collations} {
+		for _, from := range sortedKeys(mapping) {
+			if !identifierPattern.MatchString(from) || !identifierPattern.MatchString(mapping[from]) {
+				return fmt.Errorf("invalid %s mapping %s=%s", kind, from, mapping[from])
+			}
+		}
+	}
+	if t.Definer != "" && t.Definer != DefinerCurrentUser && t.Definer != DefinerKeep && t.Definer != DefinerStrip {
+		return fmt.Errorf("invalid definer policy %q, must be one of %s", t.Definer, strings.Join(DefinerPolicies, ", "))
+	}
+	return nil
+}
+
+var (
+	// 表选项只在列定义之后出现，分区定义中的选项写作 ENGINE = InnoDB
+	autoIncrementPattern = regexp.MustCompile(`(?i)\s+AUTO_INCREMENT\s*=\s*\d+`)
+	enginePattern        = regexp.MustCompile(`(?i)\b(ENGINE\s*=\s*)\w+`)
+	rowFormatPattern     = regexp.MustCompile(`(?i)\s+ROW_FORMAT\s*=\s*\w+`)
+	directoryPattern     = regexp.MustCompile(`(?i)\s+(DATA|INDEX)\s+DIRECTORY\s*=\s*'(?:[^'\\]|\\.|'')*'`)
+	// MySQL 8.0 将通用表空间写在版本注释中：/*!50100 TABLESPACE `ts1` */
+	tablespaceCommentPattern = regexp.MustCompile("(?i)\\s*/\\*!50100 TABLESPACE `[^`]+`(\\s+STORAGE\\s+\\w+)?\\s*\\*/")
+	tablespacePattern        = regexp.MustCompile("(?i)\\s+TABLESPACE\\s*=?\\s*(`[^`]+`|\\w+)(\\s+STORAGE\\s+\\w+)?")
+
+	charsetPattern   = regexp.MustCompile(`(?i)\b(CHARACTER\s+SET|CHARSET)(\s*=\s*|\s+)(\w+)`)
+	collationPattern = regexp.MustCompile(`(?i)\b(COLLATE)(\s*=\s*|\s+)(\w+)`)
+	definerPattern   = regexp.MustCompile("(?i)\\s*DEFINER\\s*=\\s*(`(?:[^`]|``)*`|'(?:[^'\\\\]|\\\\.)*'|\\w+)@(`(?:[^`]|``)*`|'(?:[^'\\\\]|\\\\.)*'|[\\w.%:-]+)|\\s*DEFINER\\s*=\\s*CURRENT_USER(\\s*\\(\\))?")
+)
+
+// Transform rewrites a CREATE statement of the given kind, a nil Transformer behaves like the zero value
+func (t *Transformer) Transform(kind Kind, ddl string) string {
+	if t == nil {
+		t = new(Transformer)
+	}
+	if kind == KindTable {
+		ddl = t.transformTableOptions(ddl)
+	}
+	if len(t.Charsets) > 0 || len(t.Collations) > 0 {
+		ddl = outsideQuotes(ddl, t.mapCharsets)
+	}
+	if kind != KindDatabase && kind != KindTable {
+		ddl = t.transformDefiner(ddl)
+	}
+	return ddl
+}
+
+// transformTableOptions 只改写列定义之后的表选项和分区定义，列的默认值和注释保持不变
+func (t *Transformer) transformTableOptions(ddl string) string {
+	end := columnListEnd(ddl)
+	if end < 0 {
+		return ddl
+	}
+	head, tail := ddl[:end], ddl[end:]
+	if t.StripDataDirectory {
+		tail = directoryPattern.ReplaceAllString(tail, "")
+	}
+	if t.StripTablespace {
+		tail = tablespaceCommentPattern.ReplaceAllString(tail, "")
+		tail = tablespacePattern.ReplaceAllString(tail, "")
+	}
+	tail = outsideQuotes(tail, func(s string) string {
+		if t.StripAutoIncrement {
+			s = autoIncrementPattern.ReplaceAllString(s, "")
+		}
+		if t.StripRowFormat {
+			s = rowFormatPattern.ReplaceAllString(s, "")
+		}
+		if t.Engine != "" {
+			s = enginePattern.ReplaceAllString(s, "${1}"+t.Engine)
+		}
+		return s
+	})
+	return head + tail
+}
+
+// mapCharsets 替换字符集和排序规则，映射的字符集的排序规则同时替换前缀
+func (t *Transformer) mapCharsets(s string) string {
+	s = charsetPattern.ReplaceAllStringFunc(s, func(match string) string {
+		m := charsetPattern.FindStringSubmatch(match)
+		if to, ok := lookup(t.Charsets, m[3]); ok {
+			return m[1] + m[2] + to
+		}
+		return match
+	})
+	return collationPattern.ReplaceAllStringFunc(s, func(match string) string {
+		m := collationPattern.FindStringSubmatch(match)
+		return m[1] + m[2] + t.mapCollation(m[3])
+	})
+}
+
+func (t *Transformer) mapCollation(collation string) string {
+	if to, ok := lookup(t.Collations, collation); ok {
+		return to
+	}
+	for _, from := range sortedKeys(t.Charsets) {
+		if rest, ok := cutPrefixFold(collation, from+"_"); ok {
+			return t.Charsets[from] + "_" + rest
+		}
+	}
+	return collation
+}
+
+// transformDefiner 按 Definer 的设置改写语句开头的 DEFINER 子句
+func (t *Transformer) transformDefiner(ddl string) string {
+	loc := definerPattern.FindStringIndex(ddl)
+	if loc == nil {
+		return ddl
+	}
+	switch t.Definer {
+	case DefinerKeep:
+		return ddl
+	case DefinerStrip:
+		return ddl[:loc[0]] + ddl[loc[1]:]
+	default:
+		// 保留 DEFINER 前的空白
+		prefix := ddl[loc[0]:loc[1]]
+		prefix = prefix[:len(prefix)-len(strings.TrimLeft(prefix, " \t\n"))]
+		return ddl[:loc[0]] + prefix + "DEFINER=CURRENT_USER()" + ddl[loc[1]:]
+	}
+}
+
+// columnListEnd 返回列定义末尾的右括号的位置，跳过字符串和带引号的标识符，注释或默认值中的括号不影响结果
+func columnListEnd(ddl string) int {
+	depth := 0
+	for i := 0; i < len(ddl); i++ {
+		switch ddl[i] {
+		case '\'', '"', '`':
+			i = quotedEnd(ddl, i) - 1
+		case '(':
+			depth++
+		case ')':
+			depth--
+			if depth == 0 {
+				return i
+			}
+		}
+	}
+	return -1
+}
+
+// outsideQuotes 只对引号之外的部分调用 fn，字符串和带引号的标识符保持不变
+func outsideQuotes(s string, fn func(string) string) string {
+	var b strings.Builder
+	start := 0
+	for i := 0; i < len(s); i++ {
+		quote := s[i]
+		if quote != '\'' && quote != '"' && quote != '`' {
+			continue
+		}
+		b.WriteString(fn(s[start:i]))
+		end := quotedEnd(s, i)
+		b.WriteString(s[i:end])
+		start, i = end, end-1
+	}
+	b.WriteString(fn(s[start:]))
+	return b.String()
+}
+
+// quotedEnd 返回从 start 开始的引号内容结束之后的位置
+func quotedEnd(s string, start int) int {
+	quote := s[start]
+	end := start + 1
+	for end < len(s) {
+		if s[end] == '\\' && quote != '`' {
+			end += 2
+			continue
+		}
+		if s[end] == quote {
+			// 连续两个引号表示引号本身
+			if end+1 < len(s) && s[end+1] == quote {
+				end += 2
+				continue
+			}
+			break
+		}
+		end++
+	}
+	return min(end+1, len(s))
+}
+
+// lookup 不区分大小写地查找映射
+func lookup(mapping map[string]string, key string) (string, bool) {
+	if to, ok := mapping[key]; ok {
+		return to, true
+	}
+	for from, to := range mapping {
+		if strings.EqualFold(from, key) {
+			return to, true
+		}
+	}
+	return "", false
+}
+
+func cutPrefixFold(s, prefix string) (string, bool) {
+	if len(s) >= len(prefix) && strings.EqualFold(s[:len(prefix)], prefix) {
+		return s[len(prefix):], true
+	}
+	return s, false
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
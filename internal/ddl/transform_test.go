@@ -0,0 +1,129 @@
+package ddl
+
+import (
+	"strings"
+	"testing"
+)
+
+const tableDDL = "CREATE TABLE `orders` (\n" +
+	"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+	"  `note` varchar(64) CHARACTER SET utf8mb3 COLLATE utf8mb3_general_ci DEFAULT 'ENGINE=MyISAM AUTO_INCREMENT=5',\n" +
+	"  `total` decimal(10,2) NOT NULL COMMENT 'COLLATE utf8mb3_bin',\n" +
+	"  PRIMARY KEY (`id`)\n" +
+	") /*!50100 TABLESPACE `ts1` */ ENGINE=MyISAM AUTO_INCREMENT=123456 DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_0900_ai_ci " +
+	"ROW_FORMAT=DYNAMIC DATA DIRECTORY='/data/o''rders/' INDEX DIRECTORY='/idx/' COMMENT='keep ROW_FORMAT=FIXED'"
+
+func TestTransformTable(t *testing.T) {
+	tr := &Transformer{
+		StripAutoIncrement: true,
+		Engine:             "InnoDB",
+		StripRowFormat:     true,
+		StripDataDirectory: true,
+		StripTablespace:    true,
+		Charsets:           map[string]string{"utf8mb3": "utf8mb4"},
+		Collations:         map[string]string{"utf8mb4_0900_ai_ci": "utf8mb4_unicode_ci"},
+	}
+	want := "CREATE TABLE `orders` (\n" +
+		"  `id` bigint NOT NULL AUTO_INCREMENT,\n" +
+		"  `note` varchar(64) CHARACTER SET utf8mb4 COLLATE utf8mb4_general_ci DEFAULT 'ENGINE=MyISAM AUTO_INCREMENT=5',\n" +
+		"  `total` decimal(10,2) NOT NULL COMMENT 'COLLATE utf8mb3_bin',\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB DEFAULT CHARSET=utf8mb4 COLLATE=utf8mb4_unicode_ci COMMENT='keep ROW_FORMAT=FIXED'"
+	if got := tr.Transform(KindTable, tableDDL); got != want {
+		t.Errorf("Transform() =\n%s\nwant\n%s", got, want)
+	}
+
+	// 零值不改写表
+	if got := (&Transformer{}).Transform(KindTable, tableDDL); got != tableDDL {
+		t.Errorf("zero Transformer changed the table:\n%s", got)
+	}
+}
+
+func TestTransformTableCommentWithParen(t *testing.T) {
+	// 注释和默认值中的换行加右括号不是列定义的结尾
+	ddl := "CREATE TABLE `notes` (\n" +
+		"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+		"  `body` varchar(64) DEFAULT 'a\n) ENGINE=MyISAM' COMMENT 'see\n) AUTO_INCREMENT=7 ROW_FORMAT=FIXED',\n" +
+		"  `tag` char(1) COMMENT '(',\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=MyISAM AUTO_INCREMENT=42 ROW_FORMAT=DYNAMIC"
+	tr := &Transformer{StripAutoIncrement: true, Engine: "InnoDB", StripRowFormat: true}
+	want := "CREATE TABLE `notes` (\n" +
+		"  `id` int NOT NULL AUTO_INCREMENT,\n" +
+		"  `body` varchar(64) DEFAULT 'a\n) ENGINE=MyISAM' COMMENT 'see\n) AUTO_INCREMENT=7 ROW_FORMAT=FIXED',\n" +
+		"  `tag` char(1) COMMENT '(',\n" +
+		"  PRIMARY KEY (`id`)\n" +
+		") ENGINE=InnoDB"
+	if got := tr.Transform(KindTable, ddl); got != want {
+		t.Errorf("Transform() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTransformPartitions(t *testing.T) {
+	ddl := "CREATE TABLE `logs` (\n  `id` int NOT NULL\n) ENGINE=InnoDB DEFAULT CHARSET=latin1\n" +
+		"/*!50100 PARTITION BY RANGE (`id`)\n" +
+		"(PARTITION p0 VALUES LESS THAN (10) DATA DIRECTORY = '/disk1/' TABLESPACE = `ts1` ENGINE = InnoDB,\n" +
+		" PARTITION p1 VALUES LESS THAN MAXVALUE ENGINE = InnoDB) */"
+	tr := &Transformer{Engine: "RocksDB", StripDataDirectory: true, StripTablespace: true}
+	want := "CREATE TABLE `logs` (\n  `id` int NOT NULL\n) ENGINE=RocksDB DEFAULT CHARSET=latin1\n" +
+		"/*!50100 PARTITION BY RANGE (`id`)\n" +
+		"(PARTITION p0 VALUES LESS THAN (10) ENGINE = RocksDB,\n" +
+		" PARTITION p1 VALUES LESS THAN MAXVALUE ENGINE = RocksDB) */"
+	if got := tr.Transform(KindTable, ddl); got != want {
+		t.Errorf("Transform() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestTransformDefiner(t *testing.T) {
+	view := "CREATE OR REPLACE ALGORITHM=UNDEFINED DEFINER=`app`@`10.0.%` SQL SECURITY DEFINER VIEW `v` AS select 'DEFINER=`x`@`y`' AS `a`"
+	trigger := "CREATE DEFINER=`root`@`localhost` TRIGGER `t` BEFORE INSERT ON `orders` FOR EACH ROW SET NEW.total = 0"
+
+	tests := []struct {
+		policy string
+		kind   Kind
+		ddl    string
+		want   string
+	}{
+		{"", KindView, view, "CREATE OR REPLACE ALGORITHM=UNDEFINED DEFINER=CURRENT_USER() SQL SECURITY DEFINER VIEW `v` AS select 'DEFINER=`x`@`y`' AS `a`"},
+		{DefinerStrip, KindView, view, "CREATE OR REPLACE ALGORITHM=UNDEFINED SQL SECURITY DEFINER VIEW `v` AS select 'DEFINER=`x`@`y`' AS `a`"},
+		{DefinerKeep, KindView, view, view},
+		{DefinerCurrentUser, KindTrigger, trigger, "CREATE DEFINER=CURRENT_USER() TRIGGER `t` BEFORE INSERT ON `orders` FOR EACH ROW SET NEW.total = 0"},
+		{DefinerStrip, KindRoutine, "CREATE DEFINER='admin'@'%' PROCEDURE `p`()\nBEGIN\nEND", "CREATE PROCEDURE `p`()\nBEGIN\nEND"},
+	}
+	for _, tc := range tests {
+		tr := &Transformer{Definer: tc.policy}
+		if got := tr.Transform(tc.kind, tc.ddl); got != tc.want {
+			t.Errorf("Transform() with definer %q =\n%s\nwant\n%s", tc.policy, got, tc.want)
+		}
+	}
+
+	var nilTransformer *Transformer
+	if got := nilTransformer.Transform(KindView, view); !strings.Contains(got, "DEFINER=CURRENT_USER()") {
+		t.Errorf("nil Transformer should replace the definer: %s", got)
+	}
+}
+
+func TestTransformDatabase(t *testing.T) {
+	ddl := "CREATE DATABASE `shop` /*!40100 DEFAULT CHARACTER SET utf8mb3 COLLATE utf8mb3_unicode_ci */ /*!80016 DEFAULT ENCRYPTION='N' */"
+	tr := &Transformer{Charsets: map[string]string{"UTF8MB3": "utf8mb4"}, Engine: "InnoDB"}
+	want := "CREATE DATABASE `shop` /*!40100 DEFAULT CHARACTER SET utf8mb4 COLLATE utf8mb4_unicode_ci */ /*!80016 DEFAULT ENCRYPTION='N' */"
+	if got := tr.Transform(KindDatabase, ddl); got != want {
+		t.Errorf("Transform() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestValidate(t *testing.T) {
+	valid := &Transformer{Engine: "InnoDB", Charsets: map[string]string{"utf8mb3": "utf8mb4"}, Definer: DefinerStrip}
+	if err := valid.Validate(); err != nil {
+		t.Errorf("Validate() returned error: %v", err)
+	}
+	for _, invalid := range []*Transformer{
+		{Engine: "InnoDB; DROP"},
+		{Collations: map[string]string{"utf8mb4_0900_ai_ci": ""}},
+		{Definer: "nobody"},
+	} {
+		if err := invalid.Validate(); err == nil {
+			t.Errorf("Validate(%+v) returned no error", invalid)
+		}
+	}
+}
@@ -1,90 +1,169 @@
 package internal
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	"io"
 	"motors-backup/internal/config"
 	dbConn "motors-backup/internal/db"
+	"motors-backup/internal/ddl"
 	"motors-backup/internal/exporter"
+	"motors-backup/internal/flavor"
+	"motors-backup/internal/log"
+	"motors-backup/internal/manifest"
+	"motors-backup/internal/metrics"
+	"motors-backup/internal/retry"
 	"motors-backup/internal/schema"
 	"regexp"
 	"runtime"
 	"strings"
+	"time"
 )
 
-func DumpCreateDatabase(cfg *config.Config, database *sql.DB, withCreateDB bool) error {
-	databaseDDL, err := schema.GetDatabaseDDL(database, cfg.DBName)
+// Format 控制导出内容中可以单独关闭的部分，零值写出所有内容
+type Format struct {
+	// NoComments omits the -- comments, the trailer marking the dump as complete or incomplete
+	// and the comments marking failed objects are always written
+	NoComments bool
+	// NoSetVariables omits the session variables set in the header and restored in the footer,
+	// and the character_set_client switches around CREATE statements
+	NoSetVariables bool
+	// DropDatabase writes DROP DATABASE IF EXISTS before the CREATE DATABASE statement
+	DropDatabase bool
+	// NoDropTable omits DROP TABLE IF EXISTS before the CREATE TABLE statement
+	NoDropTable bool
+	// IfNotExists writes CREATE TABLE IF NOT EXISTS so that the data is merged into an existing table
+	IfNotExists bool
+	// DDL rewrites the CREATE statements read from the server, nil only replaces view definers
+	DDL *ddl.Transformer
+}
+
+// printComment 写出 -- 注释块，关闭注释时不写出
+func (f Format) printComment(w io.Writer, format string, args ...any) {
+	if f.NoComments {
+		return
+	}
+	fmt.Fprintln(w, "--")
+	fmt.Fprintf(w, "-- "+format+"\n", args...)
+	fmt.Fprintln(w, "--")
+}
+
+func DumpCreateDatabase(ctx context.Context, w io.Writer, cfg *config.Config, database dbConn.Querier, server flavor.Server, withCreateDB bool, format Format) error {
+	databaseDDL, err := schema.GetDatabaseDDL(ctx, database, cfg.DBName)
 	if err != nil {
 		return fmt.Errorf("failed to get database DDL: %w", err)
 	}
-	fmt.Println("--")
-	fmt.Printf("-- Current Database: `%s`\n", cfg.DBName)
-	fmt.Println("--")
+	format.printComment(w, "Current Database: `%s`", cfg.DBName)
+	if format.DropDatabase {
+		fmt.Fprintf(w, "\n/*!40000 DROP DATABASE IF EXISTS `%s`*/;\n", cfg.DBName)
+	}
 	if withCreateDB {
-		fmt.Printf("\n%s;\n", strings.Replace(databaseDDL, "CREATE DATABASE", "CREATE DATABASE /*!32312 IF NOT EXISTS*/", 1))
+		databaseDDL = server.GateVersionComments(format.DDL.Transform(ddl.KindDatabase, databaseDDL))
+		fmt.Fprintf(w, "\n%s;\n", strings.Replace(databaseDDL, "CREATE DATABASE", "CREATE DATABASE /*!32312 IF NOT EXISTS*/", 1))
 	}
-	fmt.Printf("\nUSE `%s`;\n\n", cfg.DBName)
+	fmt.Fprintf(w, "\nUSE `%s`;\n\n", cfg.DBName)
 
 	return nil
 }
 
-func DumpTableStructure(cfg *config.Config, database *sql.DB, tableName string) error {
-	tableDDL, err := schema.GetTableDDL(database, cfg.DBName, tableName)
+func DumpTableStructure(ctx context.Context, w io.Writer, cfg *config.Config, database dbConn.Querier, server flavor.Server, tableName string, format Format) error {
+	tableDDL, err := schema.GetTableDDL(ctx, database, cfg.DBName, tableName)
 	if err != nil {
 		return fmt.Errorf("failed to get table DDL: %w", err)
 	}
-	fmt.Println("--")
-	fmt.Printf("-- Table structure for table `%s`\n", tableName)
-	fmt.Println("--")
-	fmt.Println("")
-	fmt.Println("")
-	fmt.Printf("DROP TABLE IF EXISTS `%s`;\n", tableName)
-	fmt.Println("/*!40101 SET @saved_cs_client     = @@character_set_client */;")
-	fmt.Println("/*!40101 SET character_set_client = utf8mb4 */;")
-	fmt.Printf("%s;\n", tableDDL)
-	fmt.Printf("/*!40101 SET character_set_client = @saved_cs_client */;\n\n")
-	return nil
-}
-
-// DumpTable dumps the specified table data as SQL INSERT statements
-func DumpTable(cfg *config.Config, database *sql.DB, tableName string, whereClause string) error {
-
-	// 获取MySQL服务器信息
-	mysqlInfo, err := getMySQLInfo(database)
-	if err != nil {
-		return fmt.Errorf("failed to get MySQL info: %w", err)
+	format.printComment(w, "Table structure for table `%s`", tableName)
+	if !format.NoComments {
+		fmt.Fprintln(w, "")
+		fmt.Fprintln(w, "")
 	}
-
-	// 检查MySQL版本兼容性
-	if err := checkMySQLVersionCompatibility(mysqlInfo.Version); err != nil {
-		return err
+	if !format.NoDropTable {
+		fmt.Fprintf(w, "DROP TABLE IF EXISTS `%s`;\n", tableName)
+	}
+	tableDDL = format.DDL.Transform(ddl.KindTable, tableDDL)
+	if format.IfNotExists {
+		tableDDL = strings.Replace(tableDDL, "CREATE TABLE", "CREATE TABLE IF NOT EXISTS", 1)
+	}
+	if !format.NoSetVariables {
+		fmt.Fprintln(w, "/*!40101 SET @saved_cs_client     = @@character_set_client */;")
+		fmt.Fprintln(w, "/*!40101 SET character_set_client = utf8mb4 */;")
+	}
+	fmt.Fprintf(w, "%s;\n", server.GateVersionComments(tableDDL))
+	if !format.NoSetVariables {
+		fmt.Fprintf(w, "/*!40101 SET character_set_client = @saved_cs_client */;\n")
 	}
+	fmt.Fprintln(w)
+	return nil
+}
 
-	// 分析列结构，识别虚拟列
-	columns, err := schema.AnalyzeColumns(database, cfg.DBName, tableName)
+// DumpTable dumps the specified table data as SQL INSERT statements and returns the number of rows dumped
+func DumpTable(ctx context.Context, w io.Writer, cfg *config.Config, database dbConn.Querier, tableName string, opts exporter.Options) (rows int64, err error) {
+	start := time.Now()
+	defer func() {
+		if err != nil {
+			err = NewStageError(metrics.StageTableData, cfg.DBName, tableName, err)
+			return
+		}
+		duration := time.Since(start)
+		metrics.ObserveTable(cfg.DBName, tableName, rows, duration)
+		log.Logger.Debug("table data exported",
+			log.KeyStage, metrics.StageTableData,
+			log.KeyDatabase, cfg.DBName,
+			log.KeyTable, tableName,
+			log.KeyRows, rows,
+			log.Duration(duration))
+	}()
+
+	// 查询表结构时的临时错误直接重试，此时尚未写出任何内容
+	var columns []schema.Column
+	var keyColumns []string
+	err = opts.Retry.Do(ctx, func() error {
+		// 获取MySQL服务器信息
+		mysqlInfo, err := getMySQLInfo(ctx, database)
+		if err != nil {
+			return fmt.Errorf("failed to get MySQL info: %w", err)
+		}
+
+		// 检查MySQL版本兼容性
+		if err := mysqlInfo.Server.Check(); err != nil {
+			return err
+		}
+
+		// 分析列结构，识别虚拟列
+		columns, err = schema.AnalyzeColumns(ctx, database, mysqlInfo.Server, cfg.DBName, tableName)
+		if err != nil {
+			return fmt.Errorf("failed to analyze columns: %w", err)
+		}
+
+		// 主键用于重试时从最后导出的行继续，upsert 时不更新主键列
+		opts.UpsertAlias = mysqlInfo.Server.SupportsRowAlias()
+		if opts.Retry.Retries > 0 || opts.InsertMode == exporter.InsertModeUpsert {
+			keyColumns, err = schema.GetPrimaryKey(ctx, database, cfg.DBName, tableName)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}, opts.OnRetry, log.KeyStage, metrics.StageTableData, log.KeyTable, tableName)
 	if err != nil {
-		return fmt.Errorf("failed to analyze columns: %w", err)
+		return 0, err
 	}
 
 	// 获取非虚拟列列表
 	nonGeneratedColumns := schema.GetNonGeneratedColumns(columns)
 	if len(nonGeneratedColumns) == 0 {
-		return fmt.Errorf("no non-generated columns found in %s", tableName)
+		return 0, fmt.Errorf("no non-generated columns found in %s", tableName)
 	}
+	opts.KeyColumns = keyColumns
+	opts.BoolColumns = schema.GetBoolColumns(columns)
 
 	// 导出数据
-	err = exporter.ExportData(database, tableName, nonGeneratedColumns, whereClause)
+	rows, err = exporter.ExportData(ctx, w, database, tableName, nonGeneratedColumns, opts)
 	if err != nil {
-		return fmt.Errorf("failed to export data: %w", err)
+		return rows, fmt.Errorf("failed to export data: %w", err)
 	}
 
-	return nil
-}
-
-func ReplaceDDLDefinerWithCurrentUser(ddl string) string {
-	// 替换 DEFINER=...
-	re := regexp.MustCompile(`DEFINER=[^ ]+`)
-	return re.ReplaceAllString(ddl, "DEFINER=CURRENT_USER()")
+	return rows, nil
 }
 
 func ReplaceViewDDLASReplace(ddl string) string {
@@ -93,75 +172,167 @@ func ReplaceViewDDLASReplace(ddl string) string {
 	return re.ReplaceAllString(ddl, "CREATE OR REPLACE ALGORITHM")
 }
 
-func DumpViews(cfg *config.Config, database *sql.DB) error {
-	viewDDLs, err := schema.AllViewDDL(database)
+func DumpViews(ctx context.Context, w io.Writer, cfg *config.Config, database dbConn.Querier, server flavor.Server, format Format) error {
+	views, err := schema.ListViews(ctx, database)
 	if err != nil {
 		return fmt.Errorf("failed to get view DDL: %w", err)
 	}
-	for _, viewDDL := range viewDDLs {
-		fmt.Printf("\n--\n-- Temporary table structure for view `%s`\n--\n\n", viewDDL.Name)
-		fmt.Printf("/*!50001 DROP VIEW IF EXISTS `%s`*/;\n", viewDDL.Name)
-		fmt.Println("SET @saved_cs_client     = @@character_set_client;")
-		fmt.Println("SET character_set_client = utf8mb4;")
-		fmt.Printf("%s;\n", ReplaceDDLDefinerWithCurrentUser(ReplaceViewDDLASReplace(viewDDL.DDL)))
-		fmt.Printf("SET character_set_client = @saved_cs_client;\n")
+	for _, view := range views {
+		if err := DumpView(ctx, w, cfg, database, server, view, format); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// DumpView writes the DDL of a single view, nothing is written when its DDL can not be read
+func DumpView(ctx context.Context, w io.Writer, cfg *config.Config, database dbConn.Querier, server flavor.Server, view string, format Format) error {
+	viewDDL, err := schema.GetViewDDL(ctx, database, view)
+	if err != nil {
+		return fmt.Errorf("failed to get view DDL: %w", err)
+	}
+	if !format.NoComments {
+		fmt.Fprintln(w)
+		format.printComment(w, "Temporary table structure for view `%s`", viewDDL.Name)
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "/*!50001 DROP VIEW IF EXISTS `%s`*/;\n", viewDDL.Name)
+	if !format.NoSetVariables {
+		fmt.Fprintln(w, "SET @saved_cs_client     = @@character_set_client;")
+		fmt.Fprintln(w, "SET character_set_client = utf8mb4;")
+	}
+	fmt.Fprintf(w, "%s;\n", server.GateVersionComments(format.DDL.Transform(ddl.KindView, ReplaceViewDDLASReplace(viewDDL.DDL))))
+	if !format.NoSetVariables {
+		fmt.Fprintf(w, "SET character_set_client = @saved_cs_client;\n")
+	}
+	return nil
+}
+
+// DumpTrigger writes the CREATE TRIGGER statement of a single trigger, nothing is written when its DDL can not be read
+func DumpTrigger(ctx context.Context, w io.Writer, cfg *config.Config, database dbConn.Querier, trigger string, format Format) error {
+	info, err := schema.GetTriggerDDL(ctx, database, cfg.DBName, trigger)
+	if err != nil {
+		return fmt.Errorf("failed to get trigger DDL: %w", err)
 	}
+	printStoredProgram(w, format.DDL.Transform(ddl.KindTrigger, info.DDL), info.SQL_MODE, format)
+	return nil
+}
 
+// DumpRoutine writes the CREATE statement of a stored procedure or function, nothing is written when its DDL can not be read
+func DumpRoutine(ctx context.Context, w io.Writer, cfg *config.Config, database dbConn.Querier, routine schema.Routine, format Format) error {
+	info, err := schema.GetRoutineDDL(ctx, database, cfg.DBName, routine)
+	if err != nil {
+		return fmt.Errorf("failed to get routine DDL: %w", err)
+	}
+	if !format.NoComments {
+		fmt.Fprintln(w)
+		format.printComment(w, "Structure for %s `%s`", strings.ToLower(info.Type), info.Name)
+		fmt.Fprintln(w)
+	}
+	if !format.NoDropTable {
+		fmt.Fprintf(w, "/*!50003 DROP %s IF EXISTS `%s` */;\n", info.Type, strings.ReplaceAll(info.Name, "`", "``"))
+	}
+	printStoredProgram(w, format.DDL.Transform(ddl.KindRoutine, info.DDL), info.SQLMode, format)
 	return nil
 }
 
+// printStoredProgram 以 ;; 为分隔符写出触发器或存储过程，创建时使用原来的 sql_mode，其中的语句才能按原样解析
+func printStoredProgram(w io.Writer, createDDL, sqlMode string, format Format) {
+	if !format.NoSetVariables {
+		fmt.Fprintln(w, "SET @saved_cs_client     = @@character_set_client;")
+		fmt.Fprintln(w, "SET character_set_client = utf8mb4;")
+	}
+	fmt.Fprintln(w, "/*!50003 SET @saved_sql_mode = @@sql_mode */;")
+	fmt.Fprintf(w, "/*!50003 SET sql_mode = %s */;\n", exporter.EscapeSQLString(sqlMode))
+	fmt.Fprintln(w, "DELIMITER ;;")
+	fmt.Fprintf(w, "%s ;;\n", createDDL)
+	fmt.Fprintln(w, "DELIMITER ;")
+	fmt.Fprintln(w, "/*!50003 SET sql_mode = @saved_sql_mode */;")
+	if !format.NoSetVariables {
+		fmt.Fprintln(w, "SET character_set_client = @saved_cs_client;")
+	}
+}
+
+// PrintObjectFailed writes a comment marking an object that could not be exported with --continue-on-error
+func PrintObjectFailed(w io.Writer, kind, name string, err error) {
+	message := strings.ReplaceAll(err.Error(), "\n", " ")
+	fmt.Fprintln(w, "--")
+	fmt.Fprintf(w, "-- FAILED: %s `%s` was not exported: %s\n", strings.ReplaceAll(kind, "_", " "), name, message)
+	fmt.Fprintln(w, "--")
+	fmt.Fprintln(w)
+}
+
+// Version is the tool version written into dump headers and manifests
+var Version = "0.1"
+
 // MySQLInfo 存储MySQL服务器信息
 type MySQLInfo struct {
 	Version  string
 	Charset  string
 	Timezone string
+	// Server is the flavor and version detected from VERSION() and @@version_comment
+	Server flavor.Server
 }
 
-func StartExport(cfg *config.Config, worker func(database *sql.DB, info *MySQLInfo) error) error {
+// StartExport connects to the database and runs worker, the connection is retried on transient errors according to policy
+func StartExport(ctx context.Context, cfg *config.Config, policy retry.Policy, worker func(database *sql.DB, info *MySQLInfo) error) error {
 	// 检查必需的DB_NAME配置
 	if cfg.DBName == "" {
 		return fmt.Errorf("DB_NAME environment variable is required")
 	}
 
 	// 建立数据库连接
-	database, err := dbConn.Connect(cfg)
+	var database *sql.DB
+	err := policy.Do(ctx, func() (err error) {
+		database, err = dbConn.Connect(ctx, cfg)
+		return retry.ConnectError(err)
+	}, func(error) {
+		metrics.ObserveRetry(cfg.DBName, metrics.StageConnect)
+	}, log.KeyStage, metrics.StageConnect, log.KeyDatabase, cfg.DBName)
 	if err != nil {
-		return fmt.Errorf("failed to connect to database: %w", err)
+		return NewStageError(metrics.StageConnect, cfg.DBName, "", fmt.Errorf("failed to connect to database: %w", err))
 	}
 	defer dbConn.Close(database)
 
+	return RunExport(ctx, cfg, database, worker)
+}
+
+// RunExport checks the server behind an open connection pool and runs worker, cfg.DBName must be
+// the default database of the pool
+func RunExport(ctx context.Context, cfg *config.Config, database *sql.DB, worker func(database *sql.DB, info *MySQLInfo) error) error {
 	// 获取MySQL服务器信息
-	mysqlInfo, err := getMySQLInfo(database)
+	mysqlInfo, err := getMySQLInfo(ctx, database)
 	if err != nil {
-		return fmt.Errorf("failed to get MySQL info: %w", err)
+		return NewStageError(metrics.StageServerInfo, cfg.DBName, "", fmt.Errorf("failed to get MySQL info: %w", err))
 	}
 
 	// 检查MySQL版本兼容性
-	if err := checkMySQLVersionCompatibility(mysqlInfo.Version); err != nil {
-		return err
+	if err := mysqlInfo.Server.Check(); err != nil {
+		return NewStageError(metrics.StageServerInfo, cfg.DBName, "", err)
 	}
 
 	return worker(database, mysqlInfo)
 }
 
 // getMySQLInfo 获取MySQL服务器的版本、字符集和时区信息
-func getMySQLInfo(database *sql.DB) (*MySQLInfo, error) {
-	var version, charset, timezone string
+func getMySQLInfo(ctx context.Context, database dbConn.Querier) (*MySQLInfo, error) {
+	var version, versionComment, charset, timezone string
 
-	// 获取版本信息
-	row := database.QueryRow("SELECT VERSION()")
-	if err := row.Scan(&version); err != nil {
+	// 获取版本信息，version_comment 用于区分 MySQL、MariaDB 和 Percona Server
+	row := database.QueryRowContext(ctx, "SELECT VERSION(), @@version_comment")
+	if err := row.Scan(&version, &versionComment); err != nil {
 		return nil, fmt.Errorf("failed to get MySQL version: %w", err)
 	}
 
 	// 获取字符集信息
-	row = database.QueryRow("SELECT @@character_set_server")
+	row = database.QueryRowContext(ctx, "SELECT @@character_set_server")
 	if err := row.Scan(&charset); err != nil {
 		return nil, fmt.Errorf("failed to get MySQL charset: %w", err)
 	}
 
 	// 获取时区信息
-	row = database.QueryRow("SELECT TIME_FORMAT(TIMEDIFF(NOW(), UTC_TIMESTAMP()), '%H:%i') AS timezone_offset;")
+	row = database.QueryRowContext(ctx, "SELECT TIME_FORMAT(TIMEDIFF(NOW(), UTC_TIMESTAMP()), '%H:%i') AS timezone_offset;")
 	if err := row.Scan(&timezone); err != nil {
 		return nil, fmt.Errorf("failed to get MySQL timezone: %w", err)
 	}
@@ -174,59 +345,62 @@ func getMySQLInfo(database *sql.DB) (*MySQLInfo, error) {
 		Version:  version,
 		Charset:  charset,
 		Timezone: timezone,
+		Server:   flavor.Parse(version, versionComment),
 	}, nil
 }
 
-// checkMySQLVersionCompatibility 检查MySQL版本是否兼容（>= 8.0）
-func checkMySQLVersionCompatibility(version string) error {
-	// 简单检查版本号是否以8.0或更高版本开头
-	if strings.HasPrefix(version, "8.") || strings.HasPrefix(version, "9.") {
-		return nil
-	}
-
-	// 如果版本号小于8.0，则返回错误
-	if strings.HasPrefix(version, "5.") || strings.HasPrefix(version, "6.") || strings.HasPrefix(version, "7.") {
-		return fmt.Errorf("incompatible MySQL version: %s. Required version >= 8.0", version)
-	}
-
-	// 对于其他格式的版本号，假定为兼容
-	return nil
-}
-
 // PrintEnvironmentSettings outputs basic MySQL environment settings
-func PrintEnvironmentSettings(cfg *config.Config, mysqlInfo *MySQLInfo) {
+func PrintEnvironmentSettings(w io.Writer, cfg *config.Config, mysqlInfo *MySQLInfo, format Format) {
 	// 獲取 golang runtime 執行環境arc
 	arch := runtime.GOARCH
 	os := runtime.GOOS
 
-	fmt.Printf("-- MOTORS_BACKUP 0.1  Distrib 8.0.x, for %s (%s)\n", os, arch)
-	fmt.Println("--")
-	fmt.Printf("-- Host: %s    Database: %s\n", cfg.DBHost, cfg.DBName)
-	fmt.Println("-- ------------------------------------------------------")
-	fmt.Printf("-- Server version	%s\n", mysqlInfo.Version)
-	fmt.Println()
-	fmt.Println("/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;")
-	fmt.Println("/*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;")
-	fmt.Println("/*!40101 SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION */;")
-	fmt.Println("/*!50503 SET NAMES utf8mb4 */;")
-	fmt.Println("/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;")
-	fmt.Printf("/*!40103 SET TIME_ZONE='%s' */;\n", mysqlInfo.Timezone)
-	fmt.Println("/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;")
-	fmt.Println("/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;")
-	fmt.Println("/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;")
-	fmt.Println("/*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;")
-	fmt.Println()
+	if !format.NoComments {
+		fmt.Fprintf(w, "-- MOTORS_BACKUP %s  Distrib 8.0.x, for %s (%s)\n", Version, os, arch)
+		fmt.Fprintln(w, "--")
+		fmt.Fprintf(w, "-- Host: %s    Database: %s\n", cfg.DBHost, cfg.DBName)
+		fmt.Fprintln(w, "-- ------------------------------------------------------")
+		fmt.Fprintf(w, "-- Server version	%s\n", mysqlInfo.Version)
+		fmt.Fprintln(w)
+	}
+	if format.NoSetVariables {
+		return
+	}
+	fmt.Fprintln(w, "/*!40101 SET @OLD_CHARACTER_SET_CLIENT=@@CHARACTER_SET_CLIENT */;")
+	fmt.Fprintln(w, "/*!40101 SET @OLD_CHARACTER_SET_RESULTS=@@CHARACTER_SET_RESULTS */;")
+	fmt.Fprintln(w, "/*!40101 SET @OLD_COLLATION_CONNECTION=@@COLLATION_CONNECTION */;")
+	fmt.Fprintln(w, "/*!50503 SET NAMES utf8mb4 */;")
+	fmt.Fprintln(w, "/*!40103 SET @OLD_TIME_ZONE=@@TIME_ZONE */;")
+	fmt.Fprintf(w, "/*!40103 SET TIME_ZONE='%s' */;\n", mysqlInfo.Timezone)
+	fmt.Fprintln(w, "/*!40014 SET @OLD_UNIQUE_CHECKS=@@UNIQUE_CHECKS, UNIQUE_CHECKS=0 */;")
+	fmt.Fprintln(w, "/*!40014 SET @OLD_FOREIGN_KEY_CHECKS=@@FOREIGN_KEY_CHECKS, FOREIGN_KEY_CHECKS=0 */;")
+	fmt.Fprintln(w, "/*!40101 SET @OLD_SQL_MODE=@@SQL_MODE, SQL_MODE='NO_AUTO_VALUE_ON_ZERO' */;")
+	fmt.Fprintln(w, "/*!40111 SET @OLD_SQL_NOTES=@@SQL_NOTES, SQL_NOTES=0 */;")
+	fmt.Fprintln(w)
 }
 
 // PrintRestoreConnectionSettings outputs statements to restore connection settings
-func PrintRestoreConnectionSettings() {
-	fmt.Println("/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;")
-	fmt.Println("/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;")
-	fmt.Println("/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;")
-	fmt.Println("/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;")
-	fmt.Println("/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;")
-	fmt.Println("/*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;")
-	fmt.Println("/*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;")
-	fmt.Println("/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;")
-	fmt.Println()
+func PrintRestoreConnectionSettings(w io.Writer, format Format) {
+	if format.NoSetVariables {
+		return
+	}
+	fmt.Fprintln(w, "/*!40101 SET SQL_MODE=@OLD_SQL_MODE */;")
+	fmt.Fprintln(w, "/*!40014 SET FOREIGN_KEY_CHECKS=@OLD_FOREIGN_KEY_CHECKS */;")
+	fmt.Fprintln(w, "/*!40014 SET UNIQUE_CHECKS=@OLD_UNIQUE_CHECKS */;")
+	fmt.Fprintln(w, "/*!40103 SET TIME_ZONE=@OLD_TIME_ZONE */;")
+	fmt.Fprintln(w, "/*!40101 SET CHARACTER_SET_CLIENT=@OLD_CHARACTER_SET_CLIENT */;")
+	fmt.Fprintln(w, "/*!40101 SET CHARACTER_SET_RESULTS=@OLD_CHARACTER_SET_RESULTS */;")
+	fmt.Fprintln(w, "/*!40101 SET COLLATION_CONNECTION=@OLD_COLLATION_CONNECTION */;")
+	fmt.Fprintln(w, "/*!40111 SET SQL_NOTES=@OLD_SQL_NOTES */;")
+	fmt.Fprintln(w)
+}
+
+// PrintDumpCompleted outputs the trailer comment marking the dump as complete
+func PrintDumpCompleted(w io.Writer) {
+	fmt.Fprintf(w, "%s%s\n", manifest.CompletedMarker, time.Now().Format("2006-01-02 15:04:05"))
+}
+
+// PrintDumpIncomplete outputs the trailer comment marking a dump that was interrupted or failed
+func PrintDumpIncomplete(w io.Writer, reason error) {
+	fmt.Fprintf(w, "\n%s%s\n", manifest.IncompleteMarker, strings.ReplaceAll(reason.Error(), "\n", " "))
 }
@@ -1,9 +1,16 @@
 package internal
 
 import (
+	"bytes"
+	"context"
 	"database/sql"
+	"errors"
 	"motors-backup/internal/config"
+	"motors-backup/internal/ddl"
+	"motors-backup/internal/exporter"
+	"motors-backup/internal/retry"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -14,8 +21,9 @@ func TestDumpCreateDatabase(t *testing.T) {
 		t.Skip("Skipping integration test: DB_HOST not set")
 	}
 
-	err := StartExport(cfg, func(database *sql.DB, info *MySQLInfo) error {
-		err := DumpCreateDatabase(cfg, database, true)
+	ctx := context.Background()
+	err := StartExport(ctx, cfg, retry.Policy{}, func(database *sql.DB, info *MySQLInfo) error {
+		err := DumpCreateDatabase(ctx, os.Stdout, cfg, database, info.Server, true, Format{})
 		if err != nil {
 			t.Errorf("DumpCreateDatabase failed: %v", err)
 		}
@@ -41,8 +49,9 @@ func TestDumpTable(t *testing.T) {
 		t.Skip("Skipping integration test: TEST_TABLE not set")
 	}
 
-	err := StartExport(cfg, func(database *sql.DB, info *MySQLInfo) error {
-		err := DumpTable(cfg, database, testTableName, "")
+	ctx := context.Background()
+	err := StartExport(ctx, cfg, retry.Policy{}, func(database *sql.DB, info *MySQLInfo) error {
+		_, err := DumpTable(ctx, os.Stdout, cfg, database, testTableName, exporter.Options{})
 		if err != nil {
 			t.Errorf("DumpTable failed: %v", err)
 		}
@@ -57,8 +66,9 @@ func TestDumpTable(t *testing.T) {
 
 func TestDumpViews(t *testing.T) {
 	cfg := config.LoadTestConfig()
-	err := StartExport(cfg, func(database *sql.DB, info *MySQLInfo) error {
-		err := DumpViews(cfg, database)
+	ctx := context.Background()
+	err := StartExport(ctx, cfg, retry.Policy{}, func(database *sql.DB, info *MySQLInfo) error {
+		err := DumpViews(ctx, os.Stdout, cfg, database, info.Server, Format{})
 		if err != nil {
 			t.Errorf("DumpViews failed: %v", err)
 		}
@@ -78,8 +88,9 @@ func TestDumpTableStructure(t *testing.T) {
 		t.Skip("Skipping integration test: TEST_TABLE not set")
 	}
 
-	err := StartExport(cfg, func(database *sql.DB, info *MySQLInfo) error {
-		err := DumpTableStructure(cfg, database, testTableName)
+	ctx := context.Background()
+	err := StartExport(ctx, cfg, retry.Policy{}, func(database *sql.DB, info *MySQLInfo) error {
+		err := DumpTableStructure(ctx, os.Stdout, cfg, database, info.Server, testTableName, Format{})
 		if err != nil {
 			t.Errorf("DumpTableStructure failed: %v", err)
 		}
@@ -99,8 +110,9 @@ func TestDumpTableWithInvalidTable(t *testing.T) {
 		t.Skip("Skipping integration test: DB_HOST not set")
 	}
 
-	err := StartExport(cfg, func(database *sql.DB, info *MySQLInfo) error {
-		err := DumpTable(cfg, database, "non_existent_table", "")
+	ctx := context.Background()
+	err := StartExport(ctx, cfg, retry.Policy{}, func(database *sql.DB, info *MySQLInfo) error {
+		_, err := DumpTable(ctx, os.Stdout, cfg, database, "non_existent_table", exporter.Options{})
 		if err != nil {
 			t.Errorf("DumpTable failed: %v", err)
 		}
@@ -115,10 +127,11 @@ func TestDumpTableWithInvalidTable(t *testing.T) {
 
 func TestPrintEnvironmentSettings(t *testing.T) {
 	cfg := config.LoadTestConfig()
-	err := StartExport(cfg, func(database *sql.DB, info *MySQLInfo) error {
+	ctx := context.Background()
+	err := StartExport(ctx, cfg, retry.Policy{}, func(database *sql.DB, info *MySQLInfo) error {
 
-		PrintEnvironmentSettings(cfg, info)
-		PrintRestoreConnectionSettings()
+		PrintEnvironmentSettings(os.Stdout, cfg, info, Format{})
+		PrintRestoreConnectionSettings(os.Stdout, Format{})
 
 		return nil
 	})
@@ -127,3 +140,79 @@ func TestPrintEnvironmentSettings(t *testing.T) {
 		t.Errorf("StartExport failed: %v", err)
 	}
 }
+
+func TestPrintEnvironmentSettingsFormat(t *testing.T) {
+	cfg := &config.Config{DBHost: "db", DBName: "shop"}
+	info := &MySQLInfo{Version: "8.0.36", Timezone: "+00:00"}
+
+	tests := []struct {
+		name                   string
+		format                 Format
+		wantComments, wantSets bool
+	}{
+		{name: "default", wantComments: true, wantSets: true},
+		{name: "no comments", format: Format{NoComments: true}, wantSets: true},
+		{name: "compact", format: Format{NoComments: true, NoSetVariables: true}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			PrintEnvironmentSettings(&buf, cfg, info, tc.format)
+			PrintRestoreConnectionSettings(&buf, tc.format)
+			out := buf.String()
+			if got := strings.Contains(out, "-- Host: db    Database: shop"); got != tc.wantComments {
+				t.Errorf("header comments written = %v, want %v:\n%s", got, tc.wantComments, out)
+			}
+			if got := strings.Contains(out, "SET NAMES utf8mb4") && strings.Contains(out, "SET SQL_MODE=@OLD_SQL_MODE"); got != tc.wantSets {
+				t.Errorf("session variables written = %v, want %v:\n%s", got, tc.wantSets, out)
+			}
+			if !tc.wantComments && !tc.wantSets && out != "" {
+				t.Errorf("compact output = %q, want nothing", out)
+			}
+		})
+	}
+}
+
+func TestPrintObjectFailed(t *testing.T) {
+	var buf bytes.Buffer
+	PrintObjectFailed(&buf, "table_data", "orders", errors.New("lock wait timeout\nexceeded"))
+
+	want := "--\n-- FAILED: table data `orders` was not exported: lock wait timeout exceeded\n--\n\n"
+	if buf.String() != want {
+		t.Errorf("PrintObjectFailed() = %q, want %q", buf.String(), want)
+	}
+}
+
+func TestPrintStoredProgram(t *testing.T) {
+	trigger := "CREATE DEFINER=`root`@`localhost` TRIGGER `orders_total` BEFORE INSERT ON `orders` FOR EACH ROW BEGIN\n  SET NEW.total = NEW.price * NEW.quantity;\nEND"
+	format := Format{DDL: &ddl.Transformer{Definer: ddl.DefinerStrip}}
+
+	var buf bytes.Buffer
+	printStoredProgram(&buf, format.DDL.Transform(ddl.KindTrigger, trigger), "STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION", format)
+	want := "SET @saved_cs_client     = @@character_set_client;\n" +
+		"SET character_set_client = utf8mb4;\n" +
+		"/*!50003 SET @saved_sql_mode = @@sql_mode */;\n" +
+		"/*!50003 SET sql_mode = 'STRICT_TRANS_TABLES,NO_ENGINE_SUBSTITUTION' */;\n" +
+		"DELIMITER ;;\n" +
+		"CREATE TRIGGER `orders_total` BEFORE INSERT ON `orders` FOR EACH ROW BEGIN\n  SET NEW.total = NEW.price * NEW.quantity;\nEND ;;\n" +
+		"DELIMITER ;\n" +
+		"/*!50003 SET sql_mode = @saved_sql_mode */;\n" +
+		"SET character_set_client = @saved_cs_client;\n"
+	if buf.String() != want {
+		t.Errorf("printStoredProgram() =\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// --compact 省略字符集变量，sql_mode 仍然需要
+	buf.Reset()
+	routine := "CREATE DEFINER=`admin`@`%` FUNCTION `net`(price DECIMAL(10,2)) RETURNS decimal(10,2)\n    DETERMINISTIC\nRETURN price / 1.2"
+	printStoredProgram(&buf, (*ddl.Transformer)(nil).Transform(ddl.KindRoutine, routine), "", Format{NoSetVariables: true})
+	want = "/*!50003 SET @saved_sql_mode = @@sql_mode */;\n" +
+		"/*!50003 SET sql_mode = '' */;\n" +
+		"DELIMITER ;;\n" +
+		"CREATE DEFINER=CURRENT_USER() FUNCTION `net`(price DECIMAL(10,2)) RETURNS decimal(10,2)\n    DETERMINISTIC\nRETURN price / 1.2 ;;\n" +
+		"DELIMITER ;\n" +
+		"/*!50003 SET sql_mode = @saved_sql_mode */;\n"
+	if buf.String() != want {
+		t.Errorf("printStoredProgram() =\n%s\nwant\n%s", buf.String(), want)
+	}
+}
@@ -0,0 +1,41 @@
+package internal
+
+import (
+	"log/slog"
+	"motors-backup/internal/log"
+	"motors-backup/internal/metrics"
+)
+
+// StageError 记录错误发生的阶段、数据库和表，供结构化日志和指标使用
+type StageError struct {
+	Stage    string
+	Database string
+	Table    string
+	Err      error
+}
+
+// NewStageError wraps err with the stage it happened in and counts it in the error metrics
+func NewStageError(stage, database, table string, err error) *StageError {
+	metrics.ObserveError(database, stage)
+	return &StageError{Stage: stage, Database: database, Table: table, Err: err}
+}
+
+func (e *StageError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *StageError) Unwrap() error {
+	return e.Err
+}
+
+// LogAttrs returns the structured fields of the error
+func (e *StageError) LogAttrs() []slog.Attr {
+	attrs := []slog.Attr{
+		slog.String(log.KeyStage, e.Stage),
+		slog.String(log.KeyDatabase, e.Database),
+	}
+	if e.Table != "" {
+		attrs = append(attrs, slog.String(log.KeyTable, e.Table))
+	}
+	return attrs
+}
@@ -1,22 +1,267 @@
 package exporter
 
 import (
+	"context"
 	"database/sql"
+	"encoding/hex"
 	"fmt"
+	"io"
+	dbConn "motors-backup/internal/db"
+	"motors-backup/internal/log"
+	"motors-backup/internal/retry"
+	"slices"
+	"strconv"
 	"strings"
 )
 
-// ExportData exports table data as INSERT statements
-func ExportData(db *sql.DB, tableName string, columns []string, whereClause string) error {
+// INSERT 语句的写法，见 Options.InsertMode
+const (
+	InsertModeInsert  = "insert"
+	InsertModeReplace = "replace"
+	InsertModeIgnore  = "ignore"
+	InsertModeUpsert  = "upsert"
+)
+
+// InsertModes lists the values accepted by Options.InsertMode
+var InsertModes = []string{InsertModeInsert, InsertModeReplace, InsertModeIgnore, InsertModeUpsert}
+
+// 表数据的输出格式，见 Options.Format
+const (
+	FormatSQL = "sql"
+	FormatCSV = "csv"
+	FormatTSV = "tsv"
+	// FormatJSONL 即 JSON Lines（NDJSON），每行一个 JSON 对象
+	FormatJSONL = "jsonl"
+)
+
+// Formats lists the values accepted by Options.Format
+var Formats = []string{FormatSQL, FormatCSV, FormatTSV, FormatJSONL}
+
+// Options 控制表数据的导出方式
+type Options struct {
+	// Where 为查询表数据时的 WHERE 条件
+	Where string
+	// Format writes the rows as SQL statements, CSV, TSV or JSON Lines, the empty string is FormatSQL
+	Format string
+	// Delimited describes the CSV and TSV output
+	Delimited DelimitedOptions
+	// JSON describes the JSON Lines output
+	JSON JSONOptions
+	// WithTable adds the table name as "_table" to every JSON object, used when the tables share one stream
+	WithTable bool
+	// BoolColumns 为 TINYINT(1) 列，JSON.TinyIntAsBool 时写成 true 和 false
+	BoolColumns []string
+	// InsertMode writes INSERT, REPLACE, INSERT IGNORE or INSERT ... ON DUPLICATE KEY UPDATE
+	// statements, the empty string is InsertModeInsert
+	InsertMode string
+	// UpsertAlias refers to the new row through a row alias instead of VALUES() in upserts,
+	// supported by MySQL 8.0.19 and later
+	UpsertAlias bool
+	// OnRow is called after every exported row, used to report progress
+	OnRow func()
+	// Masks maps a column to the SQL expression selected instead of the column value
+	Masks map[string]string
+	// NoComments omits the comment before the data, NoLocks the LOCK TABLES and UNLOCK TABLES around it
+	// and NoDisableKeys the ALTER TABLE ... DISABLE KEYS and ENABLE KEYS statements
+	NoComments    bool
+	NoLocks       bool
+	NoDisableKeys bool
+	// KeyColumns 为表的主键列，重试时按主键从最后导出的行继续，upsert 时不更新这些列
+	KeyColumns []string
+	// Retry 为遇到临时错误时的重试策略，零值表示不重试
+	Retry retry.Policy
+	// OnRetry is called before every retry of the table
+	OnRetry func(err error)
+}
+
+// rowEncoder 按输出格式写出表数据，查询、重试和续传由 tableExport 负责
+type rowEncoder interface {
+	// begin 在第一次查询成功后写出表头
+	begin(columnTypes []*sql.ColumnType)
+	encode(values []interface{}, columnTypes []*sql.ColumnType)
+	// end 在导出结束后写出表尾，err 为导出失败的原因
+	end(err error)
+}
+
+// tableExport 记录一个表的导出进度，重连后从 lastKey 之后继续
+type tableExport struct {
+	db         dbConn.Querier
+	tableName  string
+	columns    []string
+	opts       Options
+	selectList string
+	encoder    rowEncoder
+	// keyIndexes 为主键列在 columns 中的位置，为 nil 时无法断点续传
+	keyIndexes []int
+
+	rows          int64
+	lastKey       []string
+	headerWritten bool
+}
+
+// ExportData exports table data in opts.Format and returns the number of rows written.
+// Transient errors are retried according to opts.Retry: before any row is written the query is
+// simply repeated, afterwards the export resumes after the last exported primary key, which
+// requires opts.KeyColumns. When an SQL export fails after the header was written, the rows
+// written so far are rolled back by the dump
+func ExportData(ctx context.Context, w io.Writer, db dbConn.Querier, tableName string, columns []string, opts Options) (int64, error) {
 	// 构建查询语句
-	columnList := "`" + strings.Join(columns, "`, `") + "`"
-	query := fmt.Sprintf("SELECT %s FROM `%s`", columnList, tableName)
+	selectList, err := buildSelectList(columns, opts.Masks)
+	if err != nil {
+		return 0, err
+	}
+	encoder, err := newEncoder(w, tableName, columns, opts)
+	if err != nil {
+		return 0, err
+	}
 
-	if whereClause != "" {
-		query += " WHERE " + whereClause
+	e := &tableExport{
+		db:         db,
+		tableName:  tableName,
+		columns:    columns,
+		opts:       opts,
+		selectList: selectList,
+		encoder:    encoder,
+		keyIndexes: resumeKeyIndexes(columns, opts),
 	}
 
-	rows, err := db.Query(query)
+	err = e.run(ctx)
+	if e.headerWritten {
+		e.encoder.end(err)
+	}
+	return e.rows, err
+}
+
+// newEncoder 根据 opts.Format 选择输出格式
+func newEncoder(w io.Writer, tableName string, columns []string, opts Options) (rowEncoder, error) {
+	switch opts.Format {
+	case "", FormatSQL:
+		insert, err := newInsertStatement(columns, opts)
+		if err != nil {
+			return nil, err
+		}
+		return &sqlEncoder{w: w, tableName: tableName, columns: columns, opts: opts, insert: insert}, nil
+	case FormatCSV, FormatTSV:
+		if err := opts.Delimited.Validate(opts.Format); err != nil {
+			return nil, err
+		}
+		return newDelimitedEncoder(w, columns, opts.Format, opts.Delimited), nil
+	case FormatJSONL:
+		return newJSONEncoder(w, tableName, columns, opts), nil
+	default:
+		return nil, fmt.Errorf("invalid format %q, must be one of %s", opts.Format, strings.Join(Formats, ", "))
+	}
+}
+
+// sqlEncoder 将每行写成一条 INSERT 语句，表数据在一个事务中写出
+type sqlEncoder struct {
+	w         io.Writer
+	tableName string
+	columns   []string
+	opts      Options
+	insert    insertStatement
+}
+
+func (s *sqlEncoder) begin([]*sql.ColumnType) {
+	if !s.opts.NoComments {
+		fmt.Fprintf(s.w, "--\n-- Dumping data for table `%s`\n--\n\n", s.tableName)
+	}
+	if !s.opts.NoLocks {
+		fmt.Fprintf(s.w, "LOCK TABLES `%s` WRITE;\n", s.tableName)
+	}
+	if !s.opts.NoDisableKeys {
+		fmt.Fprintf(s.w, "/*!40000 ALTER TABLE `%s` DISABLE KEYS */;\n", s.tableName)
+	}
+	fmt.Fprintln(s.w, "START TRANSACTION;")
+}
+
+func (s *sqlEncoder) encode(values []interface{}, columnTypes []*sql.ColumnType) {
+	fmt.Fprintln(s.w, buildInsertStatement(s.tableName, s.columns, values, columnTypes, s.insert))
+}
+
+func (s *sqlEncoder) end(err error) {
+	if err != nil {
+		// 回滚已写出的行，恢复时不会导入不完整的表数据
+		fmt.Fprintln(s.w, "ROLLBACK;")
+	} else {
+		fmt.Fprintln(s.w, "COMMIT;")
+	}
+	if !s.opts.NoDisableKeys {
+		fmt.Fprintf(s.w, "/*!40000 ALTER TABLE `%s` ENABLE KEYS */;\n", s.tableName)
+	}
+	if !s.opts.NoLocks {
+		fmt.Fprintln(s.w, "UNLOCK TABLES;")
+	}
+}
+
+// run 导出表数据，遇到临时错误时按重试策略重新查询或从最后导出的主键继续
+func (e *tableExport) run(ctx context.Context) error {
+	for attempt := 1; ; attempt++ {
+		err := e.export(ctx)
+		if err == nil || !retry.IsTransient(err) {
+			return err
+		}
+		if e.rows > 0 && e.keyIndexes == nil {
+			return fmt.Errorf("%w (can not resume after %d rows, the table has no usable primary key)", err, e.rows)
+		}
+		if !e.opts.Retry.Wait(ctx, attempt, err, log.KeyTable, e.tableName, log.KeyRows, e.rows) {
+			return err
+		}
+		if e.opts.OnRetry != nil {
+			e.opts.OnRetry(err)
+		}
+	}
+}
+
+// resumeKeyIndexes 返回主键列的位置，主键列被脱敏、为虚拟列或未启用重试时无法续传
+func resumeKeyIndexes(columns []string, opts Options) []int {
+	if opts.Retry.Retries == 0 || len(opts.KeyColumns) == 0 {
+		return nil
+	}
+	indexes := make([]int, len(opts.KeyColumns))
+	for i, key := range opts.KeyColumns {
+		if _, masked := opts.Masks[key]; masked {
+			return nil
+		}
+		indexes[i] = slices.Index(columns, key)
+		if indexes[i] < 0 {
+			return nil
+		}
+	}
+	return indexes
+}
+
+// query 构建查询语句，续传时只查询主键大于 lastKey 的行
+func (e *tableExport) query() string {
+	query := fmt.Sprintf("SELECT %s FROM `%s`", e.selectList, e.tableName)
+
+	var conditions []string
+	if e.opts.Where != "" {
+		conditions = append(conditions, "("+e.opts.Where+")")
+	}
+	keyList := ""
+	if e.keyIndexes != nil {
+		keyList = "`" + strings.Join(e.opts.KeyColumns, "`, `") + "`"
+	}
+	if e.lastKey != nil {
+		if len(e.lastKey) == 1 {
+			conditions = append(conditions, fmt.Sprintf("%s > %s", keyList, e.lastKey[0]))
+		} else {
+			conditions = append(conditions, fmt.Sprintf("(%s) > (%s)", keyList, strings.Join(e.lastKey, ", ")))
+		}
+	}
+	if len(conditions) > 0 {
+		query += " WHERE " + strings.Join(conditions, " AND ")
+	}
+	if keyList != "" {
+		query += " ORDER BY " + keyList
+	}
+	return query
+}
+
+// export 执行一次查询并写出结果，表头只在第一次成功查询后写出
+func (e *tableExport) export(ctx context.Context) error {
+	rows, err := e.db.QueryContext(ctx, e.query())
 	if err != nil {
 		return fmt.Errorf("failed to query table data: %w", err)
 	}
@@ -28,16 +273,15 @@ func ExportData(db *sql.DB, tableName string, columns []string, whereClause stri
 		return fmt.Errorf("failed to get column types: %w", err)
 	}
 
-	// 输出表头信息
-	fmt.Printf("--\n-- Dumping data for table `%s`\n--\n\n", tableName)
-
-	fmt.Printf("LOCK TABLES `%s` WRITE;\n", tableName)
-	fmt.Printf("/*!40000 ALTER TABLE `%s` DISABLE KEYS */;\n", tableName)
-	fmt.Println("START TRANSACTION;")
+	if !e.headerWritten {
+		// 输出表头信息
+		e.encoder.begin(columnTypes)
+		e.headerWritten = true
+	}
 
 	// 准备用于Scan的值
-	values := make([]interface{}, len(columns))
-	valuePtrs := make([]interface{}, len(columns))
+	values := make([]interface{}, len(e.columns))
+	valuePtrs := make([]interface{}, len(e.columns))
 	for i := range values {
 		valuePtrs[i] = &values[i]
 	}
@@ -50,23 +294,126 @@ func ExportData(db *sql.DB, tableName string, columns []string, whereClause stri
 			return fmt.Errorf("failed to scan row: %w", err)
 		}
 
-		// 构建INSERT语句
-		insertStmt := buildInsertStatement(tableName, columns, values, columnTypes)
-		fmt.Println(insertStmt)
+		e.encoder.encode(values, columnTypes)
+		e.rows++
+		if e.keyIndexes != nil {
+			e.lastKey = make([]string, len(e.keyIndexes))
+			for i, index := range e.keyIndexes {
+				e.lastKey[i] = keyLiteral(values[index], columnTypes[index].DatabaseTypeName())
+			}
+		}
+		if e.opts.OnRow != nil {
+			e.opts.OnRow()
+		}
 	}
 
 	if err = rows.Err(); err != nil {
 		return fmt.Errorf("error iterating rows: %w", err)
 	}
-
-	fmt.Println("COMMIT;")
-	fmt.Printf("/*!40000 ALTER TABLE `%s` ENABLE KEYS */;\n", tableName)
-	fmt.Println("UNLOCK TABLES;")
 	return nil
 }
 
+// keyLiteral 将主键值转换为 SQL 字面量：整数和定点数不加引号以避免按浮点数比较，
+// 二进制类型使用十六进制字面量，其他类型使用字符串字面量以便按列的排序规则比较
+func keyLiteral(value interface{}, typeName string) string {
+	var raw []byte
+	switch v := value.(type) {
+	case nil:
+		return "NULL"
+	case []byte:
+		raw = v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	default:
+		raw = []byte(fmt.Sprint(v))
+	}
+
+	switch {
+	case strings.Contains(typeName, "INT") || strings.Contains(typeName, "DECIMAL") || typeName == "YEAR":
+		if isNumeric(raw) {
+			return string(raw)
+		}
+	case typeName == "BINARY" || typeName == "VARBINARY" || typeName == "BLOB" || typeName == "BIT":
+		return "X'" + hex.EncodeToString(raw) + "'"
+	}
+	return EscapeSQLString(string(raw))
+}
+
+func isNumeric(raw []byte) bool {
+	if len(raw) == 0 {
+		return false
+	}
+	for i, c := range raw {
+		if !(c >= '0' && c <= '9' || c == '.' || c == '-' && i == 0) {
+			return false
+		}
+	}
+	return true
+}
+
+// buildSelectList 构建查询的列列表，脱敏的列以表达式代替，结果仍使用原列名
+func buildSelectList(columns []string, masks map[string]string) (string, error) {
+	for column := range masks {
+		if !slices.Contains(columns, column) {
+			return "", fmt.Errorf("masked column %s does not exist or is a generated column", column)
+		}
+	}
+
+	items := make([]string, len(columns))
+	for i, column := range columns {
+		if expression, ok := masks[column]; ok {
+			items[i] = fmt.Sprintf("(%s) AS `%s`", expression, column)
+		} else {
+			items[i] = "`" + column + "`"
+		}
+	}
+	return strings.Join(items, ", "), nil
+}
+
+// insertStatement 为按 InsertMode 写出的语句开头的关键字和结尾的 ON DUPLICATE KEY UPDATE 子句
+type insertStatement struct {
+	verb   string
+	suffix string
+}
+
+// newInsertStatement 根据 opts.InsertMode 构建语句，upsert 更新主键以外的所有列
+func newInsertStatement(columns []string, opts Options) (insertStatement, error) {
+	switch opts.InsertMode {
+	case "", InsertModeInsert:
+		return insertStatement{verb: "INSERT INTO"}, nil
+	case InsertModeReplace:
+		return insertStatement{verb: "REPLACE INTO"}, nil
+	case InsertModeIgnore:
+		return insertStatement{verb: "INSERT IGNORE INTO"}, nil
+	case InsertModeUpsert:
+	default:
+		return insertStatement{}, fmt.Errorf("invalid insert mode %q, must be one of %s", opts.InsertMode, strings.Join(InsertModes, ", "))
+	}
+
+	var updates []string
+	for _, column := range columns {
+		if slices.Contains(opts.KeyColumns, column) {
+			continue
+		}
+		if opts.UpsertAlias {
+			updates = append(updates, fmt.Sprintf("`%s`=new.`%s`", column, column))
+		} else {
+			updates = append(updates, fmt.Sprintf("`%s`=VALUES(`%s`)", column, column))
+		}
+	}
+	// 所有列都属于主键时没有可更新的列，将主键更新为自身以忽略重复的行
+	if len(updates) == 0 {
+		updates = append(updates, fmt.Sprintf("`%s`=`%s`", columns[0], columns[0]))
+	}
+	suffix := " ON DUPLICATE KEY UPDATE " + strings.Join(updates, ", ")
+	if opts.UpsertAlias {
+		suffix = " AS new" + suffix
+	}
+	return insertStatement{verb: "INSERT INTO", suffix: suffix}, nil
+}
+
 // buildInsertStatement builds an INSERT statement for a row of data
-func buildInsertStatement(tableName string, columns []string, values []interface{}, columnTypes []*sql.ColumnType) string {
+func buildInsertStatement(tableName string, columns []string, values []interface{}, columnTypes []*sql.ColumnType, insert insertStatement) string {
 	// 构建列名部分
 	columnList := "`" + strings.Join(columns, "`, `") + "`"
 
@@ -79,7 +426,7 @@ func buildInsertStatement(tableName string, columns []string, values []interface
 	}
 
 	// 组合INSERT语句
-	return fmt.Sprintf("INSERT INTO `%s` (%s) VALUES (%s);", tableName, columnList, strings.Join(valueList, ", "))
+	return fmt.Sprintf("%s `%s` (%s) VALUES (%s)%s;", insert.verb, tableName, columnList, strings.Join(valueList, ", "), insert.suffix)
 }
 
 // EscapeSQLString 接收一个字符串，并返回一个符合SQL字面量规范的安全字符串。
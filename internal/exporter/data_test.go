@@ -0,0 +1,163 @@
+package exporter
+
+import (
+	"database/sql"
+	"motors-backup/internal/retry"
+	"slices"
+	"testing"
+)
+
+func TestBuildSelectList(t *testing.T) {
+	columns := []string{"id", "email", "phone"}
+
+	got, err := buildSelectList(columns, nil)
+	if err != nil {
+		t.Fatalf("buildSelectList returned error: %v", err)
+	}
+	if want := "`id`, `email`, `phone`"; got != want {
+		t.Errorf("buildSelectList() = %s, want %s", got, want)
+	}
+
+	got, err = buildSelectList(columns, map[string]string{"email": "CONCAT(id, '@example.invalid')"})
+	if err != nil {
+		t.Fatalf("buildSelectList returned error: %v", err)
+	}
+	if want := "`id`, (CONCAT(id, '@example.invalid')) AS `email`, `phone`"; got != want {
+		t.Errorf("buildSelectList() = %s, want %s", got, want)
+	}
+
+	if _, err := buildSelectList(columns, map[string]string{"mail": "NULL"}); err == nil {
+		t.Error("expected error for unknown masked column")
+	}
+}
+
+func TestTableExportQuery(t *testing.T) {
+	e := &tableExport{
+		tableName:  "orders",
+		selectList: "`shop_id`, `id`, `total`",
+		opts:       Options{Where: "total > 0 OR id < 10", KeyColumns: []string{"shop_id", "id"}},
+	}
+	if want := "SELECT `shop_id`, `id`, `total` FROM `orders` WHERE (total > 0 OR id < 10)"; e.query() != want {
+		t.Errorf("query() = %s, want %s", e.query(), want)
+	}
+
+	e.keyIndexes = []int{0, 1}
+	if want := "SELECT `shop_id`, `id`, `total` FROM `orders` WHERE (total > 0 OR id < 10) ORDER BY `shop_id`, `id`"; e.query() != want {
+		t.Errorf("query() = %s, want %s", e.query(), want)
+	}
+
+	// 续传时只查询最后导出的主键之后的行
+	e.lastKey = []string{"'a'", "42"}
+	if want := "SELECT `shop_id`, `id`, `total` FROM `orders` WHERE (total > 0 OR id < 10) AND (`shop_id`, `id`) > ('a', 42) ORDER BY `shop_id`, `id`"; e.query() != want {
+		t.Errorf("query() = %s, want %s", e.query(), want)
+	}
+
+	e.opts.Where = ""
+	e.opts.KeyColumns = []string{"id"}
+	e.keyIndexes = []int{1}
+	e.lastKey = []string{"42"}
+	if want := "SELECT `shop_id`, `id`, `total` FROM `orders` WHERE `id` > 42 ORDER BY `id`"; e.query() != want {
+		t.Errorf("query() = %s, want %s", e.query(), want)
+	}
+}
+
+func TestResumeKeyIndexes(t *testing.T) {
+	columns := []string{"id", "email", "created_at"}
+	policy := retry.Policy{Retries: 3}
+
+	if got := resumeKeyIndexes(columns, Options{KeyColumns: []string{"created_at", "id"}, Retry: policy}); !slices.Equal(got, []int{2, 0}) {
+		t.Errorf("resumeKeyIndexes() = %v, want [2 0]", got)
+	}
+	// 未启用重试、没有主键、主键被脱敏或为虚拟列时无法续传
+	for _, opts := range []Options{
+		{KeyColumns: []string{"id"}},
+		{Retry: policy},
+		{KeyColumns: []string{"id"}, Retry: policy, Masks: map[string]string{"id": "0"}},
+		{KeyColumns: []string{"id", "virtual_id"}, Retry: policy},
+	} {
+		if got := resumeKeyIndexes(columns, opts); got != nil {
+			t.Errorf("resumeKeyIndexes(%+v) = %v, want nil", opts, got)
+		}
+	}
+}
+
+func TestKeyLiteral(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		typeName string
+		want     string
+	}{
+		{int64(42), "BIGINT", "42"},
+		{[]byte("-7"), "INT", "-7"},
+		{[]byte("12.50"), "DECIMAL", "12.50"},
+		{[]byte("o'brien"), "VARCHAR", "'o''brien'"},
+		{[]byte("2024-01-01 00:00:00"), "DATETIME", "'2024-01-01 00:00:00'"},
+		{[]byte{0x00, 0xff}, "VARBINARY", "X'00ff'"},
+		{nil, "INT", "NULL"},
+	}
+	for _, tc := range tests {
+		if got := keyLiteral(tc.value, tc.typeName); got != tc.want {
+			t.Errorf("keyLiteral(%v, %s) = %s, want %s", tc.value, tc.typeName, got, tc.want)
+		}
+	}
+}
+
+func TestBuildInsertStatement(t *testing.T) {
+	columns := []string{"id", "email", "total"}
+	values := []interface{}{[]byte("7"), []byte("a@example.com"), nil}
+	columnTypes := make([]*sql.ColumnType, len(columns))
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "insert",
+			want: "INSERT INTO `users` (`id`, `email`, `total`) VALUES ('7', 'a@example.com', NULL);",
+		},
+		{
+			name: "replace",
+			opts: Options{InsertMode: InsertModeReplace},
+			want: "REPLACE INTO `users` (`id`, `email`, `total`) VALUES ('7', 'a@example.com', NULL);",
+		},
+		{
+			name: "ignore",
+			opts: Options{InsertMode: InsertModeIgnore},
+			want: "INSERT IGNORE INTO `users` (`id`, `email`, `total`) VALUES ('7', 'a@example.com', NULL);",
+		},
+		{
+			name: "upsert",
+			opts: Options{InsertMode: InsertModeUpsert, KeyColumns: []string{"id"}},
+			want: "INSERT INTO `users` (`id`, `email`, `total`) VALUES ('7', 'a@example.com', NULL)" +
+				" ON DUPLICATE KEY UPDATE `email`=VALUES(`email`), `total`=VALUES(`total`);",
+		},
+		{
+			name: "upsert with row alias",
+			opts: Options{InsertMode: InsertModeUpsert, KeyColumns: []string{"id"}, UpsertAlias: true},
+			want: "INSERT INTO `users` (`id`, `email`, `total`) VALUES ('7', 'a@example.com', NULL)" +
+				" AS new ON DUPLICATE KEY UPDATE `email`=new.`email`, `total`=new.`total`;",
+		},
+		{
+			name: "upsert without columns outside the key",
+			opts: Options{InsertMode: InsertModeUpsert, KeyColumns: []string{"id", "email", "total"}},
+			want: "INSERT INTO `users` (`id`, `email`, `total`) VALUES ('7', 'a@example.com', NULL)" +
+				" ON DUPLICATE KEY UPDATE `id`=`id`;",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			insert, err := newInsertStatement(columns, tc.opts)
+			if err != nil {
+				t.Fatalf("newInsertStatement returned error: %v", err)
+			}
+			if got := buildInsertStatement("users", columns, values, columnTypes, insert); got != tc.want {
+				t.Errorf("buildInsertStatement() =\n%s\nwant\n%s", got, tc.want)
+			}
+		})
+	}
+
+	if _, err := newInsertStatement(columns, Options{InsertMode: "merge"}); err == nil {
+		t.Error("expected error for an unknown insert mode")
+	}
+}
@@ -0,0 +1,161 @@
+package exporter
+
+import (
+	"database/sql"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+// DelimitedOptions 描述 CSV 和 TSV 的写法，零值输出逗号（TSV 为制表符）分隔、带表头、以 \n 换行的文件
+type DelimitedOptions struct {
+	// Delimiter separates the fields, 0 uses a comma for CSV and a tab for TSV
+	Delimiter rune
+	// Null is written for NULL values, an empty string or a string equal to Null is quoted to tell them apart
+	Null string
+	// CRLF ends the lines with \r\n instead of \n
+	CRLF bool
+	// NoHeader omits the header row with the column names
+	NoHeader bool
+}
+
+// Validate checks the delimiter and the NULL representation for the CSV or TSV format
+func (o DelimitedOptions) Validate(format string) error {
+	delimiter := o.delimiter(format)
+	if delimiter == '"' || delimiter == '\r' || delimiter == '\n' || delimiter == utf8.RuneError || !utf8.ValidRune(delimiter) {
+		return fmt.Errorf("invalid delimiter %q", delimiter)
+	}
+	if strings.ContainsAny(o.Null, "\"\r\n") || strings.ContainsRune(o.Null, delimiter) {
+		return fmt.Errorf("invalid NULL representation %q, it must not contain quotes, line breaks or the delimiter", o.Null)
+	}
+	return nil
+}
+
+func (o DelimitedOptions) delimiter(format string) rune {
+	switch {
+	case o.Delimiter != 0:
+		return o.Delimiter
+	case format == FormatTSV:
+		return '\t'
+	default:
+		return ','
+	}
+}
+
+// delimitedEncoder 按 RFC 4180 写出 CSV 和 TSV，包含分隔符、引号或换行的字段加双引号
+type delimitedEncoder struct {
+	w         io.Writer
+	columns   []string
+	opts      DelimitedOptions
+	delimiter rune
+	eol       string
+	// typeNames 为各列的类型名，在第一次查询成功后记录
+	typeNames []string
+	line      strings.Builder
+}
+
+func newDelimitedEncoder(w io.Writer, columns []string, format string, opts DelimitedOptions) *delimitedEncoder {
+	e := &delimitedEncoder{w: w, columns: columns, opts: opts, delimiter: opts.delimiter(format), eol: "\n"}
+	if opts.CRLF {
+		e.eol = "\r\n"
+	}
+	return e
+}
+
+func (e *delimitedEncoder) begin(columnTypes []*sql.ColumnType) {
+	e.typeNames = make([]string, len(columnTypes))
+	for i, columnType := range columnTypes {
+		e.typeNames[i] = columnType.DatabaseTypeName()
+	}
+	if e.opts.NoHeader {
+		return
+	}
+	e.line.Reset()
+	for i, column := range e.columns {
+		if i > 0 {
+			e.line.WriteRune(e.delimiter)
+		}
+		e.writeField(column, false)
+	}
+	e.line.WriteString(e.eol)
+	io.WriteString(e.w, e.line.String())
+}
+
+func (e *delimitedEncoder) encode(values []interface{}, _ []*sql.ColumnType) {
+	e.line.Reset()
+	for i, value := range values {
+		if i > 0 {
+			e.line.WriteRune(e.delimiter)
+		}
+		if value == nil {
+			e.line.WriteString(e.opts.Null)
+			continue
+		}
+		e.writeField(formatField(value, e.typeNames[i]), true)
+	}
+	e.line.WriteString(e.eol)
+	io.WriteString(e.w, e.line.String())
+}
+
+// end 没有表尾，导出失败时由调用方丢弃不完整的文件
+func (e *delimitedEncoder) end(error) {}
+
+// writeField 写出一个字段，值与 NULL 的写法相同时加引号以便区分
+func (e *delimitedEncoder) writeField(field string, isValue bool) {
+	quote := strings.ContainsAny(field, "\"\r\n") || strings.ContainsRune(field, e.delimiter) ||
+		isValue && field == e.opts.Null
+	if !quote {
+		e.line.WriteString(field)
+		return
+	}
+	e.line.WriteByte('"')
+	e.line.WriteString(strings.ReplaceAll(field, `"`, `""`))
+	e.line.WriteByte('"')
+}
+
+// formatField 按列类型格式化非 NULL 的值：二进制类型为十六进制，BIT 为整数，浮点数不使用科学计数法
+func formatField(value interface{}, typeName string) string {
+	switch v := value.(type) {
+	case []byte:
+		switch {
+		case typeName == "BIT":
+			var n uint64
+			for _, b := range v {
+				n = n<<8 | uint64(b)
+			}
+			return strconv.FormatUint(n, 10)
+		case isBinaryType(typeName):
+			return hex.EncodeToString(v)
+		}
+		return string(v)
+	case string:
+		return v
+	case int64:
+		return strconv.FormatInt(v, 10)
+	case uint64:
+		return strconv.FormatUint(v, 10)
+	case float32:
+		return strconv.FormatFloat(float64(v), 'f', -1, 32)
+	case float64:
+		if typeName == "FLOAT" {
+			return strconv.FormatFloat(v, 'f', -1, 32)
+		}
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	case time.Time:
+		if typeName == "DATE" {
+			return v.Format(time.DateOnly)
+		}
+		return v.Format("2006-01-02 15:04:05.999999")
+	default:
+		return fmt.Sprint(v)
+	}
+}
+
+// isBinaryType 判断驱动返回的类型名是否为二进制类型，文本类型的名称为 CHAR、VARCHAR 和 TEXT
+func isBinaryType(typeName string) bool {
+	return strings.Contains(typeName, "BINARY") || strings.HasSuffix(typeName, "BLOB") || typeName == "GEOMETRY"
+}
@@ -0,0 +1,107 @@
+package exporter
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestDelimitedEncoder(t *testing.T) {
+	columns := []string{"id", "name", "note", "data"}
+	typeNames := []string{"INT", "VARCHAR", "TEXT", "VARBINARY"}
+	rows := [][]interface{}{
+		{int64(1), []byte("plain"), []byte(""), []byte{0x00, 0xff}},
+		{int64(2), []byte(`say "hi", bye`), nil, nil},
+		{int64(3), []byte("two\nlines"), []byte(`\N`), []byte{}},
+	}
+
+	tests := []struct {
+		name   string
+		format string
+		opts   DelimitedOptions
+		want   string
+	}{
+		{
+			name:   "csv",
+			format: FormatCSV,
+			want: "id,name,note,data\n" +
+				"1,plain,\"\",00ff\n" +
+				"2,\"say \"\"hi\"\", bye\",,\n" +
+				"3,\"two\nlines\",\\N,\"\"\n",
+		},
+		{
+			name:   "tsv with \\N and crlf",
+			format: FormatTSV,
+			opts:   DelimitedOptions{Null: `\N`, CRLF: true, NoHeader: true},
+			want: "1\tplain\t\t00ff\r\n" +
+				"2\t\"say \"\"hi\"\", bye\"\t\\N\t\\N\r\n" +
+				"3\t\"two\nlines\"\t\"\\N\"\t\r\n",
+		},
+		{
+			name:   "semicolon",
+			format: FormatCSV,
+			opts:   DelimitedOptions{Delimiter: ';', Null: "NULL", NoHeader: true},
+			want: "1;plain;;00ff\n" +
+				"2;\"say \"\"hi\"\", bye\";NULL;NULL\n" +
+				"3;\"two\nlines\";\\N;\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			e := newDelimitedEncoder(&b, columns, tc.format, tc.opts)
+			e.begin(nil)
+			e.typeNames = typeNames
+			for _, row := range rows {
+				e.encode(row, nil)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("output =\n%q\nwant\n%q", got, tc.want)
+			}
+		})
+	}
+}
+
+func TestFormatField(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		typeName string
+		want     string
+	}{
+		{int64(-42), "BIGINT", "-42"},
+		{uint64(18446744073709551615), "UNSIGNED BIGINT", "18446744073709551615"},
+		{float64(float32(0.1)), "FLOAT", "0.1"},
+		{1234567.5, "DOUBLE", "1234567.5"},
+		{[]byte("12.50"), "DECIMAL", "12.50"},
+		{[]byte{0x01, 0x02}, "BIT", "258"},
+		{[]byte("abc"), "BLOB", "616263"},
+		{[]byte("abc"), "TEXT", "abc"},
+		{[]byte(`{"a": 1}`), "JSON", `{"a": 1}`},
+		{[]byte("2024-01-02 03:04:05"), "DATETIME", "2024-01-02 03:04:05"},
+		{time.Date(2024, 1, 2, 0, 0, 0, 0, time.UTC), "DATE", "2024-01-02"},
+		{time.Date(2024, 1, 2, 3, 4, 5, 500000000, time.UTC), "DATETIME", "2024-01-02 03:04:05.5"},
+	}
+	for _, tc := range tests {
+		if got := formatField(tc.value, tc.typeName); got != tc.want {
+			t.Errorf("formatField(%v, %s) = %s, want %s", tc.value, tc.typeName, got, tc.want)
+		}
+	}
+}
+
+func TestDelimitedOptionsValidate(t *testing.T) {
+	valid := []DelimitedOptions{{}, {Delimiter: ';'}, {Delimiter: '|', Null: `\N`}}
+	for _, opts := range valid {
+		if err := opts.Validate(FormatCSV); err != nil {
+			t.Errorf("Validate(%+v) returned error: %v", opts, err)
+		}
+	}
+	invalid := []DelimitedOptions{{Delimiter: '"'}, {Delimiter: '\n'}, {Null: "a,b"}, {Null: `"`}}
+	for _, opts := range invalid {
+		if err := opts.Validate(FormatCSV); err == nil {
+			t.Errorf("Validate(%+v) returned no error", opts)
+		}
+	}
+	if err := (DelimitedOptions{Null: "a\tb"}).Validate(FormatTSV); err == nil {
+		t.Error("Validate accepted a NULL representation containing the TSV delimiter")
+	}
+}
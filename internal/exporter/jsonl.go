@@ -0,0 +1,125 @@
+package exporter
+
+import (
+	"bytes"
+	"database/sql"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"slices"
+	"strings"
+)
+
+// JSONOptions 描述 JSON Lines 的写法
+type JSONOptions struct {
+	// TinyIntAsBool writes TINYINT(1) columns, which MySQL uses for BOOLEAN, as true and false
+	TinyIntAsBool bool
+}
+
+// TableField is the key of the table name in the JSON objects of a stream shared by all tables
+const TableField = "_table"
+
+// jsonEncoder 将每行写成一个以列名为键的 JSON 对象，键的顺序与列的顺序相同
+type jsonEncoder struct {
+	w io.Writer
+	// keys 为已编码的列名，prefix 为 WithTable 时的表名字段
+	keys   []string
+	prefix string
+	bools  []bool
+	// typeNames 为各列的类型名，在第一次查询成功后记录
+	typeNames []string
+	line      bytes.Buffer
+}
+
+func newJSONEncoder(w io.Writer, tableName string, columns []string, opts Options) *jsonEncoder {
+	e := &jsonEncoder{w: w, keys: make([]string, len(columns)), bools: make([]bool, len(columns))}
+	for i, column := range columns {
+		e.keys[i] = jsonString(column) + ":"
+		e.bools[i] = opts.JSON.TinyIntAsBool && slices.Contains(opts.BoolColumns, column)
+	}
+	if opts.WithTable {
+		e.prefix = jsonString(TableField) + ":" + jsonString(tableName)
+	}
+	return e
+}
+
+func (e *jsonEncoder) begin(columnTypes []*sql.ColumnType) {
+	e.typeNames = make([]string, len(columnTypes))
+	for i, columnType := range columnTypes {
+		e.typeNames[i] = columnType.DatabaseTypeName()
+	}
+}
+
+func (e *jsonEncoder) encode(values []interface{}, _ []*sql.ColumnType) {
+	e.line.Reset()
+	e.line.WriteByte('{')
+	e.line.WriteString(e.prefix)
+	for i, value := range values {
+		if i > 0 || e.prefix != "" {
+			e.line.WriteByte(',')
+		}
+		e.line.WriteString(e.keys[i])
+		e.line.WriteString(e.jsonValue(i, value))
+	}
+	e.line.WriteString("}\n")
+	e.w.Write(e.line.Bytes())
+}
+
+// end 没有表尾，导出失败时由调用方丢弃不完整的文件
+func (e *jsonEncoder) end(error) {}
+
+// jsonValue 按列类型转换为 JSON：数字不加引号，二进制为 base64，JSON 列原样嵌入，其他类型为字符串
+func (e *jsonEncoder) jsonValue(i int, value interface{}) string {
+	if value == nil {
+		return "null"
+	}
+	typeName := e.typeNames[i]
+	if raw, ok := value.([]byte); ok {
+		switch {
+		case typeName == "JSON" && json.Valid(raw):
+			return compactJSON(raw)
+		case isBinaryType(typeName):
+			return `"` + base64.StdEncoding.EncodeToString(raw) + `"`
+		}
+	}
+
+	field := formatField(value, typeName)
+	if e.bools[i] {
+		return boolLiteral(field != "0")
+	}
+	if isNumberType(typeName) && isNumeric([]byte(field)) {
+		return field
+	}
+	return jsonString(field)
+}
+
+// isNumberType 判断驱动返回的类型名是否为数字类型，BIT 由 formatField 转换为整数
+func isNumberType(typeName string) bool {
+	return strings.Contains(typeName, "INT") || typeName == "DECIMAL" || typeName == "FLOAT" ||
+		typeName == "DOUBLE" || typeName == "YEAR" || typeName == "BIT"
+}
+
+// jsonString 编码字符串，不转义 HTML 字符
+func jsonString(s string) string {
+	var b strings.Builder
+	encoder := json.NewEncoder(&b)
+	encoder.SetEscapeHTML(false)
+	encoder.Encode(s)
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// compactJSON 去掉 JSON 列中的换行，保证每行一个对象
+func compactJSON(raw []byte) string {
+	var b bytes.Buffer
+	if err := json.Compact(&b, raw); err != nil {
+		return jsonString(string(raw))
+	}
+	return b.String()
+}
+
+func boolLiteral(v bool) string {
+	if v {
+		return "true"
+	}
+	return "false"
+}
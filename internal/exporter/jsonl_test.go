@@ -0,0 +1,76 @@
+package exporter
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSONEncoder(t *testing.T) {
+	columns := []string{"id", "name", "price", "active", "tags", "avatar", "created_at"}
+	typeNames := []string{"UNSIGNED BIGINT", "VARCHAR", "DECIMAL", "TINYINT", "JSON", "BLOB", "DATETIME"}
+	rows := [][]interface{}{
+		{int64(1), []byte(`<b>"Tom"</b>`), []byte("12.50"), int64(1), []byte("{\"a\": [1, 2],\n \"b\": null}"), []byte{0xff, 0x00}, []byte("2026-10-19 03:00:00")},
+		{int64(2), nil, []byte("-0.10"), int64(0), []byte("not json"), nil, nil},
+	}
+
+	tests := []struct {
+		name string
+		opts Options
+		want string
+	}{
+		{
+			name: "per table",
+			want: `{"id":1,"name":"<b>\"Tom\"</b>","price":12.50,"active":1,"tags":{"a":[1,2],"b":null},"avatar":"/wA=","created_at":"2026-10-19 03:00:00"}` + "\n" +
+				`{"id":2,"name":null,"price":-0.10,"active":0,"tags":"not json","avatar":null,"created_at":null}` + "\n",
+		},
+		{
+			name: "combined stream with booleans",
+			opts: Options{WithTable: true, JSON: JSONOptions{TinyIntAsBool: true}, BoolColumns: []string{"active"}},
+			want: `{"_table":"users","id":1,"name":"<b>\"Tom\"</b>","price":12.50,"active":true,"tags":{"a":[1,2],"b":null},"avatar":"/wA=","created_at":"2026-10-19 03:00:00"}` + "\n" +
+				`{"_table":"users","id":2,"name":null,"price":-0.10,"active":false,"tags":"not json","avatar":null,"created_at":null}` + "\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var b strings.Builder
+			e := newJSONEncoder(&b, "users", columns, tc.opts)
+			e.begin(nil)
+			e.typeNames = typeNames
+			for _, row := range rows {
+				e.encode(row, nil)
+			}
+			if got := b.String(); got != tc.want {
+				t.Errorf("output =\n%s\nwant\n%s", got, tc.want)
+			}
+			for _, line := range strings.Split(strings.TrimSuffix(b.String(), "\n"), "\n") {
+				if !json.Valid([]byte(line)) {
+					t.Errorf("invalid JSON line: %s", line)
+				}
+			}
+		})
+	}
+}
+
+func TestJSONValueTypes(t *testing.T) {
+	tests := []struct {
+		value    interface{}
+		typeName string
+		want     string
+	}{
+		{[]byte{0x01, 0x00}, "BIT", "256"},
+		{float64(float32(0.1)), "FLOAT", "0.1"},
+		{[]byte("2026"), "YEAR", "2026"},
+		{[]byte("42"), "INT", "42"},
+		{[]byte("12:30:00"), "TIME", `"12:30:00"`},
+		{[]byte("a\tb\n"), "TEXT", `"a\tb\n"`},
+		{[]byte("abc"), "VARBINARY", `"YWJj"`},
+	}
+	for _, tc := range tests {
+		e := newJSONEncoder(nil, "t", []string{"c"}, Options{})
+		e.typeNames = []string{tc.typeName}
+		if got := e.jsonValue(0, tc.value); got != tc.want {
+			t.Errorf("jsonValue(%v, %s) = %s, want %s", tc.value, tc.typeName, got, tc.want)
+		}
+	}
+}
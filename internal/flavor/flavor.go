@@ -0,0 +1,140 @@
+package flavor
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Flavor 为 MySQL 兼容服务器的发行版
+type Flavor string
+
+const (
+	MySQL   Flavor = "mysql"
+	MariaDB Flavor = "mariadb"
+	Percona Flavor = "percona"
+)
+
+// Name returns the product name of the flavor
+func (f Flavor) Name() string {
+	switch f {
+	case MariaDB:
+		return "MariaDB"
+	case Percona:
+		return "Percona Server"
+	default:
+		return "MySQL"
+	}
+}
+
+// Server describes the flavor and version of a server
+type Server struct {
+	Flavor Flavor
+	// Version is the value of VERSION()
+	Version string
+	Major   int
+	Minor   int
+	Patch   int
+}
+
+// MariaDB 通过旧版协议连接时版本号带有 5.5.5- 前缀
+const mariaDBReplicationPrefix = "5.5.5-"
+
+var versionPattern = regexp.MustCompile(`^(\d+)\.(\d+)(?:\.(\d+))?`)
+
+// Parse detects the server from VERSION() and @@version_comment, the numbers are left at zero
+// when the version can not be parsed
+func Parse(version, versionComment string) Server {
+	s := Server{Flavor: MySQL, Version: version}
+	comment := strings.ToLower(versionComment)
+	switch {
+	case strings.Contains(strings.ToLower(version), "mariadb") || strings.Contains(comment, "mariadb"):
+		s.Flavor = MariaDB
+	case strings.Contains(comment, "percona"):
+		s.Flavor = Percona
+	}
+
+	number := version
+	if s.Flavor == MariaDB {
+		number = strings.TrimPrefix(number, mariaDBReplicationPrefix)
+	}
+	if m := versionPattern.FindStringSubmatch(number); m != nil {
+		s.Major, _ = strconv.Atoi(m[1])
+		s.Minor, _ = strconv.Atoi(m[2])
+		s.Patch, _ = strconv.Atoi(m[3])
+	}
+	return s
+}
+
+// String returns the product name and version, e.g. "MariaDB 10.6.12"
+func (s Server) String() string {
+	if s.Major == 0 {
+		return fmt.Sprintf("%s %s", s.Flavor.Name(), s.Version)
+	}
+	return fmt.Sprintf("%s %d.%d.%d", s.Flavor.Name(), s.Major, s.Minor, s.Patch)
+}
+
+// AtLeast reports whether the server version is major.minor or later
+func (s Server) AtLeast(major, minor int) bool {
+	return s.Major > major || s.Major == major && s.Minor >= minor
+}
+
+// SupportsRowAlias reports whether INSERT ... ON DUPLICATE KEY UPDATE can refer to the new row through
+// a row alias, added in MySQL 8.0.19. It replaces the VALUES() function deprecated in 8.0.20, MariaDB only has VALUES()
+func (s Server) SupportsRowAlias() bool {
+	if s.Flavor == MariaDB {
+		return false
+	}
+	return s.AtLeast(8, 1) || s.Major == 8 && s.Minor == 0 && s.Patch >= 19
+}
+
+// Check returns an error for servers that are not supported: MySQL and Percona Server before 5.7
+// and MariaDB before 10.2. Versions that can not be parsed are assumed to be supported
+func (s Server) Check() error {
+	if s.Major == 0 {
+		return nil
+	}
+	if s.Flavor == MariaDB {
+		if !s.AtLeast(10, 2) {
+			return fmt.Errorf("incompatible MariaDB version: %s. Required version >= 10.2", s.Version)
+		}
+		return nil
+	}
+	if !s.AtLeast(5, 7) {
+		return fmt.Errorf("incompatible %s version: %s. Required version >= 5.7", s.Flavor.Name(), s.Version)
+	}
+	return nil
+}
+
+// IsGenerated reports whether the Extra value of SHOW COLUMNS marks a generated column.
+// MySQL 8.0 also reports DEFAULT_GENERATED for columns with expression defaults, those hold data.
+// MariaDB 10.2 reports VIRTUAL GENERATED or STORED GENERATED, older releases VIRTUAL or PERSISTENT
+func (s Server) IsGenerated(extra string) bool {
+	extra = strings.ToUpper(extra)
+	if strings.Contains(extra, "VIRTUAL GENERATED") || strings.Contains(extra, "STORED GENERATED") {
+		return true
+	}
+	if s.Flavor != MariaDB {
+		return false
+	}
+	for _, word := range strings.Fields(extra) {
+		if word == "VIRTUAL" || word == "PERSISTENT" {
+			return true
+		}
+	}
+	return false
+}
+
+// MariaDB 的六位版本号注释，MySQL 只读取五位版本号，会把第六位当作语句的一部分
+var sixDigitComment = regexp.MustCompile(`/\*!(\d{6})`)
+
+// GateVersionComments rewrites the executable comments of DDL read from the server so that they
+// only run on the flavor they were written for: MariaDB's six digit /*!NNNNNN comments become
+// /*M!NNNNNN, which MySQL ignores. DDL from MySQL and Percona Server is returned unchanged
+func (s Server) GateVersionComments(ddl string) string {
+	if s.Flavor != MariaDB {
+		return ddl
+	}
+	return sixDigitComment.ReplaceAllString(ddl, "/*M!$1")
+}
@@ -0,0 +1,128 @@
+package flavor
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		version, comment string
+		want             Server
+	}{
+		{"8.0.36", "MySQL Community Server - GPL", Server{Flavor: MySQL, Major: 8, Minor: 0, Patch: 36}},
+		{"5.7.44-log", "MySQL Community Server (GPL)", Server{Flavor: MySQL, Major: 5, Minor: 7, Patch: 44}},
+		{"5.7.44-48-log", "Percona Server (GPL), Release 48, Revision 497f936a373", Server{Flavor: Percona, Major: 5, Minor: 7, Patch: 44}},
+		{"10.6.12-MariaDB-1:10.6.12+maria~ubu2004-log", "mariadb.org binary distribution", Server{Flavor: MariaDB, Major: 10, Minor: 6, Patch: 12}},
+		{"5.5.5-10.11.6-MariaDB", "", Server{Flavor: MariaDB, Major: 10, Minor: 11, Patch: 6}},
+		{"11.4.2-MariaDB", "MariaDB Server", Server{Flavor: MariaDB, Major: 11, Minor: 4, Patch: 2}},
+		{"unknown", "", Server{Flavor: MySQL}},
+	}
+	for _, tc := range tests {
+		t.Run(tc.version, func(t *testing.T) {
+			tc.want.Version = tc.version
+			if got := Parse(tc.version, tc.comment); got != tc.want {
+				t.Errorf("Parse(%q, %q) = %+v, want %+v", tc.version, tc.comment, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		version, comment string
+		wantErr          bool
+	}{
+		{"8.0.36", "", false},
+		{"9.1.0", "", false},
+		{"5.7.44-log", "", false},
+		{"5.6.51", "", true},
+		{"5.5.62", "", true},
+		{"5.7.44-48", "Percona Server (GPL)", false},
+		{"5.6.51-91.0", "Percona Server (GPL)", true},
+		{"5.5.5-10.6.12-MariaDB", "", false},
+		{"10.2.44-MariaDB", "", false},
+		{"10.1.48-MariaDB", "", true},
+		{"11.4.2-MariaDB", "", false},
+		{"unknown", "", false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.version, func(t *testing.T) {
+			err := Parse(tc.version, tc.comment).Check()
+			if (err != nil) != tc.wantErr {
+				t.Errorf("Check() for %s error = %v, wantErr %v", tc.version, err, tc.wantErr)
+			}
+		})
+	}
+}
+
+func TestSupportsRowAlias(t *testing.T) {
+	tests := []struct {
+		version, comment string
+		want             bool
+	}{
+		{"8.0.18", "", false},
+		{"8.0.19", "", true},
+		{"8.0.20", "", true},
+		{"8.4.2", "", true},
+		{"9.1.0", "", true},
+		{"5.7.44-log", "", false},
+		{"8.0.36-28", "Percona Server (GPL)", true},
+		{"11.4.2-MariaDB", "", false},
+	}
+	for _, tc := range tests {
+		if got := Parse(tc.version, tc.comment).SupportsRowAlias(); got != tc.want {
+			t.Errorf("SupportsRowAlias() for %s = %v, want %v", tc.version, got, tc.want)
+		}
+	}
+}
+
+func TestIsGenerated(t *testing.T) {
+	mysql := Parse("8.0.36", "")
+	mariadb := Parse("10.6.12-MariaDB", "")
+	tests := []struct {
+		server Server
+		extra  string
+		want   bool
+	}{
+		{mysql, "VIRTUAL GENERATED", true},
+		{mysql, "STORED GENERATED", true},
+		{mysql, "DEFAULT_GENERATED", false},
+		{mysql, "DEFAULT_GENERATED on update CURRENT_TIMESTAMP", false},
+		{mysql, "auto_increment", false},
+		{mysql, "VIRTUAL", false},
+		{mysql, "", false},
+		{mariadb, "VIRTUAL GENERATED", true},
+		{mariadb, "STORED GENERATED", true},
+		{mariadb, "PERSISTENT", true},
+		{mariadb, "VIRTUAL", true},
+		{mariadb, "on update current_timestamp()", false},
+		{mariadb, "INVISIBLE", false},
+	}
+	for _, tc := range tests {
+		if got := tc.server.IsGenerated(tc.extra); got != tc.want {
+			t.Errorf("%s IsGenerated(%q) = %v, want %v", tc.server, tc.extra, got, tc.want)
+		}
+	}
+}
+
+func TestGateVersionComments(t *testing.T) {
+	ddl := "CREATE TABLE `t` (\n  `id` int(11) NOT NULL\n) ENGINE=InnoDB /*!100301 ENCRYPTED=YES */ /*!50100 PARTITION BY HASH (`id`) */"
+
+	got := Parse("10.6.12-MariaDB", "").GateVersionComments(ddl)
+	if !strings.Contains(got, "/*M!100301 ENCRYPTED=YES */") || !strings.Contains(got, "/*!50100 PARTITION BY") {
+		t.Errorf("GateVersionComments() for MariaDB = %q", got)
+	}
+	if got := Parse("8.0.36", "").GateVersionComments(ddl); got != ddl {
+		t.Errorf("GateVersionComments() for MySQL = %q, want it unchanged", got)
+	}
+}
+
+func TestString(t *testing.T) {
+	if got := Parse("5.5.5-10.6.12-MariaDB-log", "").String(); got != "MariaDB 10.6.12" {
+		t.Errorf("String() = %q, want MariaDB 10.6.12", got)
+	}
+	if got := Parse("unknown", "").String(); got != "MySQL unknown" {
+		t.Errorf("String() = %q, want MySQL unknown", got)
+	}
+}
@@ -1,15 +1,140 @@
 package log
 
 import (
-	"github.com/op/go-logging"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
 )
 
-var Logger = logging.MustGetLogger("MOTORS_BACKUP")
+// 结构化日志的字段名称
+const (
+	KeyStage    = "stage"
+	KeyDatabase = "database"
+	KeyTable    = "table"
+	KeyRows     = "rows"
+	KeyDuration = "duration"
+	KeyError    = "error"
+	KeyJob      = "job"
+)
+
+const (
+	FormatText = "text"
+	FormatJSON = "json"
+)
+
+// Logger is the process wide logger, every event is written as a single line
+var Logger = slog.New(newHandler(os.Stderr, FormatText, slog.LevelInfo))
+
+// Options configures the logger
+type Options struct {
+	Format string
+	Level  string
+	File   string
+}
+
+// OptionsFromEnv reads LOG_FORMAT, LOG_LEVEL and LOG_FILE
+func OptionsFromEnv() Options {
+	return Options{
+		Format: os.Getenv("LOG_FORMAT"),
+		Level:  os.Getenv("LOG_LEVEL"),
+		File:   os.Getenv("LOG_FILE"),
+	}
+}
+
+// Setup replaces Logger according to opts, events go to LOG_FILE when set and to stderr otherwise.
+// The returned function syncs and closes LOG_FILE, later events go to stderr
+func Setup(opts Options) (func() error, error) {
+	format := strings.ToLower(opts.Format)
+	if format == "" {
+		format = FormatText
+	}
+	if format != FormatText && format != FormatJSON {
+		return nil, fmt.Errorf("invalid LOG_FORMAT %q, expected text or json", opts.Format)
+	}
+
+	level, err := ParseLevel(opts.Level)
+	if err != nil {
+		return nil, err
+	}
+
+	if opts.File == "" {
+		Logger = slog.New(newHandler(os.Stderr, format, level))
+		return func() error { return nil }, nil
+	}
+
+	file, err := os.OpenFile(opts.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open LOG_FILE: %w", err)
+	}
+	Logger = slog.New(newHandler(file, format, level))
+	return func() error {
+		// 关闭后的事件写到 stderr，不会丢失
+		Logger = slog.New(newHandler(os.Stderr, format, level))
+		if err := file.Sync(); err != nil {
+			file.Close()
+			return fmt.Errorf("failed to sync LOG_FILE: %w", err)
+		}
+		if err := file.Close(); err != nil {
+			return fmt.Errorf("failed to close LOG_FILE: %w", err)
+		}
+		return nil
+	}, nil
+}
+
+// ParseLevel parses debug, info, warn (or warning) and error, empty means info
+func ParseLevel(level string) (slog.Level, error) {
+	switch strings.ToLower(level) {
+	case "debug":
+		return slog.LevelDebug, nil
+	case "", "info":
+		return slog.LevelInfo, nil
+	case "warn", "warning":
+		return slog.LevelWarn, nil
+	case "error":
+		return slog.LevelError, nil
+	}
+	return slog.LevelInfo, fmt.Errorf("invalid LOG_LEVEL %q, expected debug, info, warn or error", level)
+}
+
+func newHandler(out io.Writer, format string, level slog.Level) slog.Handler {
+	opts := &slog.HandlerOptions{
+		Level: level,
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			// 持续时间统一以秒为单位输出，便于日志系统解析
+			if a.Value.Kind() == slog.KindDuration {
+				return slog.Float64(a.Key, a.Value.Duration().Seconds())
+			}
+			return a
+		},
+	}
+	if format == FormatJSON {
+		return slog.NewJSONHandler(out, opts)
+	}
+	return slog.NewTextHandler(out, opts)
+}
+
+// Duration returns the duration field, written in seconds
+func Duration(d time.Duration) slog.Attr {
+	return slog.Duration(KeyDuration, d)
+}
+
+// attrError is implemented by errors that carry structured fields such as the stage and table
+type attrError interface {
+	LogAttrs() []slog.Attr
+}
 
-func init() {
-	format := logging.MustStringFormatter(
-		`MOTORS_BACKUP %{shortfunc} %{level:.4s} %{shortfile}
-%{id:03x} %{message}`,
-	)
-	logging.SetFormatter(format)
+// Err returns the error field followed by the fields carried by the error
+func Err(err error) []any {
+	args := []any{slog.String(KeyError, err.Error())}
+	var withAttrs attrError
+	if errors.As(err, &withAttrs) {
+		for _, attr := range withAttrs.LogAttrs() {
+			args = append(args, attr)
+		}
+	}
+	return args
 }
@@ -0,0 +1,120 @@
+package log
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type stageError struct{ stage string }
+
+func (e *stageError) Error() string { return "boom" }
+
+func (e *stageError) LogAttrs() []slog.Attr {
+	return []slog.Attr{slog.String(KeyStage, e.stage)}
+}
+
+func TestJSONHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := slog.New(newHandler(&buf, FormatJSON, slog.LevelInfo))
+
+	err := fmt.Errorf("wrapped: %w", &stageError{stage: "table_data"})
+	logger.Error("backup failed", append(Err(err), KeyTable, "users", Duration(1500*time.Millisecond))...)
+	logger.Debug("hidden")
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("expected one line, got %d: %q", len(lines), buf.String())
+	}
+
+	var event map[string]any
+	if err := json.Unmarshal([]byte(lines[0]), &event); err != nil {
+		t.Fatalf("line is not valid JSON: %v", err)
+	}
+	want := map[string]any{
+		"msg":       "backup failed",
+		"level":     "ERROR",
+		KeyError:    "wrapped: boom",
+		KeyStage:    "table_data",
+		KeyTable:    "users",
+		KeyDuration: 1.5,
+	}
+	for key, value := range want {
+		if event[key] != value {
+			t.Errorf("%s = %v, want %v", key, event[key], value)
+		}
+	}
+}
+
+func TestErrWithoutAttrs(t *testing.T) {
+	args := Err(errors.New("plain"))
+	if len(args) != 1 {
+		t.Fatalf("expected only the error field, got %v", args)
+	}
+}
+
+func TestParseLevel(t *testing.T) {
+	tests := map[string]slog.Level{
+		"":        slog.LevelInfo,
+		"debug":   slog.LevelDebug,
+		"INFO":    slog.LevelInfo,
+		"warning": slog.LevelWarn,
+		"error":   slog.LevelError,
+	}
+	for input, want := range tests {
+		got, err := ParseLevel(input)
+		if err != nil || got != want {
+			t.Errorf("ParseLevel(%q) = %v, %v; want %v", input, got, err, want)
+		}
+	}
+	if _, err := ParseLevel("verbose"); err == nil {
+		t.Error("expected error for invalid level")
+	}
+}
+
+func TestSetup(t *testing.T) {
+	original := Logger
+	defer func() { Logger = original }()
+
+	if _, err := Setup(Options{Format: "xml"}); err == nil {
+		t.Error("expected error for invalid format")
+	}
+
+	path := filepath.Join(t.TempDir(), "backup.log")
+	closeLog, err := Setup(Options{Format: "json", Level: "debug", File: path})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	Logger.Debug("written to file", KeyDatabase, "motors")
+	if err := closeLog(); err != nil {
+		t.Fatalf("closing the log file failed: %v", err)
+	}
+	// 关闭后的事件不再写入文件
+	Logger.Debug("written to stderr", KeyDatabase, "after close")
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read log file: %v", err)
+	}
+	if !strings.Contains(string(data), `"database":"motors"`) {
+		t.Errorf("log file does not contain the event: %s", data)
+	}
+	if strings.Contains(string(data), "after close") {
+		t.Errorf("log file contains an event logged after closing it: %s", data)
+	}
+
+	closeLog, err = Setup(Options{})
+	if err != nil {
+		t.Fatalf("Setup failed: %v", err)
+	}
+	if err := closeLog(); err != nil {
+		t.Errorf("closing stderr logging returned error: %v", err)
+	}
+}
@@ -0,0 +1,212 @@
+package manifest
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// FileName is the name of the manifest written next to every backup
+const FileName = "manifest.json"
+
+// CompletedMarker starts the trailer comment written at the end of a complete SQL dump
+const CompletedMarker = "-- Dump completed on "
+
+// IncompleteMarker starts the trailer comment written when a dump is interrupted or fails
+const IncompleteMarker = "-- DUMP INCOMPLETE: "
+
+// Manifest 描述一次备份的内容及其校验信息
+type Manifest struct {
+	ToolVersion   string `json:"tool_version"`
+	ServerVersion string `json:"server_version"`
+	ServerFlavor  string `json:"server_flavor,omitempty"`
+	Host          string `json:"host"`
+	Database      string `json:"database"`
+	// Format is the format of the table data, empty for an SQL dump
+	Format      string    `json:"format,omitempty"`
+	StartedAt   time.Time `json:"started_at"`
+	CompletedAt time.Time `json:"completed_at"`
+	Tables      []Table   `json:"tables"`
+	Files       []File    `json:"files"`
+	KeyID       string    `json:"key_id,omitempty"`
+	// Failures lists the objects skipped by --continue-on-error, the backup is incomplete when it is not empty
+	Failures []Failure `json:"failures,omitempty"`
+	// Binlog is recorded with --source-data
+	Binlog *BinlogPosition `json:"binlog,omitempty"`
+}
+
+// BinlogPosition is the binary log position of the consistent snapshot a dump was read from
+type BinlogPosition struct {
+	File     string `json:"file"`
+	Position uint64 `json:"position"`
+	// GTIDSet 为 MySQL 的 gtid_executed 或 MariaDB 的 gtid_binlog_pos，未启用 GTID 时为空
+	GTIDSet string `json:"gtid_set,omitempty"`
+}
+
+// Table records what was exported for a table
+type Table struct {
+	Name       string `json:"name"`
+	Rows       int64  `json:"rows"`
+	Bytes      int64  `json:"bytes"`
+	SchemaOnly bool   `json:"schema_only,omitempty"`
+	// Retries counts the retries after transient errors needed to export the table
+	Retries int `json:"retries,omitempty"`
+}
+
+// 导出失败的对象类型
+const (
+	ObjectTableStructure = "table_structure"
+	ObjectTableData      = "table_data"
+	ObjectView           = "view"
+	ObjectTrigger        = "trigger"
+	ObjectRoutine        = "routine"
+)
+
+// Failure records an object that could not be exported
+type Failure struct {
+	Kind  string `json:"kind"`
+	Name  string `json:"name"`
+	Error string `json:"error"`
+}
+
+// File records the size and checksum of a backup file
+type File struct {
+	Name   string `json:"name"`
+	Size   int64  `json:"size"`
+	SHA256 string `json:"sha256"`
+}
+
+// AddFile hashes the file at path and records it relative to the backup directory
+func (m *Manifest) AddFile(dir, name string) error {
+	size, sum, err := HashFile(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	m.Files = append(m.Files, File{Name: name, Size: size, SHA256: sum})
+	return nil
+}
+
+// Write writes the manifest as manifest.json into dir
+func (m *Manifest) Write(dir string) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %w", err)
+	}
+	data = append(data, '\n')
+	if err := os.WriteFile(filepath.Join(dir, FileName), data, 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest: %w", err)
+	}
+	return nil
+}
+
+// Load reads a manifest, path may be the manifest file or the backup directory
+func Load(path string) (*Manifest, error) {
+	if info, err := os.Stat(path); err == nil && info.IsDir() {
+		path = filepath.Join(path, FileName)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read manifest: %w", err)
+	}
+	m := new(Manifest)
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("failed to parse manifest %s: %w", path, err)
+	}
+	return m, nil
+}
+
+// HashFile returns the size and hex encoded SHA-256 of a file
+func HashFile(path string) (int64, string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(hash, file)
+	if err != nil {
+		return 0, "", fmt.Errorf("failed to read %s: %w", path, err)
+	}
+	return size, hex.EncodeToString(hash.Sum(nil)), nil
+}
+
+// Verify checks every file listed in the manifest stored in dir and returns
+// one message per problem found, an empty slice means the backup is intact
+func Verify(dir string) ([]string, error) {
+	m, err := Load(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Files) == 0 {
+		return []string{"manifest lists no files"}, nil
+	}
+
+	var problems []string
+	for _, failure := range m.Failures {
+		problems = append(problems, fmt.Sprintf("%s %s was not exported: %s", strings.ReplaceAll(failure.Kind, "_", " "), failure.Name, failure.Error))
+	}
+	for _, f := range m.Files {
+		path := filepath.Join(dir, f.Name)
+		size, sum, err := HashFile(path)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %v", f.Name, err))
+			continue
+		}
+		if size != f.Size {
+			problems = append(problems, fmt.Sprintf("%s: size %d does not match manifest size %d", f.Name, size, f.Size))
+		}
+		if sum != f.SHA256 {
+			problems = append(problems, fmt.Sprintf("%s: sha256 %s does not match manifest %s", f.Name, sum, f.SHA256))
+		}
+		if strings.HasSuffix(f.Name, ".sql") {
+			if err := CheckCompleted(path); err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %v", f.Name, err))
+			}
+		}
+	}
+	return problems, nil
+}
+
+// CheckCompleted returns an error when the SQL dump at path does not end with
+// the completion trailer, which means the dump was truncated
+func CheckCompleted(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %w", path, err)
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return fmt.Errorf("failed to stat %s: %w", path, err)
+	}
+
+	// 只读取文件末尾，避免扫描整个备份
+	const tailSize = 4096
+	offset := info.Size() - tailSize
+	if offset < 0 {
+		offset = 0
+	}
+	tail := make([]byte, info.Size()-offset)
+	if _, err := file.ReadAt(tail, offset); err != nil && err != io.EOF {
+		return fmt.Errorf("failed to read %s: %w", path, err)
+	}
+
+	tail = bytes.TrimRight(tail, "\n")
+	lastLine := tail[bytes.LastIndexByte(tail, '\n')+1:]
+	if reason, ok := bytes.CutPrefix(lastLine, []byte(IncompleteMarker)); ok {
+		return fmt.Errorf("dump is incomplete: %s", reason)
+	}
+	if !bytes.HasPrefix(lastLine, []byte(CompletedMarker)) {
+		return fmt.Errorf("dump is truncated, missing %q trailer", strings.TrimSpace(CompletedMarker))
+	}
+	return nil
+}
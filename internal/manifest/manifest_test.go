@@ -0,0 +1,131 @@
+package manifest
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+const completeDump = "INSERT INTO `users` (`id`) VALUES ('1');\n-- Dump completed on 2026-10-19 03:00:00\n"
+
+func writeBackup(t *testing.T, content string) string {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "shop.sql"), []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write dump: %v", err)
+	}
+
+	m := &Manifest{Database: "shop", Tables: []Table{{Name: "users", Rows: 1, Bytes: 41}}}
+	if err := m.AddFile(dir, "shop.sql"); err != nil {
+		t.Fatalf("AddFile returned error: %v", err)
+	}
+	if err := m.Write(dir); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	return dir
+}
+
+func TestManifestRoundTrip(t *testing.T) {
+	dir := writeBackup(t, completeDump)
+
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	if m.Database != "shop" || len(m.Tables) != 1 || m.Tables[0].Rows != 1 {
+		t.Errorf("unexpected manifest: %+v", m)
+	}
+	if len(m.Files) != 1 || m.Files[0].Size != int64(len(completeDump)) || len(m.Files[0].SHA256) != 64 {
+		t.Errorf("unexpected files: %+v", m.Files)
+	}
+
+	problems, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if len(problems) != 0 {
+		t.Errorf("Expected no problems, got %v", problems)
+	}
+}
+
+func TestVerifyDetectsModifiedFile(t *testing.T) {
+	dir := writeBackup(t, completeDump)
+	tampered := strings.Replace(completeDump, "'1'", "'2'", 1)
+	if err := os.WriteFile(filepath.Join(dir, "shop.sql"), []byte(tampered), 0o644); err != nil {
+		t.Fatalf("Failed to modify dump: %v", err)
+	}
+
+	problems, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0], "sha256") {
+		t.Errorf("Expected a checksum problem, got %v", problems)
+	}
+}
+
+func TestVerifyDetectsTruncatedDump(t *testing.T) {
+	dir := writeBackup(t, "INSERT INTO `users` (`id`) VALUES ('1');\n")
+
+	problems, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if len(problems) != 1 || !strings.Contains(problems[0], "truncated") {
+		t.Errorf("Expected a truncation problem, got %v", problems)
+	}
+}
+
+func TestVerifyDetectsMissingFile(t *testing.T) {
+	dir := writeBackup(t, completeDump)
+	os.Remove(filepath.Join(dir, "shop.sql"))
+
+	problems, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	if len(problems) != 1 {
+		t.Errorf("Expected a missing file problem, got %v", problems)
+	}
+}
+
+func TestVerifyReportsFailedObjects(t *testing.T) {
+	dir := writeBackup(t, completeDump)
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	m.Failures = []Failure{
+		{Kind: ObjectTableData, Name: "orders", Error: "lock wait timeout"},
+		{Kind: ObjectTrigger, Name: "orders_audit", Error: "access denied"},
+	}
+	if err := m.Write(dir); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+
+	problems, err := Verify(dir)
+	if err != nil {
+		t.Fatalf("Verify returned error: %v", err)
+	}
+	want := []string{
+		"table data orders was not exported: lock wait timeout",
+		"trigger orders_audit was not exported: access denied",
+	}
+	if !slices.Equal(problems, want) {
+		t.Errorf("Expected failed object problems %v, got %v", want, problems)
+	}
+}
+
+func TestCheckCompletedReportsIncompleteDump(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "shop.sql")
+	content := "INSERT INTO `users` (`id`) VALUES ('1');\nROLLBACK;\n\n-- DUMP INCOMPLETE: interrupted by terminated\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatalf("Failed to write dump: %v", err)
+	}
+
+	err := CheckCompleted(path)
+	if err == nil || err.Error() != "dump is incomplete: interrupted by terminated" {
+		t.Errorf("CheckCompleted() error = %v, want the incomplete reason", err)
+	}
+}
@@ -0,0 +1,137 @@
+package manifest
+
+import (
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// SignatureFileName is the detached Ed25519 signature of manifest.json
+const SignatureFileName = FileName + ".sig"
+
+// ErrNoSignature is returned when a backup has no manifest signature
+var ErrNoSignature = errors.New("manifest is not signed")
+
+// KeyID returns a short fingerprint of a public key, recorded in signed manifests
+func KeyID(pub ed25519.PublicKey) string {
+	sum := sha256.Sum256(pub)
+	return hex.EncodeToString(sum[:8])
+}
+
+// GenerateKey creates a new key pair and writes it as PEM encoded PKCS#8 and PKIX files
+func GenerateKey(privatePath, publicPath string) (ed25519.PublicKey, error) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate key: %w", err)
+	}
+
+	privDER, err := x509.MarshalPKCS8PrivateKey(priv)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode private key: %w", err)
+	}
+	pubDER, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode public key: %w", err)
+	}
+
+	if err := os.WriteFile(privatePath, pem.EncodeToMemory(&pem.Block{Type: "PRIVATE KEY", Bytes: privDER}), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to write private key: %w", err)
+	}
+	if err := os.WriteFile(publicPath, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubDER}), 0o644); err != nil {
+		return nil, fmt.Errorf("failed to write public key: %w", err)
+	}
+	return pub, nil
+}
+
+// LoadPrivateKey reads a PEM encoded PKCS#8 Ed25519 private key, as written by
+// GenerateKey or `openssl genpkey -algorithm ed25519`
+func LoadPrivateKey(path string) (ed25519.PrivateKey, error) {
+	der, err := readPEM(path, "PRIVATE KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", path, err)
+	}
+	priv, ok := key.(ed25519.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("private key %s is not an Ed25519 key", path)
+	}
+	return priv, nil
+}
+
+// LoadPublicKey reads a PEM encoded PKIX Ed25519 public key
+func LoadPublicKey(path string) (ed25519.PublicKey, error) {
+	der, err := readPEM(path, "PUBLIC KEY")
+	if err != nil {
+		return nil, err
+	}
+	key, err := x509.ParsePKIXPublicKey(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key %s: %w", path, err)
+	}
+	pub, ok := key.(ed25519.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("public key %s is not an Ed25519 key", path)
+	}
+	return pub, nil
+}
+
+func readPEM(path, blockType string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key: %w", err)
+	}
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != blockType {
+		return nil, fmt.Errorf("%s does not contain a PEM %q block", path, blockType)
+	}
+	return block.Bytes, nil
+}
+
+// Sign writes the detached signature of the manifest.json stored in dir
+func Sign(dir string, priv ed25519.PrivateKey) error {
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	signature := base64.StdEncoding.EncodeToString(ed25519.Sign(priv, data))
+	if err := os.WriteFile(filepath.Join(dir, SignatureFileName), []byte(signature+"\n"), 0o644); err != nil {
+		return fmt.Errorf("failed to write manifest signature: %w", err)
+	}
+	return nil
+}
+
+// VerifySignature checks the manifest.json in dir against its detached signature
+func VerifySignature(dir string, pub ed25519.PublicKey) error {
+	encoded, err := os.ReadFile(filepath.Join(dir, SignatureFileName))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNoSignature
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read manifest signature: %w", err)
+	}
+	signature, err := base64.StdEncoding.DecodeString(strings.TrimSpace(string(encoded)))
+	if err != nil {
+		return fmt.Errorf("failed to decode manifest signature: %w", err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, FileName))
+	if err != nil {
+		return fmt.Errorf("failed to read manifest: %w", err)
+	}
+	if !ed25519.Verify(pub, data, signature) {
+		return fmt.Errorf("manifest signature does not match public key %s", KeyID(pub))
+	}
+	return nil
+}
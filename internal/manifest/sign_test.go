@@ -0,0 +1,86 @@
+package manifest
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestSignAndVerifySignature(t *testing.T) {
+	keyDir := t.TempDir()
+	privatePath := filepath.Join(keyDir, "backup.key")
+	publicPath := filepath.Join(keyDir, "backup.pub")
+	if _, err := GenerateKey(privatePath, publicPath); err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+
+	priv, err := LoadPrivateKey(privatePath)
+	if err != nil {
+		t.Fatalf("LoadPrivateKey returned error: %v", err)
+	}
+	pub, err := LoadPublicKey(publicPath)
+	if err != nil {
+		t.Fatalf("LoadPublicKey returned error: %v", err)
+	}
+
+	dir := writeBackup(t, completeDump)
+	if err := VerifySignature(dir, pub); !errors.Is(err, ErrNoSignature) {
+		t.Errorf("VerifySignature on unsigned backup = %v, want ErrNoSignature", err)
+	}
+
+	if err := Sign(dir, priv); err != nil {
+		t.Fatalf("Sign returned error: %v", err)
+	}
+	if err := VerifySignature(dir, pub); err != nil {
+		t.Errorf("VerifySignature returned error: %v", err)
+	}
+
+	// 修改 manifest 后签名应失效
+	m, err := Load(dir)
+	if err != nil {
+		t.Fatalf("Load returned error: %v", err)
+	}
+	m.Tables[0].Rows = 2
+	if err := m.Write(dir); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	if err := VerifySignature(dir, pub); err == nil {
+		t.Error("Expected signature error for modified manifest, got nil")
+	}
+}
+
+func TestVerifySignatureWithOtherKey(t *testing.T) {
+	keyDir := t.TempDir()
+	if _, err := GenerateKey(filepath.Join(keyDir, "a.key"), filepath.Join(keyDir, "a.pub")); err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	if _, err := GenerateKey(filepath.Join(keyDir, "b.key"), filepath.Join(keyDir, "b.pub")); err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+
+	priv, _ := LoadPrivateKey(filepath.Join(keyDir, "a.key"))
+	other, _ := LoadPublicKey(filepath.Join(keyDir, "b.pub"))
+
+	dir := writeBackup(t, completeDump)
+	if err := Sign(dir, priv); err != nil {
+		t.Fatalf("Sign returned error: %v", err)
+	}
+	if err := VerifySignature(dir, other); err == nil {
+		t.Error("Expected signature error for other key, got nil")
+	}
+}
+
+func TestLoadPrivateKeyRejectsPublicKey(t *testing.T) {
+	keyDir := t.TempDir()
+	publicPath := filepath.Join(keyDir, "backup.pub")
+	if _, err := GenerateKey(filepath.Join(keyDir, "backup.key"), publicPath); err != nil {
+		t.Fatalf("GenerateKey returned error: %v", err)
+	}
+	if _, err := LoadPrivateKey(publicPath); err == nil {
+		t.Error("Expected error loading a public key as private key, got nil")
+	}
+	if _, err := os.Stat(filepath.Join(keyDir, "backup.key")); err != nil {
+		t.Errorf("private key not written: %v", err)
+	}
+}
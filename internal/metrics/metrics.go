@@ -0,0 +1,106 @@
+package metrics
+
+import (
+	"io"
+	"time"
+)
+
+// 指标名称
+const (
+	LastSuccess   = "motors_backup_last_success_timestamp_seconds"
+	LastRun       = "motors_backup_last_run_timestamp_seconds"
+	LastDuration  = "motors_backup_last_duration_seconds"
+	LastBytes     = "motors_backup_last_bytes"
+	Runs          = "motors_backup_runs_total"
+	TableRows     = "motors_backup_table_rows"
+	TableDuration = "motors_backup_table_duration_seconds"
+	WrittenBytes  = "motors_backup_written_bytes_total"
+	Errors        = "motors_backup_errors_total"
+	Retries       = "motors_backup_retries_total"
+)
+
+// 错误发生的阶段
+const (
+	StageConnect        = "connect"
+	StageServerInfo     = "server_info"
+	StageSnapshot       = "snapshot"
+	StageDatabase       = "database"
+	StageListTables     = "list_tables"
+	StageTableStructure = "table_structure"
+	StageTableData      = "table_data"
+	StageViews          = "views"
+	StageTriggers       = "triggers"
+	StageRoutines       = "routines"
+	StageWrite          = "write"
+)
+
+// Default is the registry used by the exporter, the daemon and the textfile exporter
+var Default = NewRegistry()
+
+func init() {
+	Default.Describe(LastSuccess, "Unix time of the last successful backup.", TypeGauge)
+	Default.Describe(LastRun, "Unix time of the last backup attempt.", TypeGauge)
+	Default.Describe(LastDuration, "Duration of the last backup attempt in seconds.", TypeGauge)
+	Default.Describe(LastBytes, "Bytes written by the last backup attempt.", TypeGauge)
+	Default.Describe(Runs, "Backup runs by result.", TypeCounter)
+	Default.Describe(TableRows, "Rows exported from a table by the last export of the table.", TypeGauge)
+	Default.Describe(TableDuration, "Duration of the last data export of a table in seconds.", TypeGauge)
+	Default.Describe(WrittenBytes, "Bytes of backup output written.", TypeCounter)
+	Default.Describe(Errors, "Backup errors by stage.", TypeCounter)
+	Default.Describe(Retries, "Retries after transient errors by stage.", TypeCounter)
+}
+
+// ObserveRun records the outcome of a whole backup run
+func ObserveRun(job, database string, start time.Time, bytes int64, err error) {
+	labels := Labels{"job": job, "database": database}
+	end := time.Now()
+
+	Default.Set(LastRun, labels, float64(end.Unix()))
+	Default.Set(LastDuration, labels, end.Sub(start).Seconds())
+	Default.Set(LastBytes, labels, float64(bytes))
+
+	result := "success"
+	if err != nil {
+		result = "failed"
+	} else {
+		Default.Set(LastSuccess, labels, float64(end.Unix()))
+	}
+	Default.Add(Runs, Labels{"job": job, "database": database, "result": result}, 1)
+}
+
+// ObserveTable records the rows and duration of a table data export
+func ObserveTable(database, table string, rows int64, duration time.Duration) {
+	labels := Labels{"database": database, "table": table}
+	Default.Set(TableRows, labels, float64(rows))
+	Default.Set(TableDuration, labels, duration.Seconds())
+}
+
+// ObserveError counts an error in the given stage
+func ObserveError(database, stage string) {
+	Default.Add(Errors, Labels{"database": database, "stage": stage}, 1)
+}
+
+// ObserveRetry counts a retry after a transient error in the given stage
+func ObserveRetry(database, stage string) {
+	Default.Add(Retries, Labels{"database": database, "stage": stage}, 1)
+}
+
+// Writer counts the bytes written for a database and the write errors
+type Writer struct {
+	w        io.Writer
+	database string
+}
+
+// NewWriter instruments w with the written bytes and write error metrics
+func NewWriter(w io.Writer, database string) *Writer {
+	return &Writer{w: w, database: database}
+}
+
+func (m *Writer) Write(p []byte) (int, error) {
+	n, err := m.w.Write(p)
+	Default.Add(WrittenBytes, Labels{"database": m.database}, float64(n))
+	if err != nil {
+		ObserveError(m.database, StageWrite)
+	}
+	return n, err
+}
@@ -0,0 +1,196 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// Labels are the label names and values of a series
+type Labels map[string]string
+
+const (
+	TypeGauge   = "gauge"
+	TypeCounter = "counter"
+)
+
+// Registry 保存所有指标，并以 Prometheus 文本格式输出
+type Registry struct {
+	mu       sync.Mutex
+	families map[string]*family
+}
+
+type family struct {
+	name   string
+	help   string
+	typ    string
+	series map[string]*series
+}
+
+type series struct {
+	labels string
+	value  float64
+}
+
+// NewRegistry creates an empty registry
+func NewRegistry() *Registry {
+	return &Registry{families: make(map[string]*family)}
+}
+
+// Describe registers the help text and type of a metric family
+func (r *Registry) Describe(name, help, typ string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.family(name).help = help
+	r.family(name).typ = typ
+}
+
+// Set sets the value of a gauge
+func (r *Registry) Set(name string, labels Labels, value float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name, labels).value = value
+}
+
+// Add adds delta to the value of a counter
+func (r *Registry) Add(name string, labels Labels, delta float64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.series(name, labels).value += delta
+}
+
+// Value returns the current value of a series, mostly useful for tests
+func (r *Registry) Value(name string, labels Labels) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.family(name).series[encodeLabels(labels)]; ok {
+		return s.value
+	}
+	return 0
+}
+
+func (r *Registry) family(name string) *family {
+	f, ok := r.families[name]
+	if !ok {
+		f = &family{name: name, typ: TypeGauge, series: make(map[string]*series)}
+		r.families[name] = f
+	}
+	return f
+}
+
+func (r *Registry) series(name string, labels Labels) *series {
+	f := r.family(name)
+	key := encodeLabels(labels)
+	s, ok := f.series[key]
+	if !ok {
+		s = &series{labels: key}
+		f.series[key] = s
+	}
+	return s
+}
+
+// WriteText writes all metrics in the Prometheus text exposition format
+func (r *Registry) WriteText(w io.Writer) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	names := make([]string, 0, len(r.families))
+	for name, f := range r.families {
+		if len(f.series) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		f := r.families[name]
+		if f.help != "" {
+			if _, err := fmt.Fprintf(w, "# HELP %s %s\n", name, f.help); err != nil {
+				return err
+			}
+		}
+		if _, err := fmt.Fprintf(w, "# TYPE %s %s\n", name, f.typ); err != nil {
+			return err
+		}
+
+		keys := make([]string, 0, len(f.series))
+		for key := range f.series {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			value := strconv.FormatFloat(f.series[key].value, 'g', -1, 64)
+			if _, err := fmt.Fprintf(w, "%s%s %s\n", name, key, value); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+// WriteTextfile atomically writes the metrics for the node_exporter textfile collector
+func (r *Registry) WriteTextfile(path string) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp*")
+	if err != nil {
+		return fmt.Errorf("failed to create metrics textfile: %w", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := r.WriteText(tmp); err != nil {
+		tmp.Close()
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	if err := tmp.Close(); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	// node_exporter 要求文件可读，CreateTemp 默认权限为 0600
+	if err := os.Chmod(tmp.Name(), 0o644); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write metrics textfile: %w", err)
+	}
+	return nil
+}
+
+// Handler serves the metrics over HTTP
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.WriteText(w)
+	})
+}
+
+// encodeLabels 按标签名排序生成 {a="1",b="2"} 形式的字符串
+func encodeLabels(labels Labels) string {
+	if len(labels) == 0 {
+		return ""
+	}
+	names := make([]string, 0, len(labels))
+	for name := range labels {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var sb strings.Builder
+	sb.WriteByte('{')
+	for i, name := range names {
+		if i > 0 {
+			sb.WriteByte(',')
+		}
+		sb.WriteString(name)
+		sb.WriteString(`="`)
+		sb.WriteString(labelReplacer.Replace(labels[name]))
+		sb.WriteByte('"')
+	}
+	sb.WriteByte('}')
+	return sb.String()
+}
+
+var labelReplacer = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
@@ -0,0 +1,91 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestWriteText(t *testing.T) {
+	r := NewRegistry()
+	r.Describe("backup_rows", "Rows exported.", TypeGauge)
+	r.Describe("backup_errors_total", "Errors.", TypeCounter)
+	r.Set("backup_rows", Labels{"table": "users", "database": "shop"}, 42)
+	r.Set("backup_rows", Labels{"table": "orders", "database": "shop"}, 7)
+	r.Add("backup_errors_total", Labels{"stage": "connect"}, 1)
+	r.Add("backup_errors_total", Labels{"stage": "connect"}, 2)
+	r.Set("backup_label_escape", Labels{"table": "a\"b\\c\nd"}, 1.5)
+
+	var buf bytes.Buffer
+	if err := r.WriteText(&buf); err != nil {
+		t.Fatalf("WriteText returned error: %v", err)
+	}
+
+	want := `# HELP backup_errors_total Errors.
+# TYPE backup_errors_total counter
+backup_errors_total{stage="connect"} 3
+# TYPE backup_label_escape gauge
+backup_label_escape{table="a\"b\\c\nd"} 1.5
+# HELP backup_rows Rows exported.
+# TYPE backup_rows gauge
+backup_rows{database="shop",table="orders"} 7
+backup_rows{database="shop",table="users"} 42
+`
+	if buf.String() != want {
+		t.Errorf("WriteText output:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteTextfile(t *testing.T) {
+	r := NewRegistry()
+	r.Set("backup_rows", nil, 1)
+
+	path := filepath.Join(t.TempDir(), "motors_backup.prom")
+	if err := r.WriteTextfile(path); err != nil {
+		t.Fatalf("WriteTextfile returned error: %v", err)
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("Failed to read textfile: %v", err)
+	}
+	if string(data) != "# TYPE backup_rows gauge\nbackup_rows 1\n" {
+		t.Errorf("unexpected textfile content %q", data)
+	}
+	matches, _ := filepath.Glob(path + ".tmp*")
+	if len(matches) != 0 {
+		t.Errorf("temporary files left behind: %v", matches)
+	}
+}
+
+func TestObserveRun(t *testing.T) {
+	ObserveRun("nightly", "metrics_test", time.Now().Add(-time.Second), 1024, nil)
+	ObserveRun("nightly", "metrics_test", time.Now(), 10, errors.New("boom"))
+
+	labels := Labels{"job": "nightly", "database": "metrics_test"}
+	if Default.Value(LastSuccess, labels) == 0 {
+		t.Error("Expected last success timestamp to be set")
+	}
+	if Default.Value(LastBytes, labels) != 10 {
+		t.Errorf("LastBytes = %v, want 10", Default.Value(LastBytes, labels))
+	}
+	if Default.Value(Runs, Labels{"job": "nightly", "database": "metrics_test", "result": "failed"}) != 1 {
+		t.Error("Expected one failed run")
+	}
+}
+
+func TestWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf, "writer_test")
+	w.Write([]byte("INSERT INTO"))
+
+	if v := Default.Value(WrittenBytes, Labels{"database": "writer_test"}); v != 11 {
+		t.Errorf("WrittenBytes = %v, want 11", v)
+	}
+	if !strings.Contains(buf.String(), "INSERT") {
+		t.Error("Writer did not pass the data through")
+	}
+}
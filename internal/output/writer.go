@@ -0,0 +1,43 @@
+package output
+
+import (
+	"fmt"
+	"io"
+	"sync/atomic"
+)
+
+// CountingWriter counts the bytes written to the underlying writer
+type CountingWriter struct {
+	w     io.Writer
+	count atomic.Int64
+}
+
+// NewCountingWriter wraps w to count the bytes written to it
+func NewCountingWriter(w io.Writer) *CountingWriter {
+	return &CountingWriter{w: w}
+}
+
+func (c *CountingWriter) Write(p []byte) (int, error) {
+	n, err := c.w.Write(p)
+	c.count.Add(int64(n))
+	return n, err
+}
+
+// Count returns the number of bytes written so far
+func (c *CountingWriter) Count() int64 {
+	return c.count.Load()
+}
+
+// FormatBytes formats a byte count with binary units
+func FormatBytes(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for v := n / unit; v >= unit; v /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %ciB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,34 @@
+package output
+
+import (
+	"bytes"
+	"testing"
+)
+
+func TestCountingWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewCountingWriter(&buf)
+	w.Write([]byte("INSERT"))
+	w.Write([]byte(" INTO;\n"))
+	if w.Count() != 13 || buf.Len() != 13 {
+		t.Errorf("Count = %d, buffer = %d, want 13", w.Count(), buf.Len())
+	}
+}
+
+func TestFormatBytes(t *testing.T) {
+	testCases := map[int64]string{
+		0:                  "0 B",
+		1023:               "1023 B",
+		1024:               "1.0 KiB",
+		1536:               "1.5 KiB",
+		5 * 1024 * 1024:    "5.0 MiB",
+		3 << 30:            "3.0 GiB",
+		int64(1.5 * 1e12):  "1.4 TiB",
+		int64(1024 * 1024): "1.0 MiB",
+	}
+	for n, want := range testCases {
+		if got := FormatBytes(n); got != want {
+			t.Errorf("FormatBytes(%d) = %s, want %s", n, got, want)
+		}
+	}
+}
@@ -0,0 +1,171 @@
+package plan
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"motors-backup/internal/flavor"
+	"motors-backup/internal/output"
+	"motors-backup/internal/schema"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+// TablePlan 描述一个表在导出时会涉及的内容
+type TablePlan struct {
+	Name             string
+	WithStructure    bool
+	WithData         bool
+	EstimatedRows    int64
+	DataBytes        int64
+	Columns          int
+	GeneratedColumns int
+	Where            string
+	WhereError       error
+}
+
+// Content describes what is exported for the table
+func (p *TablePlan) Content() string {
+	switch {
+	case p.WithStructure && p.WithData:
+		return "structure+data"
+	case p.WithStructure:
+		return "structure"
+	case p.WithData:
+		return "data"
+	default:
+		return "-"
+	}
+}
+
+// Build collects the estimates for the given tables without reading any table data, withStructure
+// reports whether CREATE TABLE statements are written, withData whether the data of a table is
+// exported and where returns its WHERE condition
+func Build(ctx context.Context, db *sql.DB, server flavor.Server, dbName string, tables []string, withStructure bool, withData func(table string) bool, where func(table string) string) ([]TablePlan, error) {
+	stats, err := schema.GetTableStats(ctx, db, dbName)
+	if err != nil {
+		return nil, err
+	}
+
+	plans := make([]TablePlan, 0, len(tables))
+	for _, table := range tables {
+		columns, err := schema.AnalyzeColumns(ctx, db, server, dbName, table)
+		if err != nil {
+			return nil, fmt.Errorf("failed to analyze columns of %s: %w", table, err)
+		}
+		nonGeneratedColumns := schema.GetNonGeneratedColumns(columns)
+
+		p := TablePlan{
+			Name:             table,
+			WithStructure:    withStructure,
+			WithData:         withData(table),
+			EstimatedRows:    stats[table].Rows,
+			DataBytes:        stats[table].DataLength,
+			Columns:          len(nonGeneratedColumns),
+			GeneratedColumns: len(columns) - len(nonGeneratedColumns),
+		}
+
+		// 使用 EXPLAIN 校验 WHERE 条件，同时得到更准确的行数估算
+		if condition := where(table); p.WithData && condition != "" {
+			p.Where = condition
+			rows, err := explainRows(ctx, db, table, nonGeneratedColumns, condition)
+			if err != nil && ctx.Err() != nil {
+				return nil, err
+			} else if err != nil {
+				p.WhereError = err
+			} else {
+				p.EstimatedRows = rows
+			}
+		}
+
+		plans = append(plans, p)
+	}
+
+	return plans, nil
+}
+
+// explainRows 对导出查询执行 EXPLAIN，返回优化器估算的匹配行数
+func explainRows(ctx context.Context, db *sql.DB, table string, columns []string, where string) (int64, error) {
+	columnList := "`" + strings.Join(columns, "`, `") + "`"
+	query := fmt.Sprintf("EXPLAIN SELECT %s FROM `%s` WHERE %s", columnList, table, where)
+
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return 0, err
+	}
+	defer rows.Close()
+
+	names, err := rows.Columns()
+	if err != nil {
+		return 0, fmt.Errorf("failed to get EXPLAIN columns: %w", err)
+	}
+
+	values := make([]sql.NullString, len(names))
+	params := make([]interface{}, len(names))
+	for i := range values {
+		params[i] = &values[i]
+	}
+
+	var estimate int64
+	if rows.Next() {
+		if err := rows.Scan(params...); err != nil {
+			return 0, fmt.Errorf("failed to scan EXPLAIN output: %w", err)
+		}
+		var estimatedRows, filtered float64 = 0, 100
+		for i, name := range names {
+			switch name {
+			case "rows":
+				estimatedRows, _ = strconv.ParseFloat(values[i].String, 64)
+			case "filtered":
+				if values[i].Valid {
+					filtered, _ = strconv.ParseFloat(values[i].String, 64)
+				}
+			}
+		}
+		estimate = int64(estimatedRows * filtered / 100)
+	}
+
+	return estimate, rows.Err()
+}
+
+// Print writes the plan as a table, rowsPerSecond is used to estimate the duration.
+// The data of a table is read with a single query, so there is no chunk count to show
+func Print(w io.Writer, plans []TablePlan, rowsPerSecond float64) {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TABLE\tCONTENT\tEST. ROWS\tDATA SIZE\tCOLUMNS\tWHERE")
+
+	var totalRows, totalBytes int64
+	for _, p := range plans {
+		if p.WithData {
+			totalRows += p.EstimatedRows
+			totalBytes += p.DataBytes
+		}
+
+		columns := strconv.Itoa(p.Columns)
+		if p.GeneratedColumns > 0 {
+			columns = fmt.Sprintf("%d (+%d generated)", p.Columns, p.GeneratedColumns)
+		}
+
+		where := "-"
+		if p.WhereError != nil {
+			where = "INVALID: " + p.WhereError.Error()
+		} else if p.Where != "" {
+			where = "ok"
+		}
+
+		fmt.Fprintf(tw, "%s\t%s\t%d\t%s\t%s\t%s\n",
+			p.Name, p.Content(), p.EstimatedRows, output.FormatBytes(p.DataBytes), columns, where)
+	}
+	tw.Flush()
+
+	fmt.Fprintln(w)
+	fmt.Fprintf(w, "Tables: %d    Estimated rows: %d    Data size: %s\n",
+		len(plans), totalRows, output.FormatBytes(totalBytes))
+	if rowsPerSecond > 0 {
+		duration := time.Duration(float64(totalRows) / rowsPerSecond * float64(time.Second))
+		fmt.Fprintf(w, "Estimated duration: %s (at %.0f rows/s)\n", duration.Round(time.Second), rowsPerSecond)
+	}
+}
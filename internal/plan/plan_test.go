@@ -0,0 +1,56 @@
+package plan
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPrint(t *testing.T) {
+	plans := []TablePlan{
+		{Name: "users", WithStructure: true, WithData: true, EstimatedRows: 40000, DataBytes: 2 << 20, Columns: 5, GeneratedColumns: 1, Where: "id>100"},
+		{Name: "logs", WithStructure: true, WithData: false, EstimatedRows: 900000, DataBytes: 1 << 30, Columns: 3},
+		{Name: "orders", WithStructure: true, WithData: true, EstimatedRows: 10, Columns: 4, Where: "user_id>0", WhereError: errors.New("Unknown column 'user_id'")},
+	}
+
+	var buf bytes.Buffer
+	Print(&buf, plans, 20000)
+	out := buf.String()
+
+	for _, want := range []string{
+		"users   structure+data  40000",
+		"5 (+1 generated)",
+		"logs    structure",
+		"INVALID: Unknown column 'user_id'",
+		"Tables: 3    Estimated rows: 40010    Data size: 2.0 MiB\n",
+		"Estimated duration: 2s",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan output missing %q:\n%s", want, out)
+		}
+	}
+}
+
+func TestPrintDataOnly(t *testing.T) {
+	plans := []TablePlan{
+		{Name: "users", WithData: true, EstimatedRows: 40000, DataBytes: 2 << 20, Columns: 5},
+		{Name: "logs", EstimatedRows: 900000, DataBytes: 1 << 30, Columns: 3},
+	}
+	var buf bytes.Buffer
+	Print(&buf, plans, 0)
+	out := buf.String()
+	for _, want := range []string{
+		"TABLE  CONTENT  EST. ROWS  DATA SIZE  COLUMNS  WHERE",
+		"users  data     40000",
+		"logs   -        900000",
+		"Tables: 2    Estimated rows: 40000    Data size: 2.0 MiB\n",
+	} {
+		if !strings.Contains(out, want) {
+			t.Errorf("plan output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "structure") {
+		t.Errorf("data-only plan mentions structure:\n%s", out)
+	}
+}
@@ -0,0 +1,271 @@
+package progress
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"motors-backup/internal/log"
+	"motors-backup/internal/output"
+	"os"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	ModeAuto = "auto"
+	ModeBar  = "bar"
+	ModeLog  = "log"
+	ModeNone = "none"
+)
+
+// barWidth is the number of characters of the progress bar
+const barWidth = 30
+
+// Reporter 根据已导出的行数和 information_schema 中的估算行数报告导出进度，
+// 进度条写入 stderr，日志模式输出结构化日志，不会混入标准输出中的 SQL
+type Reporter struct {
+	mode     string
+	out      io.Writer
+	interval time.Duration
+	bytes    func() int64
+
+	totalEstimate int64
+	totalRows     atomic.Int64
+	tableRows     atomic.Int64
+	started       time.Time
+
+	mu            sync.Mutex
+	table         string
+	tableEstimate int64
+	tableStarted  time.Time
+	tables        int
+
+	// outMu 保证进度条和完成信息不会交错输出
+	outMu   sync.Mutex
+	done    chan struct{}
+	stopped chan struct{}
+}
+
+// ResolveMode turns auto into bar when f is a terminal and into log lines otherwise
+func ResolveMode(mode string, f *os.File) (string, error) {
+	switch mode {
+	case ModeBar, ModeLog, ModeNone:
+		return mode, nil
+	case "", ModeAuto:
+		if IsTerminal(f) {
+			return ModeBar, nil
+		}
+		return ModeLog, nil
+	}
+	return "", fmt.Errorf("invalid progress mode %q, expected auto, bar, log or none", mode)
+}
+
+// IsTerminal reports whether f is attached to a terminal
+func IsTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	return info.Mode()&os.ModeCharDevice != 0
+}
+
+// New creates a reporter, totalEstimate is the estimated number of rows of all
+// tables and bytes returns the number of bytes written so far
+func New(mode string, out io.Writer, interval time.Duration, totalEstimate int64, bytes func() int64) *Reporter {
+	if interval <= 0 {
+		interval = 10 * time.Second
+	}
+	if mode == ModeBar {
+		interval = 500 * time.Millisecond
+	}
+	return &Reporter{
+		mode:          mode,
+		out:           out,
+		interval:      interval,
+		bytes:         bytes,
+		totalEstimate: totalEstimate,
+		done:          make(chan struct{}),
+		stopped:       make(chan struct{}),
+	}
+}
+
+// Start starts reporting in the background
+func (r *Reporter) Start() {
+	r.started = time.Now()
+	if r.mode == ModeNone {
+		close(r.stopped)
+		return
+	}
+
+	go func() {
+		defer close(r.stopped)
+		ticker := time.NewTicker(r.interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.report()
+			case <-r.done:
+				return
+			}
+		}
+	}()
+}
+
+// StartTable marks the beginning of a table export
+func (r *Reporter) StartTable(table string, estimate int64) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.table = table
+	r.tableEstimate = estimate
+	r.tableStarted = time.Now()
+	r.tableRows.Store(0)
+}
+
+// AddRow records one exported row, safe to call for every row
+func (r *Reporter) AddRow() {
+	r.tableRows.Add(1)
+	r.totalRows.Add(1)
+}
+
+// FinishTable marks the end of the current table export
+func (r *Reporter) FinishTable() {
+	r.mu.Lock()
+	table, rows, elapsed := r.table, r.tableRows.Load(), time.Since(r.tableStarted)
+	r.table = ""
+	r.tables++
+	r.mu.Unlock()
+
+	switch r.mode {
+	case ModeBar:
+		r.println(fmt.Sprintf("%s done: %d rows in %s (%s)", table, rows, elapsed.Round(time.Millisecond), rate(rows, elapsed)))
+	case ModeLog:
+		log.Logger.Info("table exported",
+			log.KeyStage, "table_data",
+			log.KeyTable, table,
+			log.KeyRows, rows,
+			log.Duration(elapsed))
+	}
+}
+
+// Stop stops reporting and prints the overall summary
+func (r *Reporter) Stop() {
+	close(r.done)
+	<-r.stopped
+	elapsed := time.Since(r.started)
+	rows := r.totalRows.Load()
+	switch r.mode {
+	case ModeBar:
+		r.println(fmt.Sprintf("Exported %d tables, %d rows, %s in %s (%s)",
+			r.tables, rows, output.FormatBytes(r.bytes()), elapsed.Round(time.Second), rate(rows, elapsed)))
+	case ModeLog:
+		log.Logger.Info("export finished",
+			"tables", r.tables,
+			log.KeyRows, rows,
+			"bytes", r.bytes(),
+			log.Duration(elapsed))
+	}
+}
+
+// report 输出一次当前进度
+func (r *Reporter) report() {
+	r.mu.Lock()
+	table, tableEstimate := r.table, r.tableEstimate
+	r.mu.Unlock()
+
+	tableRows, elapsed := r.tableRows.Load(), time.Since(r.started)
+	if r.mode == ModeBar {
+		r.outMu.Lock()
+		fmt.Fprintf(r.out, "\r\033[K%s", r.line(table, tableRows, tableEstimate, elapsed))
+		r.outMu.Unlock()
+		return
+	}
+
+	totalRows := r.totalRows.Load()
+	args := []any{
+		log.KeyTable, table,
+		log.KeyRows, tableRows,
+		"table_estimated_rows", tableEstimate,
+		"total_rows", totalRows,
+		"total_estimated_rows", r.totalEstimate,
+		"rows_per_sec", int64(float64(totalRows) / elapsed.Seconds()),
+		"bytes", r.bytes(),
+	}
+	if eta, ok := r.eta(totalRows, elapsed); ok {
+		args = append(args, slog.Duration("eta", eta))
+	}
+	log.Logger.Info("export progress", args...)
+}
+
+// println 清除当前进度条后输出一行完整的信息
+func (r *Reporter) println(line string) {
+	r.outMu.Lock()
+	fmt.Fprintf(r.out, "\r\033[K%s\n", line)
+	r.outMu.Unlock()
+}
+
+// eta 根据目前的平均速度估算剩余时间
+func (r *Reporter) eta(totalRows int64, elapsed time.Duration) (time.Duration, bool) {
+	if totalRows == 0 || r.totalEstimate <= totalRows {
+		return 0, false
+	}
+	return time.Duration(float64(elapsed) * float64(r.totalEstimate-totalRows) / float64(totalRows)), true
+}
+
+// line formats the progress of the current table and the whole export
+func (r *Reporter) line(table string, tableRows, tableEstimate int64, elapsed time.Duration) string {
+	totalRows := r.totalRows.Load()
+	var sb strings.Builder
+
+	if table != "" {
+		fmt.Fprintf(&sb, "%s %d/%s rows", table, tableRows, estimate(tableEstimate))
+		if tableEstimate > 0 {
+			fmt.Fprintf(&sb, " %s", percent(tableRows, tableEstimate))
+		}
+		sb.WriteString(" | ")
+	}
+
+	if r.mode == ModeBar {
+		filled := int(fraction(totalRows, r.totalEstimate) * barWidth)
+		fmt.Fprintf(&sb, "[%s%s] ", strings.Repeat("=", filled), strings.Repeat(" ", barWidth-filled))
+	}
+	fmt.Fprintf(&sb, "total %d/%s rows %s, %s, %s written",
+		totalRows, estimate(r.totalEstimate), percent(totalRows, r.totalEstimate), rate(totalRows, elapsed), output.FormatBytes(r.bytes()))
+
+	if eta, ok := r.eta(totalRows, elapsed); ok {
+		fmt.Fprintf(&sb, ", ETA %s", eta.Round(time.Second))
+	}
+	return sb.String()
+}
+
+// fraction 返回完成比例，估算行数可能不准确，因此上限为 1
+func fraction(done, total int64) float64 {
+	if total <= 0 {
+		return 0
+	}
+	f := float64(done) / float64(total)
+	if f > 1 {
+		return 1
+	}
+	return f
+}
+
+func percent(done, total int64) string {
+	return fmt.Sprintf("%.0f%%", fraction(done, total)*100)
+}
+
+func estimate(n int64) string {
+	if n <= 0 {
+		return "?"
+	}
+	return fmt.Sprintf("~%d", n)
+}
+
+func rate(rows int64, elapsed time.Duration) string {
+	if elapsed <= 0 {
+		return "0 rows/s"
+	}
+	return fmt.Sprintf("%.0f rows/s", float64(rows)/elapsed.Seconds())
+}
@@ -0,0 +1,70 @@
+package progress
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveMode(t *testing.T) {
+	file, err := os.CreateTemp(t.TempDir(), "stderr")
+	if err != nil {
+		t.Fatalf("Failed to create file: %v", err)
+	}
+	defer file.Close()
+
+	// 普通文件不是终端，auto 应回退为日志输出
+	if mode, err := ResolveMode(ModeAuto, file); err != nil || mode != ModeLog {
+		t.Errorf("ResolveMode(auto) = %s, %v, want log", mode, err)
+	}
+	if mode, err := ResolveMode(ModeBar, file); err != nil || mode != ModeBar {
+		t.Errorf("ResolveMode(bar) = %s, %v, want bar", mode, err)
+	}
+	if _, err := ResolveMode("fancy", file); err == nil {
+		t.Error("Expected error for invalid mode, got nil")
+	}
+}
+
+func TestLine(t *testing.T) {
+	r := New(ModeLog, nil, time.Second, 1000, func() int64 { return 2048 })
+	for i := 0; i < 250; i++ {
+		r.AddRow()
+	}
+
+	line := r.line("users", 250, 500, 10*time.Second)
+	for _, want := range []string{"users 250/~500 rows 50%", "total 250/~1000 rows 25%", "25 rows/s", "2.0 KiB written", "ETA 30s"} {
+		if !strings.Contains(line, want) {
+			t.Errorf("line %q missing %q", line, want)
+		}
+	}
+}
+
+func TestLineWithoutEstimate(t *testing.T) {
+	r := New(ModeBar, nil, time.Second, 0, func() int64 { return 0 })
+	r.AddRow()
+
+	line := r.line("users", 1, 0, time.Second)
+	if !strings.Contains(line, "users 1/? rows |") || strings.Contains(line, "ETA") {
+		t.Errorf("unexpected line %q", line)
+	}
+	if !strings.Contains(line, "["+strings.Repeat(" ", barWidth)+"]") {
+		t.Errorf("expected empty bar in %q", line)
+	}
+}
+
+func TestBarOutput(t *testing.T) {
+	var out bytes.Buffer
+	r := New(ModeBar, &out, time.Hour, 2, func() int64 { return 10 })
+	r.Start()
+	r.StartTable("users", 2)
+	r.AddRow()
+	r.AddRow()
+	r.FinishTable()
+	r.Stop()
+
+	if !strings.Contains(out.String(), "users done: 2 rows") || !strings.Contains(out.String(), "Exported 1 tables, 2 rows") {
+		t.Errorf("unexpected output %q", out.String())
+	}
+}
@@ -0,0 +1,112 @@
+package restore
+
+import (
+	"errors"
+	"fmt"
+	"motors-backup/internal/binlog"
+	"motors-backup/internal/manifest"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Target is the point a restore stops at, either a time or the GTID of the last transaction to replay
+type Target struct {
+	Time time.Time
+	GTID string
+}
+
+func (t Target) String() string {
+	if t.GTID != "" {
+		return "GTID " + t.GTID
+	}
+	return t.Time.Format("2006-01-02 15:04:05 MST")
+}
+
+// Backup is a backup directory with its manifest
+type Backup struct {
+	Dir      string
+	Manifest *manifest.Manifest
+}
+
+// LoadCatalog reads the manifests of dir and of its subdirectories, such as the directories the
+// daemon writes for every run, ordered by completion time
+func LoadCatalog(dir string) ([]Backup, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read backup catalog: %w", err)
+	}
+	dirs := []string{dir}
+	for _, entry := range entries {
+		if entry.IsDir() {
+			dirs = append(dirs, filepath.Join(dir, entry.Name()))
+		}
+	}
+
+	var backups []Backup
+	for _, d := range dirs {
+		if _, err := os.Stat(filepath.Join(d, manifest.FileName)); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		m, err := manifest.Load(d)
+		if err != nil {
+			return nil, err
+		}
+		backups = append(backups, Backup{Dir: d, Manifest: m})
+	}
+	slices.SortFunc(backups, func(a, b Backup) int {
+		return a.Manifest.CompletedAt.Compare(b.Manifest.CompletedAt)
+	})
+	return backups, nil
+}
+
+// Select returns the latest backup the target can be reached from by replaying the binary log.
+// Only complete SQL backups taken with --source-data qualify. For a time the backup must have completed
+// before it, as the snapshot is taken at some point between the start and the end of the backup.
+// database may be empty when all backups of the catalog belong to one database
+func Select(backups []Backup, database string, target Target) (*Backup, error) {
+	var candidates []Backup
+	databases := make(map[string]bool)
+	for _, b := range backups {
+		m := b.Manifest
+		// 只有 SQL 导出能用于恢复，CSV 等格式没有表结构
+		if m.Binlog == nil || len(m.Failures) > 0 || m.Format != "" || database != "" && m.Database != database {
+			continue
+		}
+		candidates = append(candidates, b)
+		databases[m.Database] = true
+	}
+	if len(candidates) == 0 {
+		return nil, fmt.Errorf("no complete backup with a binary log position (taken with --source-data) found")
+	}
+	if len(databases) > 1 {
+		return nil, fmt.Errorf("the catalog holds backups of %d databases, choose one with --database", len(databases))
+	}
+
+	var gtid binlog.GTID
+	if target.GTID != "" {
+		var err error
+		if gtid, err = binlog.ParseGTID(target.GTID); err != nil {
+			return nil, err
+		}
+	}
+
+	for i := len(candidates) - 1; i >= 0; i-- {
+		b := candidates[i]
+		if target.GTID == "" {
+			if !b.Manifest.CompletedAt.After(target.Time) {
+				return &b, nil
+			}
+			continue
+		}
+		included, err := binlog.Includes(b.Manifest.Binlog.GTIDSet, gtid)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", b.Dir, err)
+		}
+		if b.Manifest.Binlog.GTIDSet != "" && !included {
+			return &b, nil
+		}
+	}
+	return nil, fmt.Errorf("no backup found before %s", target)
+}
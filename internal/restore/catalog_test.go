@@ -0,0 +1,84 @@
+package restore
+
+import (
+	"motors-backup/internal/manifest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func writeManifest(t *testing.T, dir string, m *manifest.Manifest) {
+	t.Helper()
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := m.Write(dir); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestSelect(t *testing.T) {
+	root := t.TempDir()
+	day := time.Date(2026, 10, 1, 0, 0, 0, 0, time.UTC)
+	sid := "3e11fa47-71ca-11e1-9e33-c80aa9429562"
+	writeManifest(t, filepath.Join(root, "shop-1"), &manifest.Manifest{Database: "shop", CompletedAt: day.Add(1 * time.Hour),
+		Binlog: &manifest.BinlogPosition{File: "binlog.000001", Position: 4, GTIDSet: sid + ":1-10"}})
+	writeManifest(t, filepath.Join(root, "shop-2"), &manifest.Manifest{Database: "shop", CompletedAt: day.Add(2 * time.Hour),
+		Binlog: &manifest.BinlogPosition{File: "binlog.000002", Position: 4, GTIDSet: sid + ":1-20"}})
+	// 不完整的备份和没有 binlog 位置的备份不能作为起点
+	writeManifest(t, filepath.Join(root, "shop-3"), &manifest.Manifest{Database: "shop", CompletedAt: day.Add(3 * time.Hour),
+		Binlog:   &manifest.BinlogPosition{File: "binlog.000003", Position: 4, GTIDSet: sid + ":1-30"},
+		Failures: []manifest.Failure{{Kind: manifest.ObjectTableData, Name: "orders"}}})
+	writeManifest(t, filepath.Join(root, "shop-4"), &manifest.Manifest{Database: "shop", CompletedAt: day.Add(4 * time.Hour)})
+	// CSV 导出没有表结构，不能用于恢复
+	writeManifest(t, filepath.Join(root, "shop-5"), &manifest.Manifest{Database: "shop", CompletedAt: day.Add(5 * time.Hour),
+		Format: "csv", Binlog: &manifest.BinlogPosition{File: "binlog.000005", Position: 4, GTIDSet: sid + ":1-50"}})
+
+	catalog, err := LoadCatalog(root)
+	if err != nil {
+		t.Fatalf("LoadCatalog returned error: %v", err)
+	}
+	if len(catalog) != 5 {
+		t.Fatalf("got %d backups, want 5", len(catalog))
+	}
+
+	tests := []struct {
+		target Target
+		want   string
+	}{
+		{target: Target{Time: day.Add(5 * time.Hour)}, want: "shop-2"},
+		{target: Target{Time: day.Add(6 * time.Hour)}, want: "shop-2"},
+		{target: Target{Time: day.Add(90 * time.Minute)}, want: "shop-1"},
+		{target: Target{Time: day}, want: ""},
+		{target: Target{GTID: sid + ":25"}, want: "shop-2"},
+		{target: Target{GTID: sid + ":15"}, want: "shop-1"},
+		{target: Target{GTID: sid + ":5"}, want: ""},
+	}
+	for _, tt := range tests {
+		backup, err := Select(catalog, "", tt.target)
+		if tt.want == "" {
+			if err == nil {
+				t.Errorf("Select(%s) = %s, want error", tt.target, backup.Dir)
+			}
+			continue
+		}
+		if err != nil || filepath.Base(backup.Dir) != tt.want {
+			t.Errorf("Select(%s) = %v, %v, want %s", tt.target, backup, err, tt.want)
+		}
+	}
+
+	writeManifest(t, filepath.Join(root, "crm-1"), &manifest.Manifest{Database: "crm", CompletedAt: day.Add(time.Hour),
+		Binlog: &manifest.BinlogPosition{File: "binlog.000001", Position: 4}})
+	catalog, err = LoadCatalog(root)
+	if err != nil {
+		t.Fatalf("LoadCatalog returned error: %v", err)
+	}
+	if _, err := Select(catalog, "", Target{Time: day.Add(5 * time.Hour)}); err == nil || !strings.Contains(err.Error(), "--database") {
+		t.Errorf("expected error asking for --database, got %v", err)
+	}
+	if backup, err := Select(catalog, "crm", Target{Time: day.Add(5 * time.Hour)}); err != nil || filepath.Base(backup.Dir) != "crm-1" {
+		t.Errorf("Select(crm) = %v, %v, want crm-1", backup, err)
+	}
+}
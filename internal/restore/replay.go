@@ -0,0 +1,359 @@
+package restore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"motors-backup/internal/binlog"
+	"motors-backup/internal/exporter"
+	"motors-backup/internal/manifest"
+	"strings"
+	"time"
+)
+
+// Execer runs a statement, *sql.Conn implements it. The replay must use a single connection because
+// the session settings, transactions and the format description of BINLOG statements are per session
+type Execer interface {
+	ExecContext(ctx context.Context, query string, args ...any) (sql.Result, error)
+}
+
+// Result describes the binary log replayed after a backup
+type Result struct {
+	// Stop is the position after the last replayed transaction, where replication could continue
+	Stop manifest.BinlogPosition
+	// LastGTID and LastTime describe the last replayed transaction, they are empty when none was replayed
+	LastGTID     string
+	LastTime     time.Time
+	Transactions int
+	// ReachedTarget is false when the archive ended before the target
+	ReachedTarget bool
+	// End is the time of the last event in the archive
+	End time.Time
+}
+
+// Replay applies the archived binary log from start until the target. Only changes of database are
+// applied: row events by the database of their table, statements by their default database, like
+// mysqlbinlog --database. Row events are sent as BINLOG statements, so the server applies them
+// exactly as a replica would. With a nil exec the archive is only read, to check it is complete
+// and to find where the replay will stop before anything is restored
+func Replay(ctx context.Context, dir string, start manifest.BinlogPosition, database string, target Target, exec Execer) (*Result, error) {
+	reader, err := binlog.OpenReader(dir, start)
+	if err != nil {
+		return nil, err
+	}
+	defer reader.Close()
+
+	r := &replayer{ctx: ctx, exec: exec, database: database, target: target, tables: make(map[uint64]bool)}
+	r.result.Stop = manifest.BinlogPosition{File: start.File, Position: start.Position}
+	if target.GTID != "" {
+		gtid, err := binlog.ParseGTID(target.GTID)
+		if err != nil {
+			return nil, err
+		}
+		r.targetGTID = gtid.String()
+	}
+	// 与 mysqlbinlog 相同，允许 BINLOG 语句和复制专用的操作
+	if err := r.run("/*!50530 SET @@SESSION.PSEUDO_SLAVE_MODE=1*/"); err != nil {
+		return nil, err
+	}
+
+	for !r.result.ReachedTarget {
+		if ctx.Err() != nil {
+			return nil, context.Cause(ctx)
+		}
+		event, err := reader.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		r.result.End = time.Unix(int64(event.Header.Timestamp), 0)
+		if err := r.handle(event, reader.Position()); err != nil {
+			return nil, fmt.Errorf("%s at %s:%d: %w", event.Header.Type, reader.File(), reader.Position().Position, err)
+		}
+	}
+
+	// 归档末尾未提交的事务不属于恢复的内容
+	if r.open {
+		if err := r.run("ROLLBACK"); err != nil {
+			return nil, err
+		}
+	}
+	return &r.result, nil
+}
+
+// replayer 跟踪事务边界和会话状态
+type replayer struct {
+	ctx        context.Context
+	exec       Execer
+	database   string
+	target     Target
+	targetGTID string
+	result     Result
+
+	// started 表示位于事务中，explicit 表示事务以 BEGIN 开始，需要以 COMMIT 或 XID 结束。
+	// BEGIN 在第一条要执行的语句之前才发送，只涉及其他数据库的事务不会产生空事务
+	started  bool
+	explicit bool
+	open     bool
+	gtid     string
+	// pending 为下一条语句之前需要执行的 INSERT_ID 和 RAND 种子
+	pending []string
+	// rows 收集一条语句的 Table_map 和 rows 事件，tables 记录表是否属于恢复的数据库
+	rows   []byte
+	tables map[uint64]bool
+	// session 为已在连接上设置的会话变量
+	session map[string]string
+}
+
+func (r *replayer) handle(event *binlog.Event, position manifest.BinlogPosition) error {
+	switch event.Header.Type {
+	case binlog.FormatDescriptionEvent:
+		return r.binlog(event.Raw)
+
+	case binlog.GTIDEvent, binlog.AnonymousGTIDEvent, binlog.MariaDBGTIDEvent:
+		if r.start(event) {
+			return nil
+		}
+		if event.Header.Type == binlog.AnonymousGTIDEvent {
+			return nil
+		}
+		gtid, err := event.GTID()
+		if err != nil {
+			return err
+		}
+		r.gtid = gtid.String()
+		// MariaDB 的 GTID 事件代替了 BEGIN
+		r.explicit = gtid.MariaDB && !gtid.Standalone
+		return nil
+
+	case binlog.QueryEvent:
+		q, err := event.Query()
+		if err != nil {
+			return err
+		}
+		switch strings.ToUpper(strings.TrimSpace(q.SQL)) {
+		case "BEGIN":
+			if !r.started && r.start(event) {
+				return nil
+			}
+			r.explicit = true
+			return nil
+		case "COMMIT", "ROLLBACK":
+			return r.commit(event, position, strings.ToUpper(strings.TrimSpace(q.SQL)))
+		}
+		if !r.started && r.start(event) {
+			return nil
+		}
+		if q.Database == r.database {
+			if err := r.query(event, q); err != nil {
+				return err
+			}
+		}
+		r.pending = nil
+		// 不在 BEGIN 中的语句（如 DDL）自成一个事务
+		if !r.explicit {
+			return r.commit(event, position, "")
+		}
+		return nil
+
+	case binlog.XIDEvent:
+		return r.commit(event, position, "COMMIT")
+
+	case binlog.TableMapEvent:
+		id, database, _, err := event.TableMap()
+		if err != nil {
+			return err
+		}
+		r.tables[id] = database == r.database
+		if r.tables[id] {
+			r.rows = append(r.rows, event.Raw...)
+		}
+		return nil
+
+	case binlog.IntvarEvent, binlog.RandEvent:
+		stmt, err := event.Intvar()
+		if event.Header.Type == binlog.RandEvent {
+			stmt, err = event.Rand()
+		}
+		if err != nil {
+			return err
+		}
+		r.pending = append(r.pending, stmt)
+		return nil
+
+	case binlog.UserVarEvent:
+		return fmt.Errorf("statements using user variables are not supported, use binlog_format=ROW")
+
+	case binlog.PreviousGTIDsEvent, binlog.StopEvent, binlog.RowsQueryEvent, binlog.TransactionContextEvent,
+		binlog.ViewChangeEvent, binlog.MariaDBAnnotateRows, binlog.MariaDBBinlogCheckpoint, binlog.MariaDBGTIDListEvent:
+		return nil
+	}
+
+	if event.IsRows() {
+		id, statementEnd, err := event.Rows()
+		if err != nil {
+			return err
+		}
+		if r.tables[id] {
+			r.rows = append(r.rows, event.Raw...)
+		}
+		if !statementEnd {
+			return nil
+		}
+		rows := r.rows
+		r.rows, r.tables = nil, make(map[uint64]bool)
+		if len(rows) == 0 {
+			return nil
+		}
+		if err := r.begin(); err != nil {
+			return err
+		}
+		return r.binlog(rows)
+	}
+	if event.Ignorable() {
+		return nil
+	}
+	return fmt.Errorf("event type is not supported")
+}
+
+// start 在事务的第一个事件处调用，事务晚于目标时间时停止，返回 true
+func (r *replayer) start(event *binlog.Event) bool {
+	if r.target.GTID == "" && int64(event.Header.Timestamp) > r.target.Time.Unix() {
+		r.result.ReachedTarget = true
+		return true
+	}
+	r.started = true
+	return false
+}
+
+// begin 在执行事务中的第一条语句之前发送 BEGIN
+func (r *replayer) begin() error {
+	if !r.explicit || r.open {
+		return nil
+	}
+	if err := r.run("BEGIN"); err != nil {
+		return err
+	}
+	r.open = true
+	return nil
+}
+
+// commit 结束事务并记录位置，到达目标 GTID 时停止。statement 为 COMMIT 或 ROLLBACK，
+// 只在发送过 BEGIN 时执行
+func (r *replayer) commit(event *binlog.Event, position manifest.BinlogPosition, statement string) error {
+	if r.open && statement != "" {
+		if err := r.run(statement); err != nil {
+			return err
+		}
+	}
+	r.result.Stop = position
+	r.result.Transactions++
+	r.result.LastTime = time.Unix(int64(event.Header.Timestamp), 0)
+	if r.gtid != "" {
+		r.result.LastGTID = r.gtid
+	}
+	if r.targetGTID != "" && r.gtid == r.targetGTID {
+		r.result.ReachedTarget = true
+	}
+	r.started, r.explicit, r.open, r.gtid, r.pending = false, false, false, "", nil
+	return nil
+}
+
+// query 按事件中记录的会话状态执行语句，会话变量只在变化时设置
+func (r *replayer) query(event *binlog.Event, q *binlog.Query) error {
+	if err := r.begin(); err != nil {
+		return err
+	}
+	timestamp := fmt.Sprint(event.Header.Timestamp)
+	if q.Microseconds >= 0 {
+		timestamp = fmt.Sprintf("%d.%06d", event.Header.Timestamp, q.Microseconds)
+	}
+	if err := r.run("SET TIMESTAMP=" + timestamp); err != nil {
+		return err
+	}
+
+	var settings [][2]string
+	set := func(name, statement string) {
+		settings = append(settings, [2]string{name, statement})
+	}
+	if q.Database != "" {
+		set("database", "USE `"+strings.ReplaceAll(q.Database, "`", "``")+"`")
+	}
+	if q.Flags2 != nil {
+		flags := *q.Flags2
+		set("flags2", fmt.Sprintf(
+			"SET @@session.foreign_key_checks=%d, @@session.sql_auto_is_null=%d, @@session.unique_checks=%d, @@session.autocommit=%d",
+			flag(flags&binlog.OptionNoForeignKeyChecks == 0), flag(flags&binlog.OptionAutoIsNull != 0),
+			flag(flags&binlog.OptionRelaxedUniqueChecks == 0), flag(flags&binlog.OptionNotAutocommit == 0)))
+	}
+	if q.SQLMode != nil {
+		set("sql_mode", fmt.Sprintf("SET @@session.sql_mode=%d", *q.SQLMode))
+	}
+	if q.AutoIncrement != nil {
+		set("auto_increment", fmt.Sprintf("SET @@session.auto_increment_increment=%d, @@session.auto_increment_offset=%d",
+			q.AutoIncrement[0], q.AutoIncrement[1]))
+	}
+	if q.Charset != nil {
+		set("charset", fmt.Sprintf("SET @@session.character_set_client=%d, @@session.collation_connection=%d, @@session.collation_server=%d",
+			q.Charset[0], q.Charset[1], q.Charset[2]))
+	}
+	if q.TimeZone != nil {
+		set("time_zone", "SET @@session.time_zone="+exporter.EscapeSQLString(*q.TimeZone))
+	}
+
+	if r.session == nil {
+		r.session = make(map[string]string)
+	}
+	for _, s := range settings {
+		name, statement := s[0], s[1]
+		if r.session[name] == statement {
+			continue
+		}
+		if err := r.run(statement); err != nil {
+			return err
+		}
+		r.session[name] = statement
+	}
+	for _, stmt := range r.pending {
+		if err := r.run(stmt); err != nil {
+			return err
+		}
+	}
+	return r.run(q.SQL)
+}
+
+// binlog 以 BINLOG 语句执行原始事件
+func (r *replayer) binlog(raw []byte) error {
+	return r.run("BINLOG '" + base64.StdEncoding.EncodeToString(raw) + "'")
+}
+
+func (r *replayer) run(query string) error {
+	if r.exec == nil {
+		return nil
+	}
+	if _, err := r.exec.ExecContext(r.ctx, query); err != nil {
+		return fmt.Errorf("%s: %w", abbreviate(query), err)
+	}
+	return nil
+}
+
+func flag(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// abbreviate 截断错误信息中过长的语句
+func abbreviate(query string) string {
+	const maxLen = 200
+	if len(query) <= maxLen {
+		return query
+	}
+	return query[:maxLen] + "..."
+}
@@ -0,0 +1,213 @@
+package restore
+
+import (
+	"context"
+	"database/sql"
+	"encoding/binary"
+	"hash/crc32"
+	"motors-backup/internal/binlog"
+	"motors-backup/internal/manifest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testSID = [16]byte{0x3e, 0x11, 0xfa, 0x47, 0x71, 0xca, 0x11, 0xe1, 0x9e, 0x33, 0xc8, 0x0a, 0xa9, 0x42, 0x95, 0x62}
+
+// recorder 记录执行的语句
+type recorder struct {
+	statements []string
+}
+
+func (r *recorder) ExecContext(_ context.Context, query string, _ ...any) (sql.Result, error) {
+	r.statements = append(r.statements, query)
+	return nil, nil
+}
+
+// binlogFile 按服务器的格式构造一个带 CRC32 校验和的 binlog 文件
+type binlogFile struct {
+	data []byte
+}
+
+func (f *binlogFile) add(eventType binlog.EventType, timestamp uint32, body []byte) uint32 {
+	if f.data == nil {
+		f.data = slices.Clone(binlog.FileMagic)
+	}
+	size := binlog.HeaderSize + len(body) + 4
+	end := uint32(len(f.data) + size)
+	raw := binary.LittleEndian.AppendUint32(nil, timestamp)
+	raw = append(raw, byte(eventType))
+	raw = binary.LittleEndian.AppendUint32(raw, 1)
+	raw = binary.LittleEndian.AppendUint32(raw, uint32(size))
+	raw = binary.LittleEndian.AppendUint32(raw, end)
+	raw = binary.LittleEndian.AppendUint16(raw, 0)
+	raw = append(raw, body...)
+	raw = binary.LittleEndian.AppendUint32(raw, crc32.ChecksumIEEE(raw))
+	f.data = append(f.data, raw...)
+	return end
+}
+
+func formatDescription() []byte {
+	body := binary.LittleEndian.AppendUint16(nil, 4)
+	version := make([]byte, 50)
+	copy(version, "8.0.36")
+	body = append(body, version...)
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = append(body, binlog.HeaderSize)
+	body = append(body, make([]byte, 40)...)
+	return append(body, 1)
+}
+
+func gtid(gno uint64) []byte {
+	body := append([]byte{1}, testSID[:]...)
+	return binary.LittleEndian.AppendUint64(body, gno)
+}
+
+// query 构造 Query 事件，记录 sql_mode 和字符集
+func query(database, sql string) []byte {
+	status := binary.LittleEndian.AppendUint64([]byte{1}, 1436549152)
+	status = append(status, 4, 45, 0, 45, 0, 255, 0)
+	body := binary.LittleEndian.AppendUint32(nil, 7)
+	body = binary.LittleEndian.AppendUint32(body, 0)
+	body = append(body, byte(len(database)), 0, 0)
+	body = binary.LittleEndian.AppendUint16(body, uint16(len(status)))
+	body = append(body, status...)
+	body = append(append(body, database...), 0)
+	return append(body, sql...)
+}
+
+func tableMap(id uint64, database, table string) []byte {
+	body := binary.LittleEndian.AppendUint64(nil, id)[:6]
+	body = append(body, 0, 0)
+	body = append(append(append(body, byte(len(database))), database...), 0)
+	body = append(append(append(body, byte(len(table))), table...), 0)
+	return append(body, 1, 3, 0)
+}
+
+func writeRows(id uint64) []byte {
+	body := binary.LittleEndian.AppendUint64(nil, id)[:6]
+	body = binary.LittleEndian.AppendUint16(body, 1)
+	return append(body, 2, 0, 1, 0xff, 0, 1, 0, 0, 0)
+}
+
+func xid() []byte {
+	return binary.LittleEndian.AppendUint64(nil, 99)
+}
+
+// writeTestArchive 写入四个事务，最后一个没有提交
+func writeTestArchive(t *testing.T, dir string) (positions []uint32) {
+	t.Helper()
+	var f binlogFile
+	f.add(binlog.FormatDescriptionEvent, 100, formatDescription())
+
+	f.add(binlog.GTIDEvent, 100, gtid(1))
+	f.add(binlog.QueryEvent, 100, query("shop", "BEGIN"))
+	f.add(binlog.TableMapEvent, 100, tableMap(1, "shop", "orders"))
+	f.add(binlog.WriteRowsEventV2, 100, writeRows(1))
+	positions = append(positions, f.add(binlog.XIDEvent, 100, xid()))
+
+	f.add(binlog.GTIDEvent, 200, gtid(2))
+	f.add(binlog.QueryEvent, 200, query("other", "BEGIN"))
+	f.add(binlog.TableMapEvent, 200, tableMap(2, "other", "t"))
+	f.add(binlog.WriteRowsEventV2, 200, writeRows(2))
+	positions = append(positions, f.add(binlog.XIDEvent, 200, xid()))
+
+	f.add(binlog.GTIDEvent, 300, gtid(3))
+	positions = append(positions, f.add(binlog.QueryEvent, 300, query("shop", "ALTER TABLE orders ADD c INT")))
+
+	f.add(binlog.GTIDEvent, 400, gtid(4))
+	f.add(binlog.QueryEvent, 400, query("shop", "BEGIN"))
+	f.add(binlog.TableMapEvent, 400, tableMap(1, "shop", "orders"))
+	f.add(binlog.WriteRowsEventV2, 400, writeRows(1))
+
+	if err := os.WriteFile(filepath.Join(dir, "binlog.000001"+binlog.PartialSuffix), f.data, 0o640); err != nil {
+		t.Fatal(err)
+	}
+	return positions
+}
+
+// summarize 将 BINLOG 语句简写，便于比较
+func summarize(statements []string) []string {
+	var out []string
+	for _, s := range statements {
+		if strings.HasPrefix(s, "BINLOG '") {
+			s = "BINLOG"
+		}
+		out = append(out, s)
+	}
+	return out
+}
+
+func TestReplay(t *testing.T) {
+	dir := t.TempDir()
+	positions := writeTestArchive(t, dir)
+	start := manifest.BinlogPosition{File: "binlog.000001", Position: 4}
+	sid := binlog.FormatSID(testSID)
+
+	tests := []struct {
+		name         string
+		target       Target
+		statements   []string
+		stop         uint32
+		transactions int
+		lastGTID     string
+		reached      bool
+	}{
+		{
+			name:   "until time",
+			target: Target{Time: time.Unix(250, 0)},
+			statements: []string{"/*!50530 SET @@SESSION.PSEUDO_SLAVE_MODE=1*/", "BINLOG",
+				"BEGIN", "BINLOG", "COMMIT"},
+			stop: positions[1], transactions: 2, lastGTID: sid + ":2", reached: true,
+		},
+		{
+			name:   "until gtid",
+			target: Target{GTID: sid + ":3"},
+			statements: []string{"/*!50530 SET @@SESSION.PSEUDO_SLAVE_MODE=1*/", "BINLOG",
+				"BEGIN", "BINLOG", "COMMIT",
+				"SET TIMESTAMP=300", "USE `shop`", "SET @@session.sql_mode=1436549152",
+				"SET @@session.character_set_client=45, @@session.collation_connection=45, @@session.collation_server=255",
+				"ALTER TABLE orders ADD c INT"},
+			stop: positions[2], transactions: 3, lastGTID: sid + ":3", reached: true,
+		},
+		{
+			name:   "end of archive",
+			target: Target{Time: time.Unix(1000, 0)},
+			statements: []string{"/*!50530 SET @@SESSION.PSEUDO_SLAVE_MODE=1*/", "BINLOG",
+				"BEGIN", "BINLOG", "COMMIT",
+				"SET TIMESTAMP=300", "USE `shop`", "SET @@session.sql_mode=1436549152",
+				"SET @@session.character_set_client=45, @@session.collation_connection=45, @@session.collation_server=255",
+				"ALTER TABLE orders ADD c INT",
+				"BEGIN", "BINLOG", "ROLLBACK"},
+			stop: positions[2], transactions: 3, lastGTID: sid + ":3", reached: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan, err := Replay(context.Background(), dir, start, "shop", tt.target, nil)
+			if err != nil {
+				t.Fatalf("Replay without exec returned error: %v", err)
+			}
+
+			rec := new(recorder)
+			result, err := Replay(context.Background(), dir, start, "shop", tt.target, rec)
+			if err != nil {
+				t.Fatalf("Replay returned error: %v", err)
+			}
+			if got := summarize(rec.statements); !slices.Equal(got, tt.statements) {
+				t.Errorf("statements =\n%q\nwant\n%q", got, tt.statements)
+			}
+			for _, r := range []*Result{plan, result} {
+				if r.Stop.Position != uint64(tt.stop) || r.Transactions != tt.transactions ||
+					r.LastGTID != tt.lastGTID || r.ReachedTarget != tt.reached {
+					t.Errorf("result = %+v, want stop %d, %d transactions, last GTID %s, reached %v",
+						r, tt.stop, tt.transactions, tt.lastGTID, tt.reached)
+				}
+			}
+		})
+	}
+}
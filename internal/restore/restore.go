@@ -0,0 +1,93 @@
+package restore
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"motors-backup/internal/log"
+	"motors-backup/internal/manifest"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// LoadDump verifies the backup against its manifest and executes its SQL files
+func LoadDump(ctx context.Context, exec Execer, backup *Backup) error {
+	problems, err := manifest.Verify(backup.Dir)
+	if err != nil {
+		return err
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("backup %s failed verification: %s", backup.Dir, strings.Join(problems, "; "))
+	}
+
+	for _, file := range backup.Manifest.Files {
+		if !strings.HasSuffix(file.Name, ".sql") {
+			continue
+		}
+		if err := loadFile(ctx, exec, filepath.Join(backup.Dir, file.Name)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// replicationStatements 为 --source-data=1 写入的复制语句，起始位置已记录在清单中，加载时跳过，
+// 否则目标的 gtid_executed 不为空时 GTID_PURGED 会失败，复制位置也会被改写
+var replicationStatements = []string{
+	"CHANGE REPLICATION SOURCE TO ",
+	"CHANGE MASTER TO ",
+	"SET @@GLOBAL.GTID_PURGED",
+	"SET GLOBAL GTID_PURGED",
+	"SET GLOBAL GTID_SLAVE_POS",
+}
+
+// isReplicationStatement reports whether stmt sets the replication position or the GTID state of the server
+func isReplicationStatement(stmt string) bool {
+	stmt = strings.ToUpper(strings.Join(strings.Fields(stmt), " "))
+	for _, prefix := range replicationStatements {
+		if strings.HasPrefix(stmt, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// loadFile 逐条执行 SQL 文件中的语句，复制语句除外
+func loadFile(ctx context.Context, exec Execer, path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	start := time.Now()
+	log.Logger.Info("loading dump", "file", path)
+	scanner := NewScanner(file)
+	count := 0
+	for {
+		stmt, err := scanner.Next()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", path, err)
+		}
+		if isReplicationStatement(stmt) {
+			log.Logger.Info("skipping replication statement, the binary log position comes from the manifest",
+				"file", path, "statement", abbreviate(stmt))
+			continue
+		}
+		if _, err := exec.ExecContext(ctx, stmt); err != nil {
+			if ctx.Err() != nil {
+				return context.Cause(ctx)
+			}
+			return fmt.Errorf("%s: statement %d: %s: %w", path, count+1, abbreviate(stmt), err)
+		}
+		count++
+	}
+	log.Logger.Info("dump loaded", "file", path, "statements", count, log.Duration(time.Since(start)))
+	return nil
+}
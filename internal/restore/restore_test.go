@@ -0,0 +1,53 @@
+package restore
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestLoadFileSkipsReplicationStatements(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+	}{
+		{
+			name: "MySQL",
+			script: "--\n-- Position to start replication or point-in-time recovery from\n--\n\n" +
+				"CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000042', SOURCE_LOG_POS=157;\n\n" +
+				"SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ '3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5';\n\n",
+		},
+		{
+			name: "MySQL 5.7",
+			script: "CHANGE MASTER TO MASTER_LOG_FILE='mysql-bin.000003', MASTER_LOG_POS=4;\n" +
+				"SET @@GLOBAL.GTID_PURGED= '3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5';\n",
+		},
+		{
+			name: "MariaDB",
+			script: "CHANGE MASTER TO MASTER_LOG_FILE='mariadb-bin.000007', MASTER_LOG_POS=342;\n" +
+				"SET GLOBAL gtid_slave_pos='0-1-100';\n",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "shop.sql")
+			script := "/*!40101 SET NAMES utf8mb4 */;\n" + tt.script +
+				"CREATE TABLE `t` (`id` int);\nINSERT INTO `t` VALUES (1);\n"
+			if err := os.WriteFile(path, []byte(script), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			rec := new(recorder)
+			if err := loadFile(context.Background(), rec, path); err != nil {
+				t.Fatalf("loadFile returned error: %v", err)
+			}
+			want := []string{"/*!40101 SET NAMES utf8mb4 */", "CREATE TABLE `t` (`id` int)", "INSERT INTO `t` VALUES (1)"}
+			if !slices.Equal(rec.statements, want) {
+				t.Errorf("statements = %q, want %q", rec.statements, want)
+			}
+		})
+	}
+}
@@ -0,0 +1,175 @@
+package restore
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"strings"
+)
+
+// Scanner splits an SQL script into statements the way the mysql client does. Quoted strings and
+// identifiers are kept intact, comments are dropped except for /*! version and /*+ hint comments
+// that the server interprets, and DELIMITER commands change the statement terminator
+type Scanner struct {
+	r         *bufio.Reader
+	delimiter string
+	stmt      strings.Builder
+	// content 表示当前语句中已有空白以外的内容
+	content bool
+}
+
+// NewScanner returns a scanner reading the script from r
+func NewScanner(r io.Reader) *Scanner {
+	return &Scanner{r: bufio.NewReaderSize(r, 256*1024), delimiter: ";"}
+}
+
+// Next returns the next statement without its delimiter, io.EOF after the last one
+func (s *Scanner) Next() (string, error) {
+	s.reset()
+	for {
+		b, err := s.r.ReadByte()
+		if errors.Is(err, io.EOF) {
+			if stmt := strings.TrimSpace(s.stmt.String()); stmt != "" {
+				return stmt, nil
+			}
+			return "", io.EOF
+		}
+		if err != nil {
+			return "", err
+		}
+
+		switch {
+		case b == '\'' || b == '"' || b == '`':
+			s.stmt.WriteByte(b)
+			s.content = true
+			if err := s.quoted(b); err != nil {
+				return "", err
+			}
+		case b == '#' || b == '-' && s.lineComment():
+			if err := s.skipLine(); err != nil {
+				return "", err
+			}
+			s.stmt.WriteByte('\n')
+		case b == '/' && s.peek(1) == "*":
+			if err := s.blockComment(); err != nil {
+				return "", err
+			}
+		case !s.content && (b == 'd' || b == 'D') && strings.EqualFold(s.peek(9), "ELIMITER "):
+			if err := s.setDelimiter(); err != nil {
+				return "", err
+			}
+		case b == s.delimiter[0] && s.peek(len(s.delimiter)-1) == s.delimiter[1:]:
+			s.r.Discard(len(s.delimiter) - 1)
+			if stmt := strings.TrimSpace(s.stmt.String()); stmt != "" {
+				return stmt, nil
+			}
+			s.reset()
+		default:
+			s.stmt.WriteByte(b)
+			if !strings.ContainsRune(" \t\r\n", rune(b)) {
+				s.content = true
+			}
+		}
+	}
+}
+
+// quoted 读取引号内的内容，支持反斜杠转义和重复引号
+func (s *Scanner) quoted(quote byte) error {
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		s.stmt.WriteByte(b)
+		switch {
+		case b == '\\' && quote != '`':
+			next, err := s.r.ReadByte()
+			if err != nil {
+				return unexpectedEOF(err)
+			}
+			s.stmt.WriteByte(next)
+		case b == quote:
+			if s.peek(1) != string(quote) {
+				return nil
+			}
+			next, _ := s.r.ReadByte()
+			s.stmt.WriteByte(next)
+		}
+	}
+}
+
+// lineComment 判断 - 之后是否为 "-- " 形式的注释
+func (s *Scanner) lineComment() bool {
+	next := s.peek(2)
+	return len(next) > 0 && next[0] == '-' && (len(next) == 1 || strings.ContainsRune(" \t\r\n", rune(next[1])))
+}
+
+func (s *Scanner) skipLine() error {
+	_, err := s.r.ReadString('\n')
+	if errors.Is(err, io.EOF) {
+		return nil
+	}
+	return err
+}
+
+// blockComment 跳过普通注释，保留服务器会执行的 /*! 和 /*+ 注释
+func (s *Scanner) blockComment() error {
+	s.r.Discard(1)
+	keep := s.peek(1) == "!" || s.peek(1) == "+"
+	if keep {
+		s.stmt.WriteString("/*")
+		s.content = true
+	}
+	var prev byte
+	for {
+		b, err := s.r.ReadByte()
+		if err != nil {
+			return unexpectedEOF(err)
+		}
+		if keep {
+			s.stmt.WriteByte(b)
+		}
+		if prev == '*' && b == '/' {
+			if !keep {
+				s.stmt.WriteByte(' ')
+			}
+			return nil
+		}
+		prev = b
+	}
+}
+
+func (s *Scanner) setDelimiter() error {
+	line, err := s.r.ReadString('\n')
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	delimiter := strings.TrimSpace(line[len("ELIMITER "):])
+	if delimiter == "" {
+		return errors.New("DELIMITER requires a delimiter")
+	}
+	s.delimiter = delimiter
+	s.reset()
+	return nil
+}
+
+func (s *Scanner) reset() {
+	s.stmt.Reset()
+	s.content = false
+}
+
+// peek 返回之后的 n 个字节，不足时返回剩余的字节
+func (s *Scanner) peek(n int) string {
+	if n <= 0 {
+		return ""
+	}
+	b, _ := s.r.Peek(n)
+	return string(b)
+}
+
+func unexpectedEOF(err error) error {
+	if errors.Is(err, io.EOF) {
+		return errors.New("unterminated quoted string or comment at the end of the script")
+	}
+	return err
+}
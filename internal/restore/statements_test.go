@@ -0,0 +1,70 @@
+package restore
+
+import (
+	"errors"
+	"io"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestScanner(t *testing.T) {
+	tests := []struct {
+		name   string
+		script string
+		want   []string
+	}{
+		{
+			name:   "statements and comments",
+			script: "-- header\n/*!40101 SET NAMES utf8mb4 */;\n\n# note\nDROP TABLE IF EXISTS `t`;\n/* plain */ SELECT 1;",
+			want:   []string{"/*!40101 SET NAMES utf8mb4 */", "DROP TABLE IF EXISTS `t`", "SELECT 1"},
+		},
+		{
+			name:   "delimiters inside quotes",
+			script: "INSERT INTO `a;b` VALUES ('x;y', \"it''s\", 'a\\'b;', 'c''d;');\nSELECT 2",
+			want:   []string{"INSERT INTO `a;b` VALUES ('x;y', \"it''s\", 'a\\'b;', 'c''d;')", "SELECT 2"},
+		},
+		{
+			name:   "dashes without a space are not a comment",
+			script: "SELECT 1--1;",
+			want:   []string{"SELECT 1--1"},
+		},
+		{
+			name:   "delimiter command",
+			script: "DELIMITER ;;\nCREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END;;\nDELIMITER ;\nSELECT 3;",
+			want:   []string{"CREATE TRIGGER t BEFORE INSERT ON a FOR EACH ROW BEGIN SET NEW.x = 1; END", "SELECT 3"},
+		},
+		{
+			name:   "empty statements",
+			script: ";;\n  ;\n",
+			want:   nil,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scanner := NewScanner(strings.NewReader(tt.script))
+			var got []string
+			for {
+				stmt, err := scanner.Next()
+				if errors.Is(err, io.EOF) {
+					break
+				}
+				if err != nil {
+					t.Fatalf("Next returned error: %v", err)
+				}
+				got = append(got, stmt)
+			}
+			if !slices.Equal(got, tt.want) {
+				t.Errorf("statements = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestScannerUnterminatedString(t *testing.T) {
+	scanner := NewScanner(strings.NewReader("INSERT INTO t VALUES ('abc"))
+	if _, err := scanner.Next(); err == nil || errors.Is(err, io.EOF) {
+		t.Errorf("expected error for an unterminated string, got %v", err)
+	}
+}
@@ -0,0 +1,147 @@
+package retry
+
+import (
+	"context"
+	"database/sql/driver"
+	"errors"
+	"io"
+	"math/rand/v2"
+	"motors-backup/internal/log"
+	"net"
+	"syscall"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+// 可以重试的 MySQL 错误码
+var transientCodes = map[uint16]string{
+	1040: "too many connections",
+	1053: "server shutdown in progress",
+	1158: "network read error",
+	1159: "network read timeout",
+	1160: "network write error",
+	1161: "network write timeout",
+	1205: "lock wait timeout",
+	1213: "deadlock",
+	1927: "connection killed",
+	3032: "server offline mode",
+	4031: "disconnected due to inactivity",
+}
+
+// IsTransient reports whether err is worth retrying on a new connection: lost connections,
+// network timeouts, lock wait timeouts and deadlocks. Everything else, such as syntax errors,
+// missing tables and permission errors, is fatal
+func IsTransient(err error) bool {
+	// 取消和超时由调用方决定，不能重试
+	if err == nil || errors.Is(err, context.Canceled) || errors.Is(err, context.DeadlineExceeded) {
+		return false
+	}
+	var fatal *fatalError
+	if errors.As(err, &fatal) {
+		return false
+	}
+
+	var mysqlErr *mysql.MySQLError
+	if errors.As(err, &mysqlErr) {
+		_, ok := transientCodes[mysqlErr.Number]
+		return ok
+	}
+
+	if errors.Is(err, mysql.ErrInvalidConn) || errors.Is(err, driver.ErrBadConn) ||
+		errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) ||
+		errors.Is(err, syscall.ECONNRESET) || errors.Is(err, syscall.ECONNREFUSED) ||
+		errors.Is(err, syscall.ECONNABORTED) || errors.Is(err, syscall.EPIPE) || errors.Is(err, syscall.ETIMEDOUT) {
+		return true
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return true
+	}
+	var opErr *net.OpError
+	return errors.As(err, &opErr)
+}
+
+// fatalError 标记不能重试的错误，即使 IsTransient 会重试被包装的错误
+type fatalError struct {
+	err error
+}
+
+func (e *fatalError) Error() string { return e.err.Error() }
+
+func (e *fatalError) Unwrap() error { return e.err }
+
+// ConnectError classifies an error of the first connection to a server: an unknown host name or a
+// refused connection point to wrong settings rather than a restarting server, so they are marked
+// fatal. Other errors are returned unchanged
+func ConnectError(err error) error {
+	var dnsErr *net.DNSError
+	if errors.As(err, &dnsErr) || errors.Is(err, syscall.ECONNREFUSED) {
+		return &fatalError{err: err}
+	}
+	return err
+}
+
+// Policy 描述重试次数和指数退避的等待时间
+type Policy struct {
+	// Retries is the number of retries after the first attempt, 0 disables retrying
+	Retries  int
+	Delay    time.Duration
+	MaxDelay time.Duration
+}
+
+// DefaultPolicy retries three times, waiting 1s, 2s and 4s with jitter
+func DefaultPolicy() Policy {
+	return Policy{Retries: 3, Delay: time.Second, MaxDelay: 30 * time.Second}
+}
+
+// Backoff returns the wait before the given retry, starting at 1: the delay doubles on every retry
+// up to MaxDelay, plus up to 20% of random jitter
+func (p Policy) Backoff(retry int) time.Duration {
+	delay := p.Delay
+	for i := 1; i < retry && delay < p.MaxDelay; i++ {
+		delay *= 2
+	}
+	if p.MaxDelay > 0 && delay > p.MaxDelay {
+		delay = p.MaxDelay
+	}
+	if delay <= 0 {
+		return 0
+	}
+	return delay + rand.N(delay/5+1)
+}
+
+// Wait logs the transient error and sleeps before the given retry, it returns false when
+// err is fatal, the retries are used up or ctx is canceled
+func (p Policy) Wait(ctx context.Context, retry int, err error, attrs ...any) bool {
+	if retry > p.Retries || !IsTransient(err) || ctx.Err() != nil {
+		return false
+	}
+	delay := p.Backoff(retry)
+	log.Logger.Warn("transient error, retrying", append(append(log.Err(err), attrs...),
+		"retry", retry, "max_retries", p.Retries, "delay", delay)...)
+
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return true
+	case <-ctx.Done():
+		return false
+	}
+}
+
+// Do runs fn until it succeeds, fails with a fatal error, the retries are used up or ctx is canceled,
+// onRetry is called before every retry and may be nil
+func (p Policy) Do(ctx context.Context, fn func() error, onRetry func(err error), attrs ...any) error {
+	for retry := 1; ; retry++ {
+		err := fn()
+		if err == nil || !p.Wait(ctx, retry, err, attrs...) {
+			return err
+		}
+		if onRetry != nil {
+			onRetry(err)
+		}
+	}
+}
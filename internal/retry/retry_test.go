@@ -0,0 +1,138 @@
+package retry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"syscall"
+	"testing"
+	"time"
+
+	"github.com/go-sql-driver/mysql"
+)
+
+func TestIsTransient(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"nil", nil, false},
+		{"deadlock", &mysql.MySQLError{Number: 1213, Message: "Deadlock found"}, true},
+		{"lock wait timeout", fmt.Errorf("failed to query table data: %w", &mysql.MySQLError{Number: 1205}), true},
+		{"syntax error", &mysql.MySQLError{Number: 1064}, false},
+		{"access denied", &mysql.MySQLError{Number: 1045}, false},
+		{"invalid connection", mysql.ErrInvalidConn, true},
+		{"unexpected eof", fmt.Errorf("error iterating rows: %w", io.ErrUnexpectedEOF), true},
+		{"connection reset", &net.OpError{Op: "read", Net: "tcp", Err: syscall.ECONNRESET}, true},
+		{"connection refused", fmt.Errorf("failed to ping database: %w", syscall.ECONNREFUSED), true},
+		{"context canceled", context.Canceled, false},
+		{"canceled query", fmt.Errorf("failed to query table data: %w", errors.Join(mysql.ErrInvalidConn, context.Canceled)), false},
+		{"other", errors.New("no non-generated columns found"), false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			if got := IsTransient(tc.err); got != tc.want {
+				t.Errorf("IsTransient(%v) = %v, want %v", tc.err, got, tc.want)
+			}
+		})
+	}
+}
+
+func TestConnectError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want bool
+	}{
+		{"connection refused", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ECONNREFUSED)}, false},
+		{"unknown host", fmt.Errorf("failed to ping database: %w", &net.OpError{Op: "dial", Net: "tcp", Err: &net.DNSError{Err: "no such host", Name: "db.invalid", IsNotFound: true}}), false},
+		{"dial timeout", &net.OpError{Op: "dial", Net: "tcp", Err: os.NewSyscallError("connect", syscall.ETIMEDOUT)}, true},
+		{"too many connections", &mysql.MySQLError{Number: 1040}, true},
+		{"access denied", &mysql.MySQLError{Number: 1045}, false},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			err := ConnectError(tc.err)
+			if got := IsTransient(err); got != tc.want {
+				t.Errorf("IsTransient(ConnectError(%v)) = %v, want %v", tc.err, got, tc.want)
+			}
+			if !errors.Is(err, tc.err) || err.Error() != tc.err.Error() {
+				t.Errorf("ConnectError(%v) = %v, want the same error", tc.err, err)
+			}
+		})
+	}
+	if ConnectError(nil) != nil {
+		t.Error("ConnectError(nil) should be nil")
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	p := Policy{Retries: 5, Delay: 100 * time.Millisecond, MaxDelay: 300 * time.Millisecond}
+	for retry, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 300 * time.Millisecond, 5: 300 * time.Millisecond} {
+		got := p.Backoff(retry)
+		if got < want || got > want+want/5 {
+			t.Errorf("Backoff(%d) = %v, want between %v and %v", retry, got, want, want+want/5)
+		}
+	}
+	if got := (Policy{Retries: 1}).Backoff(1); got != 0 {
+		t.Errorf("Backoff without delay = %v, want 0", got)
+	}
+}
+
+func TestDo(t *testing.T) {
+	p := Policy{Retries: 2}
+	transient := &mysql.MySQLError{Number: 1213}
+
+	// 临时错误重试后成功
+	calls, retries := 0, 0
+	err := p.Do(context.Background(), func() error {
+		calls++
+		if calls < 3 {
+			return transient
+		}
+		return nil
+	}, func(error) { retries++ })
+	if err != nil || calls != 3 || retries != 2 {
+		t.Errorf("Do() = %v after %d calls and %d retries, want success after 3 calls", err, calls, retries)
+	}
+
+	// 重试次数用完后返回最后的错误
+	calls = 0
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return transient
+	}, nil)
+	if !errors.Is(err, transient) || calls != 3 {
+		t.Errorf("Do() = %v after %d calls, want the deadlock error after 3 calls", err, calls)
+	}
+
+	// 致命错误不重试
+	calls = 0
+	fatal := &mysql.MySQLError{Number: 1146}
+	err = p.Do(context.Background(), func() error {
+		calls++
+		return fatal
+	}, nil)
+	if !errors.Is(err, fatal) || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want the fatal error after 1 call", err, calls)
+	}
+}
+
+func TestDoStopsWhenCanceled(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	p := Policy{Retries: 3, Delay: time.Hour}
+
+	calls := 0
+	err := p.Do(ctx, func() error {
+		calls++
+		cancel()
+		return mysql.ErrInvalidConn
+	}, nil)
+	if !errors.Is(err, mysql.ErrInvalidConn) || calls != 1 {
+		t.Errorf("Do() = %v after %d calls, want the connection error after 1 call", err, calls)
+	}
+}
@@ -1,23 +1,29 @@
 package schema
 
 import (
+	"context"
 	"database/sql"
 	"fmt"
+	dbConn "motors-backup/internal/db"
+	"motors-backup/internal/flavor"
 	"strings"
 )
 
 // Column represents a table column
 type Column struct {
-	Name        string
+	Name string
+	// Type 为 SHOW COLUMNS 中的类型，例如 tinyint(1) 或 varchar(255)
+	Type        string
 	IsGenerated bool
 }
 
-// AnalyzeColumns analyzes the table schema to identify virtual columns
-func AnalyzeColumns(db *sql.DB, dbName, tableName string) ([]Column, error) {
+// AnalyzeColumns analyzes the table schema to identify virtual columns, the Extra values of
+// SHOW COLUMNS differ between server flavors
+func AnalyzeColumns(ctx context.Context, db dbConn.Querier, server flavor.Server, dbName, tableName string) ([]Column, error) {
 	// 使用 information_schema.COLUMNS 表查询列信息和生成表达式
 	query := fmt.Sprintf("SHOW COLUMNS FROM `%s`.`%s`;", dbName, tableName)
 
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query information_schema: %w", err)
 	}
@@ -28,11 +34,11 @@ func AnalyzeColumns(db *sql.DB, dbName, tableName string) ([]Column, error) {
 		var tmp interface{}
 		var col Column
 		var Extra string
-		err := rows.Scan(&col.Name, &tmp, &tmp, &tmp, &tmp, &Extra)
+		err := rows.Scan(&col.Name, &col.Type, &tmp, &tmp, &tmp, &Extra)
 		if err != nil {
 			return nil, fmt.Errorf("failed to scan column info: %w", err)
 		}
-		col.IsGenerated = strings.Contains(Extra, "GENERATED")
+		col.IsGenerated = server.IsGenerated(Extra)
 		columns = append(columns, col)
 	}
 
@@ -43,9 +49,9 @@ func AnalyzeColumns(db *sql.DB, dbName, tableName string) ([]Column, error) {
 	return columns, nil
 }
 
-func GetTableDDL(db *sql.DB, dbName string, tableName string) (string, error) {
+func GetTableDDL(ctx context.Context, db dbConn.Querier, dbName string, tableName string) (string, error) {
 	query := fmt.Sprintf("SHOW CREATE TABLE `%s`.`%s`;", dbName, tableName)
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return "", fmt.Errorf("failed to query information_schema: %w", err)
 	}
@@ -54,8 +60,12 @@ func GetTableDDL(db *sql.DB, dbName string, tableName string) (string, error) {
 	var tmp interface{}
 	params := make([]interface{}, 0)
 	columns, err := rows.Columns()
+	if err != nil {
+		return "", fmt.Errorf("failed to get table DDL columns: %w", err)
+	}
+	// MySQL 和 MariaDB 的 DDL 列名都以 Create 开头，视图和序列为 Create View / Create Table
 	for _, col := range columns {
-		if strings.Contains(col, "Create") {
+		if strings.HasPrefix(col, "Create") {
 			params = append(params, &ddl)
 			continue
 		}
@@ -69,6 +79,13 @@ func GetTableDDL(db *sql.DB, dbName string, tableName string) (string, error) {
 		}
 	}
 
+	if err = rows.Err(); err != nil {
+		return "", fmt.Errorf("error iterating rows: %w", err)
+	}
+	if ddl == "" {
+		return "", fmt.Errorf("table %s not found", tableName)
+	}
+
 	return ddl, nil
 }
 
@@ -83,9 +100,21 @@ func GetNonGeneratedColumns(columns []Column) []string {
 	return nonVirtualColumns
 }
 
-func GetDatabaseDDL(db *sql.DB, dbName string) (string, error) {
+// GetBoolColumns returns the non-generated TINYINT(1) columns, which MySQL uses for BOOLEAN
+func GetBoolColumns(columns []Column) []string {
+	var boolColumns []string
+	for _, col := range columns {
+		typ := strings.ToLower(col.Type)
+		if !col.IsGenerated && (typ == "tinyint(1)" || typ == "tinyint(1) unsigned") {
+			boolColumns = append(boolColumns, col.Name)
+		}
+	}
+	return boolColumns
+}
+
+func GetDatabaseDDL(ctx context.Context, db dbConn.Querier, dbName string) (string, error) {
 	query := fmt.Sprintf("SHOW CREATE DATABASE `%s`;", dbName)
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return "", fmt.Errorf("failed to query information_schema: %w", err)
 	}
@@ -101,9 +130,9 @@ func GetDatabaseDDL(db *sql.DB, dbName string) (string, error) {
 	return ddl, nil
 }
 
-func ListAllTables(db *sql.DB) ([]string, error) {
+func ListAllTables(ctx context.Context, db dbConn.Querier) ([]string, error) {
 	query := "SHOW FULL TABLES WHERE Table_Type = 'BASE TABLE';"
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query information_schema: %w", err)
 	}
@@ -133,9 +162,9 @@ type TriggerInfo struct {
 	DDL      string
 }
 
-func AllTriggersDDL(db *sql.DB, dbName string) ([]*TriggerInfo, error) {
+func AllTriggersDDL(ctx context.Context, db dbConn.Querier, dbName string) ([]*TriggerInfo, error) {
 	query := fmt.Sprintf("SELECT `trigger_name` FROM `information_schema`.`triggers` WHERE `trigger_schema` = ?")
-	rows, err := db.Query(query, dbName)
+	rows, err := db.QueryContext(ctx, query, dbName)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query triggers: %w", err)
 	}
@@ -154,46 +183,184 @@ func AllTriggersDDL(db *sql.DB, dbName string) ([]*TriggerInfo, error) {
 	triggersDDL := make([]*TriggerInfo, 0)
 
 	for _, triggerName := range triggers {
-		query := fmt.Sprintf("SHOW CREATE TRIGGER `%s`.`%s`", dbName, triggerName)
-		rows, err := db.Query(query)
+		info, err := GetTriggerDDL(ctx, db, dbName, triggerName)
 		if err != nil {
-			return nil, fmt.Errorf("failed to query trigger DDL: %w", err)
+			return nil, err
+		}
+		triggersDDL = append(triggersDDL, info)
+	}
+
+	return triggersDDL, nil
+}
+
+// ListTriggers returns the triggers of a table in the order the server fires them
+func ListTriggers(ctx context.Context, db dbConn.Querier, dbName, table string) ([]string, error) {
+	query := "SELECT `TRIGGER_NAME` FROM `information_schema`.`TRIGGERS` WHERE `EVENT_OBJECT_SCHEMA` = ? AND `EVENT_OBJECT_TABLE` = ? ORDER BY `ACTION_ORDER`, `TRIGGER_NAME`"
+	rows, err := db.QueryContext(ctx, query, dbName, table)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query triggers: %w", err)
+	}
+	defer rows.Close()
+
+	var triggers []string
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, fmt.Errorf("failed to scan trigger name: %w", err)
+		}
+		triggers = append(triggers, name)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return triggers, nil
+}
+
+// GetTriggerDDL returns the CREATE TRIGGER statement of a trigger and the sql_mode it was created with
+func GetTriggerDDL(ctx context.Context, db dbConn.Querier, dbName, trigger string) (*TriggerInfo, error) {
+	query := fmt.Sprintf("SHOW CREATE TRIGGER `%s`.`%s`", escapeIdentifier(dbName), escapeIdentifier(trigger))
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query trigger DDL: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column names: %w", err)
+	}
+	params := make([]interface{}, len(columns))
+	info := new(TriggerInfo)
+	var tmp sql.RawBytes
+	for i, col := range columns {
+		if strings.Contains(col, "sql_mode") {
+			params[i] = &(info.SQL_MODE)
+			continue
+		}
+		if strings.Contains(col, "Trigger") {
+			params[i] = &(info.Name)
+			continue
 		}
-		defer rows.Close()
+		if strings.Contains(col, "Statement") {
+			params[i] = &(info.DDL)
+			continue
+		}
+		params[i] = &tmp
+	}
 
-		columns, err := rows.Columns()
+	for rows.Next() {
+		err := rows.Scan(params...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get column names: %w", err)
+			return nil, fmt.Errorf("failed to scan trigger DDL: %w", err)
 		}
-		params := make([]interface{}, len(columns))
-		info := new(TriggerInfo)
-		var tmp string
-		for i, col := range columns {
-			if strings.Contains(col, "sql_mode") {
-				params[i] = &(info.SQL_MODE)
-				continue
-			}
-			if strings.Contains(col, "Trigger") {
-				params[i] = &(info.Name)
-				continue
-			}
-			if strings.Contains(col, "Statement") {
-				params[i] = &(info.DDL)
-				continue
-			}
-			params[i] = &tmp
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	if info.DDL == "" {
+		return nil, fmt.Errorf("trigger %s not found", trigger)
+	}
+	return info, nil
+}
+
+// escapeIdentifier 转义反引号中的标识符
+func escapeIdentifier(name string) string {
+	return strings.ReplaceAll(name, "`", "``")
+}
+
+// 存储过程和函数的类型，与 information_schema.ROUTINES 中的 ROUTINE_TYPE 相同
+const (
+	RoutineProcedure = "PROCEDURE"
+	RoutineFunction  = "FUNCTION"
+)
+
+// Routine names a stored procedure or function
+type Routine struct {
+	// Type is RoutineProcedure or RoutineFunction
+	Type string
+	Name string
+}
+
+// RoutineInfo holds the CREATE statement of a routine and the sql_mode it was created with
+type RoutineInfo struct {
+	Routine
+	SQLMode string
+	DDL     string
+}
+
+// ListRoutines returns the stored procedures and functions of a database sorted by type and name
+func ListRoutines(ctx context.Context, db dbConn.Querier, dbName string) ([]Routine, error) {
+	query := "SELECT `ROUTINE_TYPE`, `ROUTINE_NAME` FROM `information_schema`.`ROUTINES` WHERE `ROUTINE_SCHEMA` = ? AND `ROUTINE_TYPE` IN ('FUNCTION', 'PROCEDURE') ORDER BY `ROUTINE_TYPE`, `ROUTINE_NAME`"
+	rows, err := db.QueryContext(ctx, query, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routines: %w", err)
+	}
+	defer rows.Close()
+
+	var routines []Routine
+	for rows.Next() {
+		var routine Routine
+		if err := rows.Scan(&routine.Type, &routine.Name); err != nil {
+			return nil, fmt.Errorf("failed to scan routine: %w", err)
 		}
+		routines = append(routines, routine)
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	return routines, nil
+}
+
+// GetRoutineDDL returns the CREATE PROCEDURE or CREATE FUNCTION statement of a routine. The server
+// returns NULL instead of the statement to users that are not allowed to see the body
+func GetRoutineDDL(ctx context.Context, db dbConn.Querier, dbName string, routine Routine) (*RoutineInfo, error) {
+	if routine.Type != RoutineProcedure && routine.Type != RoutineFunction {
+		return nil, fmt.Errorf("unknown routine type %q", routine.Type)
+	}
+	query := fmt.Sprintf("SHOW CREATE %s `%s`.`%s`", routine.Type, escapeIdentifier(dbName), escapeIdentifier(routine.Name))
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query routine DDL: %w", err)
+	}
+	defer rows.Close()
 
-		for rows.Next() {
-			err := rows.Scan(params...)
-			if err != nil {
-				return nil, fmt.Errorf("failed to scan trigger DDL: %w", err)
-			}
-			triggersDDL = append(triggersDDL, info)
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get column names: %w", err)
+	}
+	info := &RoutineInfo{Routine: routine}
+	var ddl sql.NullString
+	var tmp sql.RawBytes
+	params := make([]interface{}, len(columns))
+	for i, col := range columns {
+		switch {
+		case col == "sql_mode":
+			params[i] = &info.SQLMode
+		case strings.HasPrefix(col, "Create "):
+			params[i] = &ddl
+		default:
+			params[i] = &tmp
 		}
 	}
 
-	return triggersDDL, nil
+	found := false
+	for rows.Next() {
+		if err := rows.Scan(params...); err != nil {
+			return nil, fmt.Errorf("failed to scan routine DDL: %w", err)
+		}
+		found = true
+	}
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	switch {
+	case !found:
+		return nil, fmt.Errorf("%s %s not found", strings.ToLower(routine.Type), routine.Name)
+	case !ddl.Valid:
+		return nil, fmt.Errorf("no privilege to read the body of %s %s", strings.ToLower(routine.Type), routine.Name)
+	}
+	info.DDL = ddl.String
+	return info, nil
 }
 
 type ViewInfo struct {
@@ -201,17 +368,34 @@ type ViewInfo struct {
 	Name string
 }
 
-func AllViewDDL(db *sql.DB) ([]*ViewInfo, error) {
+func AllViewDDL(ctx context.Context, db dbConn.Querier) ([]*ViewInfo, error) {
+	views, err := ListViews(ctx, db)
+	if err != nil {
+		return nil, err
+	}
+
+	viewDDL := make([]*ViewInfo, 0, len(views))
+	for _, view := range views {
+		info, err := GetViewDDL(ctx, db, view)
+		if err != nil {
+			return nil, err
+		}
+		viewDDL = append(viewDDL, info)
+	}
+
+	return viewDDL, nil
+}
+
+// ListViews returns the names of the views in the current database
+func ListViews(ctx context.Context, db dbConn.Querier) ([]string, error) {
 	query := "SHOW FULL TABLES WHERE Table_Type = 'VIEW';"
-	rows, err := db.Query(query)
+	rows, err := db.QueryContext(ctx, query)
 	if err != nil {
 		return nil, fmt.Errorf("failed to query view DDL: %w", err)
 	}
 	defer rows.Close()
 
 	views := make([]string, 0)
-	viewDDL := make([]*ViewInfo, 0)
-
 	for rows.Next() {
 		var viewName, tableType string
 		err := rows.Scan(&viewName, &tableType)
@@ -221,45 +405,116 @@ func AllViewDDL(db *sql.DB) ([]*ViewInfo, error) {
 		views = append(views, viewName)
 	}
 
-	for _, view := range views {
-		query := fmt.Sprintf("SHOW CREATE VIEW %s", view)
-		rows, err := db.Query(query)
-		if err != nil {
-			return nil, fmt.Errorf("failed to query view DDL: %w", err)
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return views, nil
+}
+
+// GetViewDDL returns the CREATE VIEW statement of a view
+func GetViewDDL(ctx context.Context, db dbConn.Querier, view string) (*ViewInfo, error) {
+	query := fmt.Sprintf("SHOW CREATE VIEW `%s`", view)
+	rows, err := db.QueryContext(ctx, query)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query view DDL: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return nil, fmt.Errorf("failed to get view DDL columns: %w", err)
+	}
+	var tmp string
+	info := new(ViewInfo)
+	params := make([]interface{}, len(columns))
+	for i, col := range columns {
+		if strings.Contains(col, "Create") {
+			params[i] = &info.DDL
+			continue
+		}
+		if strings.Contains(col, "View") {
+			params[i] = &info.Name
+			continue
 		}
-		defer rows.Close()
+		params[i] = &tmp
+	}
 
-		columns, err := rows.Columns()
+	for rows.Next() {
+		err := rows.Scan(params...)
 		if err != nil {
-			return nil, fmt.Errorf("failed to get view DDL columns: %w", err)
+			return nil, fmt.Errorf("failed to scan view DDL: %w", err)
 		}
-		var tmp string
-		var viewName string
-		var DDL string
-		params := make([]interface{}, len(columns))
-		for i, col := range columns {
-			if strings.Contains(col, "Create") {
-				params[i] = &DDL
-				continue
-			}
-			if strings.Contains(col, "View") {
-				params[i] = &viewName
-				continue
-			}
-			params[i] = &tmp
+	}
+
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+	if info.DDL == "" {
+		return nil, fmt.Errorf("view %s not found", view)
+	}
+
+	return info, nil
+}
+
+// TableStat holds the estimates information_schema keeps for a table
+type TableStat struct {
+	Name        string
+	Rows        int64
+	DataLength  int64
+	IndexLength int64
+}
+
+// GetTableStats returns the estimated row count and data length of every base table,
+// the values come from information_schema.TABLES and no table data is read
+func GetTableStats(ctx context.Context, db dbConn.Querier, dbName string) (map[string]TableStat, error) {
+	query := "SELECT `TABLE_NAME`, COALESCE(`TABLE_ROWS`, 0), COALESCE(`DATA_LENGTH`, 0), COALESCE(`INDEX_LENGTH`, 0) " +
+		"FROM `information_schema`.`TABLES` WHERE `TABLE_SCHEMA` = ? AND `TABLE_TYPE` = 'BASE TABLE'"
+	rows, err := db.QueryContext(ctx, query, dbName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query table stats: %w", err)
+	}
+	defer rows.Close()
+
+	stats := make(map[string]TableStat)
+	for rows.Next() {
+		var stat TableStat
+		err := rows.Scan(&stat.Name, &stat.Rows, &stat.DataLength, &stat.IndexLength)
+		if err != nil {
+			return nil, fmt.Errorf("failed to scan table stats: %w", err)
 		}
+		stats[stat.Name] = stat
+	}
 
-		for rows.Next() {
-			err := rows.Scan(params...)
-			if err != nil {
-				return nil, fmt.Errorf("failed to scan view DDL: %w", err)
-			}
-			viewDDL = append(viewDDL, &ViewInfo{
-				DDL:  DDL,
-				Name: viewName,
-			})
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return stats, nil
+}
+
+// GetPrimaryKey returns the primary key columns of a table in index order, or nil when the table has no primary key
+func GetPrimaryKey(ctx context.Context, db dbConn.Querier, dbName, tableName string) ([]string, error) {
+	query := "SELECT `COLUMN_NAME` FROM `information_schema`.`STATISTICS` " +
+		"WHERE `TABLE_SCHEMA` = ? AND `TABLE_NAME` = ? AND `INDEX_NAME` = 'PRIMARY' ORDER BY `SEQ_IN_INDEX`"
+	rows, err := db.QueryContext(ctx, query, dbName, tableName)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query primary key: %w", err)
+	}
+	defer rows.Close()
+
+	var columns []string
+	for rows.Next() {
+		var column string
+		if err := rows.Scan(&column); err != nil {
+			return nil, fmt.Errorf("failed to scan primary key: %w", err)
 		}
+		columns = append(columns, column)
 	}
 
-	return viewDDL, nil
+	if err = rows.Err(); err != nil {
+		return nil, fmt.Errorf("error iterating rows: %w", err)
+	}
+
+	return columns, nil
 }
@@ -1,11 +1,14 @@
 package schema
 
 import (
+	"context"
 	"database/sql"
 	"encoding/json"
 	"motors-backup/internal/config"
 	"motors-backup/internal/db"
+	"motors-backup/internal/flavor"
 	"os"
+	"strings"
 	"testing"
 )
 
@@ -14,7 +17,7 @@ func GetTestConfig() (*sql.DB, string, string, error) {
 
 	//log.Logger.Debugf("%+v", conf)
 
-	db, err := db.Connect(conf)
+	db, err := db.Connect(context.Background(), conf)
 	if err != nil {
 		return nil, "", "", err
 	}
@@ -24,12 +27,21 @@ func GetTestConfig() (*sql.DB, string, string, error) {
 	return db, conf.DBName, testTableName, nil
 }
 
+// testServer 查询测试数据库的版本
+func testServer(t *testing.T, dbConn *sql.DB) flavor.Server {
+	var version, comment string
+	if err := dbConn.QueryRow("SELECT VERSION(), @@version_comment").Scan(&version, &comment); err != nil {
+		t.Fatalf("Failed to get server version: %v", err)
+	}
+	return flavor.Parse(version, comment)
+}
+
 func TestListAllTables(t *testing.T) {
 	dbConn, _, _, err := GetTestConfig()
 	if err != nil {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
-	tables, err := ListAllTables(dbConn)
+	tables, err := ListAllTables(context.Background(), dbConn)
 	if err != nil {
 		t.Errorf("Failed to list tables: %v", err)
 	}
@@ -41,7 +53,7 @@ func TestAnalyzeColumns(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
-	columns, err := AnalyzeColumns(dbConn, dbName, tableName)
+	columns, err := AnalyzeColumns(context.Background(), dbConn, testServer(t, dbConn), dbName, tableName)
 	if err != nil {
 		t.Errorf("Failed to analyze columns: %v", err)
 	}
@@ -55,7 +67,7 @@ func TestGetTableDDL(t *testing.T) {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	ddl, err := GetTableDDL(dbConn, dbName, tableName)
+	ddl, err := GetTableDDL(context.Background(), dbConn, dbName, tableName)
 	if err != nil {
 		t.Errorf("Failed to get table DDL: %v", err)
 	}
@@ -68,7 +80,7 @@ func TestGetNonGeneratedColumns(t *testing.T) {
 	if err != nil {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
-	columns, err := AnalyzeColumns(dbConn, dbName, tableName)
+	columns, err := AnalyzeColumns(context.Background(), dbConn, testServer(t, dbConn), dbName, tableName)
 	if err != nil {
 		t.Errorf("Failed to analyze columns: %v", err)
 	}
@@ -76,12 +88,25 @@ func TestGetNonGeneratedColumns(t *testing.T) {
 	t.Logf("Non-generated columns: %+v", nonGeneratedColumns)
 }
 
+func TestGetBoolColumns(t *testing.T) {
+	columns := []Column{
+		{Name: "id", Type: "int"},
+		{Name: "active", Type: "tinyint(1)"},
+		{Name: "deleted", Type: "TINYINT(1) UNSIGNED"},
+		{Name: "level", Type: "tinyint(4)"},
+		{Name: "is_admin", Type: "tinyint(1)", IsGenerated: true},
+	}
+	if got := GetBoolColumns(columns); strings.Join(got, ",") != "active,deleted" {
+		t.Errorf("GetBoolColumns() = %v, want [active deleted]", got)
+	}
+}
+
 func TestAllTriggersDDL(t *testing.T) {
 	dbConn, dbName, _, err := GetTestConfig()
 	if err != nil {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
-	triggers, err := AllTriggersDDL(dbConn, dbName)
+	triggers, err := AllTriggersDDL(context.Background(), dbConn, dbName)
 	if err != nil {
 		t.Errorf("Failed to get triggers: %v", err)
 	}
@@ -99,7 +124,7 @@ func TestAllViewDDL(t *testing.T) {
 		t.Fatalf("Failed to connect to database: %v", err)
 	}
 
-	views, err := AllViewDDL(dbConn)
+	views, err := AllViewDDL(context.Background(), dbConn)
 	if err != nil {
 		t.Errorf("Failed to get views: %v", err)
 	}
@@ -109,3 +134,29 @@ func TestAllViewDDL(t *testing.T) {
 	}
 	t.Logf("Views: %s", string(b))
 }
+
+func TestGetTableStats(t *testing.T) {
+	dbConn, dbName, _, err := GetTestConfig()
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	stats, err := GetTableStats(context.Background(), dbConn, dbName)
+	if err != nil {
+		t.Errorf("Failed to get table stats: %v", err)
+	}
+	t.Logf("Table stats: %+v", stats)
+}
+
+func TestGetPrimaryKey(t *testing.T) {
+	dbConn, dbName, tableName, err := GetTestConfig()
+	if err != nil {
+		t.Fatalf("Failed to connect to database: %v", err)
+	}
+
+	keys, err := GetPrimaryKey(context.Background(), dbConn, dbName, tableName)
+	if err != nil {
+		t.Errorf("Failed to get primary key: %v", err)
+	}
+	t.Logf("Primary key: %v", keys)
+}
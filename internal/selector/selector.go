@@ -0,0 +1,121 @@
+// Package selector matches table names against exact names, glob patterns and regular expressions
+package selector
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// RegexpPrefix marks a selector as a regular expression, e.g. re:^tmp_
+const RegexpPrefix = "re:"
+
+// Selector matches table names. Selectors starting with re: are regular expressions, selectors
+// containing *, ? or [ are glob patterns as in path.Match, everything else is an exact name
+type Selector struct {
+	pattern string
+	re      *regexp.Regexp
+	glob    bool
+}
+
+// Parse parses a selector and checks its pattern
+func Parse(pattern string) (Selector, error) {
+	pattern = strings.TrimSpace(pattern)
+	if pattern == "" {
+		return Selector{}, fmt.Errorf("empty table selector")
+	}
+	s := Selector{pattern: pattern}
+	if expr, ok := strings.CutPrefix(pattern, RegexpPrefix); ok {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return Selector{}, fmt.Errorf("invalid table selector %q: %w", pattern, err)
+		}
+		s.re = re
+		return s, nil
+	}
+	if strings.ContainsAny(pattern, "*?[") {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return Selector{}, fmt.Errorf("invalid table selector %q: %w", pattern, err)
+		}
+		s.glob = true
+	}
+	return s, nil
+}
+
+// ParseAll parses a list of selectors, blank entries are skipped
+func ParseAll(patterns []string) ([]Selector, error) {
+	selectors := make([]Selector, 0, len(patterns))
+	for _, pattern := range patterns {
+		if strings.TrimSpace(pattern) == "" {
+			continue
+		}
+		s, err := Parse(pattern)
+		if err != nil {
+			return nil, err
+		}
+		selectors = append(selectors, s)
+	}
+	return selectors, nil
+}
+
+// IsPattern reports whether the selector is a glob pattern or a regular expression
+func (s Selector) IsPattern() bool {
+	return s.re != nil || s.glob
+}
+
+// Match reports whether the table name is selected
+func (s Selector) Match(name string) bool {
+	switch {
+	case s.re != nil:
+		return s.re.MatchString(name)
+	case s.glob:
+		matched, _ := path.Match(s.pattern, name)
+		return matched
+	default:
+		return s.pattern == name
+	}
+}
+
+// String returns the selector as it was written
+func (s Selector) String() string {
+	return s.pattern
+}
+
+// MatchAny reports whether name matches one of the patterns, invalid patterns match nothing
+func MatchAny(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if s, err := Parse(pattern); err == nil && s.Match(name) {
+			return true
+		}
+	}
+	return false
+}
+
+// ReadFile reads one selector per line, blank lines and lines starting with # are skipped
+func ReadFile(name string) ([]string, error) {
+	f, err := os.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read tables file: %w", err)
+	}
+	defer f.Close()
+
+	var patterns []string
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		if _, err := Parse(text); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", name, line, err)
+		}
+		patterns = append(patterns, text)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("failed to read tables file: %w", err)
+	}
+	return patterns, nil
+}
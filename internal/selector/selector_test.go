@@ -0,0 +1,72 @@
+package selector
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func TestMatch(t *testing.T) {
+	tests := []struct {
+		pattern string
+		name    string
+		want    bool
+	}{
+		{"users", "users", true},
+		{"users", "users_old", false},
+		{"log_2024_*", "log_2024_01", true},
+		{"log_2024_*", "log_2023_12", false},
+		{"tmp_?", "tmp_a", true},
+		{"tmp_?", "tmp_ab", false},
+		{"order[s_]*", "order_items", true},
+		{"re:^tmp_", "tmp_import", true},
+		{"re:^tmp_", "users_tmp_", false},
+		{"re:_(old|bak)$", "users_bak", true},
+		{" users ", "users", true},
+	}
+	for _, tc := range tests {
+		s, err := Parse(tc.pattern)
+		if err != nil {
+			t.Fatalf("Parse(%q) returned error: %v", tc.pattern, err)
+		}
+		if got := s.Match(tc.name); got != tc.want {
+			t.Errorf("Parse(%q).Match(%q) = %v, want %v", tc.pattern, tc.name, got, tc.want)
+		}
+	}
+
+	for _, invalid := range []string{"", "re:(", "log_[", "  "} {
+		if _, err := Parse(invalid); err == nil {
+			t.Errorf("Parse(%q) returned no error", invalid)
+		}
+	}
+}
+
+func TestMatchAny(t *testing.T) {
+	patterns := []string{"logs", "re:^tmp_", "re:("}
+	if !MatchAny(patterns, "logs") || !MatchAny(patterns, "tmp_x") || MatchAny(patterns, "users") {
+		t.Error("MatchAny() returned wrong results")
+	}
+}
+
+func TestReadFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "tables.txt")
+	content := "# 需要导出的表\nusers\n\n  orders  \nlog_2024_*\nre:^audit_\n"
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	got, err := ReadFile(path)
+	if err != nil {
+		t.Fatalf("ReadFile returned error: %v", err)
+	}
+	if want := []string{"users", "orders", "log_2024_*", "re:^audit_"}; !slices.Equal(got, want) {
+		t.Errorf("ReadFile() = %q, want %q", got, want)
+	}
+
+	if err := os.WriteFile(path, []byte("users\nre:(\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := ReadFile(path); err == nil {
+		t.Error("expected error for an invalid selector")
+	}
+}
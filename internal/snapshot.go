@@ -0,0 +1,182 @@
+package internal
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"motors-backup/internal/exporter"
+	"motors-backup/internal/flavor"
+	"motors-backup/internal/manifest"
+	"strings"
+)
+
+// Source data modes of --source-data
+const (
+	SourceDataOff       = 0
+	SourceDataStatement = 1
+	SourceDataComment   = 2
+)
+
+// Snapshot is a consistent snapshot transaction on a dedicated connection, every query of a dump
+// that records its binary log position must run on Conn
+type Snapshot struct {
+	Conn     *sql.Conn
+	Position manifest.BinlogPosition
+}
+
+// StartSnapshot takes the global read lock, starts a consistent snapshot transaction and reads the
+// binary log position and GTID set before releasing the lock, so that the position matches the
+// data read in the transaction. It requires the RELOAD privilege and an enabled binary log
+func StartSnapshot(ctx context.Context, database *sql.DB, server flavor.Server) (*Snapshot, error) {
+	conn, err := database.Conn(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open snapshot connection: %w", err)
+	}
+
+	s := &Snapshot{Conn: conn}
+	if err := s.start(ctx, server); err != nil {
+		discardConn(conn)
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Snapshot) start(ctx context.Context, server flavor.Server) (err error) {
+	// 先刷新表，缩短持有全局读锁的时间
+	if _, err := s.Conn.ExecContext(ctx, "FLUSH /*!40101 LOCAL */ TABLES"); err != nil {
+		return fmt.Errorf("failed to flush tables: %w", err)
+	}
+	if _, err := s.Conn.ExecContext(ctx, "FLUSH TABLES WITH READ LOCK"); err != nil {
+		return fmt.Errorf("failed to take the global read lock, the RELOAD privilege is required: %w", err)
+	}
+	defer func() {
+		if _, unlockErr := s.Conn.ExecContext(ctx, "UNLOCK TABLES"); unlockErr != nil && err == nil {
+			err = fmt.Errorf("failed to release the global read lock: %w", unlockErr)
+		}
+	}()
+
+	if _, err := s.Conn.ExecContext(ctx, "SET SESSION TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+		return fmt.Errorf("failed to set isolation level: %w", err)
+	}
+	if _, err := s.Conn.ExecContext(ctx, "START TRANSACTION /*!40100 WITH CONSISTENT SNAPSHOT */"); err != nil {
+		return fmt.Errorf("failed to start consistent snapshot: %w", err)
+	}
+
+	s.Position, err = readBinlogPosition(ctx, s.Conn, server)
+	return err
+}
+
+// Close ends the snapshot transaction and closes the connection
+func (s *Snapshot) Close() error {
+	if _, err := s.Conn.ExecContext(context.Background(), "COMMIT"); err != nil {
+		discardConn(s.Conn)
+		return fmt.Errorf("failed to end snapshot transaction: %w", err)
+	}
+	return s.Conn.Close()
+}
+
+// discardConn 关闭底层连接而不放回连接池，锁和事务随连接一起释放
+func discardConn(conn *sql.Conn) {
+	conn.Raw(func(any) error {
+		return driver.ErrBadConn
+	})
+	conn.Close()
+}
+
+// binlogStatusQuery 返回查询 binlog 位置的语句，MySQL 8.2 起 SHOW MASTER STATUS 被 SHOW BINARY LOG STATUS 取代
+func binlogStatusQuery(server flavor.Server) string {
+	if server.Flavor != flavor.MariaDB && server.AtLeast(8, 2) {
+		return "SHOW BINARY LOG STATUS"
+	}
+	return "SHOW MASTER STATUS"
+}
+
+// readBinlogPosition 读取当前的 binlog 文件、位置和 GTID 集合
+func readBinlogPosition(ctx context.Context, conn *sql.Conn, server flavor.Server) (manifest.BinlogPosition, error) {
+	var pos manifest.BinlogPosition
+	rows, err := conn.QueryContext(ctx, binlogStatusQuery(server))
+	if err != nil {
+		return pos, fmt.Errorf("failed to query binary log position: %w", err)
+	}
+	defer rows.Close()
+
+	columns, err := rows.Columns()
+	if err != nil {
+		return pos, fmt.Errorf("failed to get binary log status columns: %w", err)
+	}
+	var tmp sql.NullString
+	var gtidSet sql.NullString
+	params := make([]interface{}, len(columns))
+	for i, col := range columns {
+		switch col {
+		case "File":
+			params[i] = &pos.File
+		case "Position":
+			params[i] = &pos.Position
+		case "Executed_Gtid_Set":
+			params[i] = &gtidSet
+		default:
+			params[i] = &tmp
+		}
+	}
+
+	if !rows.Next() {
+		if err := rows.Err(); err != nil {
+			return pos, fmt.Errorf("failed to read binary log position: %w", err)
+		}
+		return pos, fmt.Errorf("binary logging is not enabled on the server")
+	}
+	if err := rows.Scan(params...); err != nil {
+		return pos, fmt.Errorf("failed to scan binary log position: %w", err)
+	}
+	rows.Close()
+
+	// MariaDB 的 GTID 不在 SHOW MASTER STATUS 中
+	if server.Flavor == flavor.MariaDB {
+		if err := conn.QueryRowContext(ctx, "SELECT @@GLOBAL.gtid_binlog_pos").Scan(&gtidSet); err != nil {
+			return pos, fmt.Errorf("failed to query gtid_binlog_pos: %w", err)
+		}
+	}
+	pos.GTIDSet = strings.ReplaceAll(gtidSet.String, "\n", "")
+	return pos, nil
+}
+
+// PrintSourceData writes the binary log position of the snapshot as a CHANGE REPLICATION SOURCE TO
+// statement and the GTID set as a SET statement, both are commented out with SourceDataComment.
+// The comment headers are omitted with format.NoComments, the statements are always written
+func PrintSourceData(w io.Writer, server flavor.Server, pos manifest.BinlogPosition, mode int, format Format) {
+	prefix := ""
+	if mode == SourceDataComment {
+		prefix = "-- "
+	}
+
+	format.printComment(w, "Position to start replication or point-in-time recovery from")
+	if !format.NoComments {
+		fmt.Fprintln(w)
+	}
+	fmt.Fprintf(w, "%s%s\n\n", prefix, changeSourceStatement(server, pos))
+
+	if pos.GTIDSet == "" {
+		return
+	}
+	format.printComment(w, "GTID state at the beginning of the backup")
+	if !format.NoComments {
+		fmt.Fprintln(w)
+	}
+	if server.Flavor == flavor.MariaDB {
+		fmt.Fprintf(w, "%sSET GLOBAL gtid_slave_pos=%s;\n\n", prefix, exporter.EscapeSQLString(pos.GTIDSet))
+		return
+	}
+	fmt.Fprintf(w, "%sSET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ %s;\n\n", prefix, exporter.EscapeSQLString(pos.GTIDSet))
+}
+
+// changeSourceStatement 返回指向快照位置的复制语句，MySQL 8.0.23 之前和 MariaDB 使用 CHANGE MASTER TO
+func changeSourceStatement(server flavor.Server, pos manifest.BinlogPosition) string {
+	file := exporter.EscapeSQLString(pos.File)
+	if server.Flavor != flavor.MariaDB && (server.AtLeast(8, 1) || server.Major == 8 && server.Minor == 0 && server.Patch >= 23) {
+		return fmt.Sprintf("CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE=%s, SOURCE_LOG_POS=%d;", file, pos.Position)
+	}
+	return fmt.Sprintf("CHANGE MASTER TO MASTER_LOG_FILE=%s, MASTER_LOG_POS=%d;", file, pos.Position)
+}
@@ -0,0 +1,97 @@
+package internal
+
+import (
+	"bytes"
+	"motors-backup/internal/flavor"
+	"motors-backup/internal/manifest"
+	"testing"
+)
+
+func TestPrintSourceData(t *testing.T) {
+	pos := manifest.BinlogPosition{File: "binlog.000042", Position: 157, GTIDSet: "3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5"}
+
+	tests := []struct {
+		name    string
+		version string
+		pos     manifest.BinlogPosition
+		mode    int
+		format  Format
+		want    string
+	}{
+		{
+			name:    "mysql 8.0 statements",
+			version: "8.0.36",
+			pos:     pos,
+			mode:    SourceDataStatement,
+			want: "--\n-- Position to start replication or point-in-time recovery from\n--\n\n" +
+				"CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000042', SOURCE_LOG_POS=157;\n\n" +
+				"--\n-- GTID state at the beginning of the backup\n--\n\n" +
+				"SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ '3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5';\n\n",
+		},
+		{
+			name:    "mysql 5.7 comments without gtid",
+			version: "5.7.44-log",
+			pos:     manifest.BinlogPosition{File: "mysql-bin.000003", Position: 4},
+			mode:    SourceDataComment,
+			want: "--\n-- Position to start replication or point-in-time recovery from\n--\n\n" +
+				"-- CHANGE MASTER TO MASTER_LOG_FILE='mysql-bin.000003', MASTER_LOG_POS=4;\n\n",
+		},
+		{
+			name:    "mariadb comments",
+			version: "10.6.12-MariaDB",
+			pos:     manifest.BinlogPosition{File: "mariadb-bin.000007", Position: 328, GTIDSet: "0-1-100"},
+			mode:    SourceDataComment,
+			want: "--\n-- Position to start replication or point-in-time recovery from\n--\n\n" +
+				"-- CHANGE MASTER TO MASTER_LOG_FILE='mariadb-bin.000007', MASTER_LOG_POS=328;\n\n" +
+				"--\n-- GTID state at the beginning of the backup\n--\n\n" +
+				"-- SET GLOBAL gtid_slave_pos='0-1-100';\n\n",
+		},
+		{
+			name:    "skip comments",
+			version: "8.0.36",
+			pos:     pos,
+			mode:    SourceDataComment,
+			format:  Format{NoComments: true},
+			want: "-- CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000042', SOURCE_LOG_POS=157;\n\n" +
+				"-- SET @@GLOBAL.GTID_PURGED=/*!80000 '+'*/ '3e11fa47-71ca-11e1-9e33-c80aa9429562:1-5';\n\n",
+		},
+	}
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			var buf bytes.Buffer
+			PrintSourceData(&buf, flavor.Parse(tc.version, ""), tc.pos, tc.mode, tc.format)
+			if buf.String() != tc.want {
+				t.Errorf("PrintSourceData() = %q, want %q", buf.String(), tc.want)
+			}
+		})
+	}
+}
+
+func TestChangeSourceStatement(t *testing.T) {
+	pos := manifest.BinlogPosition{File: "binlog.000001", Position: 4}
+	for version, want := range map[string]string{
+		"8.0.22":          "CHANGE MASTER TO MASTER_LOG_FILE='binlog.000001', MASTER_LOG_POS=4;",
+		"8.0.23":          "CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000001', SOURCE_LOG_POS=4;",
+		"8.4.0":           "CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000001', SOURCE_LOG_POS=4;",
+		"11.4.2-MariaDB":  "CHANGE MASTER TO MASTER_LOG_FILE='binlog.000001', MASTER_LOG_POS=4;",
+		"5.7.44-48-log":   "CHANGE MASTER TO MASTER_LOG_FILE='binlog.000001', MASTER_LOG_POS=4;",
+		"9.1.0-community": "CHANGE REPLICATION SOURCE TO SOURCE_LOG_FILE='binlog.000001', SOURCE_LOG_POS=4;",
+	} {
+		if got := changeSourceStatement(flavor.Parse(version, ""), pos); got != want {
+			t.Errorf("changeSourceStatement(%s) = %q, want %q", version, got, want)
+		}
+	}
+}
+
+func TestBinlogStatusQuery(t *testing.T) {
+	for version, want := range map[string]string{
+		"5.7.44":         "SHOW MASTER STATUS",
+		"8.0.36":         "SHOW MASTER STATUS",
+		"8.4.0":          "SHOW BINARY LOG STATUS",
+		"11.4.2-MariaDB": "SHOW MASTER STATUS",
+	} {
+		if got := binlogStatusQuery(flavor.Parse(version, "")); got != want {
+			t.Errorf("binlogStatusQuery(%s) = %q, want %q", version, got, want)
+		}
+	}
+}
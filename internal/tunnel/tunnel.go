@@ -0,0 +1,230 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/agent"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+const defaultPort = "22"
+
+// Config describes the SSH connection to the bastion host
+type Config struct {
+	// Host 为 host 或 host:port，默认端口 22
+	Host string
+	// User defaults to the current OS user
+	User string
+	// KeyFile 为私钥文件，未指定时使用 SSH_AUTH_SOCK 中的 ssh-agent
+	KeyFile string
+	// KnownHostsFile defaults to ~/.ssh/known_hosts, unknown host keys are always rejected
+	KnownHostsFile string
+	Timeout        time.Duration
+}
+
+// Tunnel is an SSH connection through which database connections are dialed, a dropped
+// SSH connection is reopened by the next dial
+type Tunnel struct {
+	addr   string
+	config *ssh.ClientConfig
+	agent  net.Conn
+
+	mu     sync.Mutex
+	client *ssh.Client
+	closed bool
+}
+
+// Open connects and authenticates to the SSH server
+func Open(cfg Config) (*Tunnel, error) {
+	addr := cfg.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, defaultPort)
+	}
+
+	username := cfg.User
+	if username == "" {
+		current, err := user.Current()
+		if err != nil {
+			return nil, fmt.Errorf("ssh user is required: %w", err)
+		}
+		username = current.Username
+	}
+
+	hostKeyCallback, err := knownHostsCallback(cfg.KnownHostsFile)
+	if err != nil {
+		return nil, err
+	}
+
+	t := &Tunnel{addr: addr}
+	auth, err := t.authMethods(cfg.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+	t.config = &ssh.ClientConfig{
+		User:              username,
+		Auth:              auth,
+		HostKeyCallback:   hostKeyCallback,
+		HostKeyAlgorithms: hostKeyAlgorithms(hostKeyCallback, addr),
+		Timeout:           cfg.Timeout,
+	}
+
+	client, err := t.connect()
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+	t.client = client
+	return t, nil
+}
+
+// connect 连接并认证 SSH 服务器
+func (t *Tunnel) connect() (*ssh.Client, error) {
+	client, err := ssh.Dial("tcp", t.addr, t.config)
+	if err != nil {
+		var keyErr *knownhosts.KeyError
+		if errors.As(err, &keyErr) && len(keyErr.Want) == 0 {
+			return nil, fmt.Errorf("host key of %s is not in the known_hosts file, add it with ssh-keyscan after checking its fingerprint: %w", t.addr, err)
+		}
+		return nil, fmt.Errorf("failed to connect to ssh server %s: %w", t.addr, err)
+	}
+	return client, nil
+}
+
+// DialContext dials addr from the SSH server, its signature matches the dial hook of the mysql driver.
+// When the SSH connection was lost it is reopened and the dial is tried once more
+func (t *Tunnel) DialContext(ctx context.Context, network, addr string) (net.Conn, error) {
+	t.mu.Lock()
+	client, closed := t.client, t.closed
+	t.mu.Unlock()
+	if closed {
+		return nil, fmt.Errorf("failed to dial %s through ssh tunnel: %w", addr, net.ErrClosed)
+	}
+
+	conn, err := client.DialContext(ctx, network, addr)
+	// 服务器拒绝转发说明 SSH 连接正常，只有其他错误才需要重新连接
+	var openErr *ssh.OpenChannelError
+	if err != nil && ctx.Err() == nil && !errors.As(err, &openErr) {
+		client, err = t.reconnect(client)
+		if err == nil {
+			conn, err = client.DialContext(ctx, network, addr)
+		}
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to dial %s through ssh tunnel: %w", addr, err)
+	}
+	return conn, nil
+}
+
+// reconnect 替换断开的 SSH 连接 old，其他连接已经重新连接时直接返回新的连接
+func (t *Tunnel) reconnect(old *ssh.Client) (*ssh.Client, error) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	if t.closed {
+		return nil, net.ErrClosed
+	}
+	if t.client != old {
+		return t.client, nil
+	}
+
+	old.Close()
+	client, err := t.connect()
+	if err != nil {
+		return nil, err
+	}
+	t.client = client
+	return client, nil
+}
+
+// Close closes the SSH connection and every connection dialed through it
+func (t *Tunnel) Close() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	t.closed = true
+	if t.agent != nil {
+		t.agent.Close()
+	}
+	if t.client != nil {
+		return t.client.Close()
+	}
+	return nil
+}
+
+// authMethods 优先使用私钥文件，否则使用 ssh-agent
+func (t *Tunnel) authMethods(keyFile string) ([]ssh.AuthMethod, error) {
+	if keyFile != "" {
+		pem, err := os.ReadFile(keyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read ssh key: %w", err)
+		}
+		signer, err := ssh.ParsePrivateKey(pem)
+		if err != nil {
+			var passphraseErr *ssh.PassphraseMissingError
+			if errors.As(err, &passphraseErr) {
+				return nil, fmt.Errorf("ssh key %s is protected by a passphrase, add it to ssh-agent and omit the key file", keyFile)
+			}
+			return nil, fmt.Errorf("failed to parse ssh key: %w", err)
+		}
+		return []ssh.AuthMethod{ssh.PublicKeys(signer)}, nil
+	}
+
+	socket := os.Getenv("SSH_AUTH_SOCK")
+	if socket == "" {
+		return nil, fmt.Errorf("no ssh key file given and SSH_AUTH_SOCK is not set")
+	}
+	conn, err := net.Dial("unix", socket)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to ssh-agent: %w", err)
+	}
+	t.agent = conn
+	return []ssh.AuthMethod{ssh.PublicKeysCallback(agent.NewClient(conn).Signers)}, nil
+}
+
+func knownHostsCallback(path string) (ssh.HostKeyCallback, error) {
+	if path == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("ssh known_hosts file is required: %w", err)
+		}
+		path = filepath.Join(home, ".ssh", "known_hosts")
+	}
+	callback, err := knownhosts.New(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// hostKeyAlgorithms 返回 known_hosts 中记录的该主机的 key 类型，
+// 避免服务器优先提供其他类型的 host key 时校验失败
+func hostKeyAlgorithms(callback ssh.HostKeyCallback, addr string) []string {
+	placeholder, err := ssh.NewPublicKey(ed25519.PublicKey(make([]byte, ed25519.PublicKeySize)))
+	if err != nil {
+		return nil
+	}
+
+	var keyErr *knownhosts.KeyError
+	if err := callback(addr, &net.TCPAddr{}, placeholder); !errors.As(err, &keyErr) {
+		return nil
+	}
+
+	var algorithms []string
+	for _, known := range keyErr.Want {
+		switch keyType := known.Key.Type(); keyType {
+		case ssh.KeyAlgoRSA:
+			algorithms = append(algorithms, ssh.KeyAlgoRSASHA512, ssh.KeyAlgoRSASHA256, ssh.KeyAlgoRSA)
+		default:
+			algorithms = append(algorithms, keyType)
+		}
+	}
+	return algorithms
+}
@@ -0,0 +1,283 @@
+package tunnel
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"io"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// testServer 是一个只支持公钥认证和 direct-tcpip 转发的 SSH 服务器
+type testServer struct {
+	addr    string
+	hostKey ssh.Signer
+
+	mu    sync.Mutex
+	conns []net.Conn
+}
+
+// drop 断开所有 SSH 连接，模拟断开的隧道
+func (s *testServer) drop() {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, conn := range s.conns {
+		conn.Close()
+	}
+	s.conns = nil
+}
+
+// accepted 返回当前的 SSH 连接数
+func (s *testServer) accepted() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.conns)
+}
+
+func newSigner(t *testing.T) (ssh.Signer, []byte) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("Failed to generate key: %v", err)
+	}
+	signer, err := ssh.NewSignerFromKey(private)
+	if err != nil {
+		t.Fatalf("Failed to create signer: %v", err)
+	}
+	block, err := ssh.MarshalPrivateKey(private, "")
+	if err != nil {
+		t.Fatalf("Failed to marshal key: %v", err)
+	}
+	return signer, pem.EncodeToMemory(block)
+}
+
+func startServer(t *testing.T, authorized ssh.PublicKey) *testServer {
+	hostKey, _ := newSigner(t)
+	config := &ssh.ServerConfig{
+		PublicKeyCallback: func(conn ssh.ConnMetadata, key ssh.PublicKey) (*ssh.Permissions, error) {
+			if conn.User() == "backup" && string(key.Marshal()) == string(authorized.Marshal()) {
+				return nil, nil
+			}
+			return nil, io.EOF
+		},
+	}
+	config.AddHostKey(hostKey)
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+
+	server := &testServer{addr: listener.Addr().String(), hostKey: hostKey}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			server.mu.Lock()
+			server.conns = append(server.conns, conn)
+			server.mu.Unlock()
+			go serveConn(conn, config)
+		}
+	}()
+	return server
+}
+
+func serveConn(conn net.Conn, config *ssh.ServerConfig) {
+	_, channels, requests, err := ssh.NewServerConn(conn, config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "only direct-tcpip is supported")
+			continue
+		}
+		var target struct {
+			Host       string
+			Port       uint32
+			OriginHost string
+			OriginPort uint32
+		}
+		if err := ssh.Unmarshal(newChannel.ExtraData(), &target); err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		upstream, err := net.Dial("tcp", net.JoinHostPort(target.Host, strconv.FormatUint(uint64(target.Port), 10)))
+		if err != nil {
+			newChannel.Reject(ssh.ConnectionFailed, err.Error())
+			continue
+		}
+		channel, channelRequests, err := newChannel.Accept()
+		if err != nil {
+			upstream.Close()
+			continue
+		}
+		go ssh.DiscardRequests(channelRequests)
+		go func() {
+			io.Copy(channel, upstream)
+			channel.Close()
+		}()
+		go func() {
+			io.Copy(upstream, channel)
+			upstream.Close()
+		}()
+	}
+}
+
+// startEcho 启动一个代替 MySQL 的回显服务
+func startEcho(t *testing.T) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	t.Cleanup(func() { listener.Close() })
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func() {
+				io.Copy(conn, conn)
+				conn.Close()
+			}()
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func writeFile(t *testing.T, name string, data []byte) string {
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatalf("Failed to write %s: %v", name, err)
+	}
+	return path
+}
+
+// openTunnel 打开到 server 的隧道，测试结束时关闭
+func openTunnel(t *testing.T, server *testServer, clientPEM []byte) *Tunnel {
+	knownHosts := writeFile(t, "known_hosts",
+		[]byte(knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, server.hostKey.PublicKey())+"\n"))
+	keyFile := writeFile(t, "id_ed25519", clientPEM)
+
+	tun, err := Open(Config{Host: server.addr, User: "backup", KeyFile: keyFile, KnownHostsFile: knownHosts, Timeout: 5 * time.Second})
+	if err != nil {
+		t.Fatalf("Open returned error: %v", err)
+	}
+	t.Cleanup(func() { tun.Close() })
+	return tun
+}
+
+// ping 经由隧道向回显服务发送数据并检查返回的内容
+func ping(t *testing.T, tun *Tunnel, echo string) {
+	conn, err := tun.DialContext(context.Background(), "tcp", echo)
+	if err != nil {
+		t.Fatalf("DialContext returned error: %v", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte("ping")); err != nil {
+		t.Fatalf("Write returned error: %v", err)
+	}
+	buf := make([]byte, 4)
+	if _, err := io.ReadFull(conn, buf); err != nil {
+		t.Fatalf("Read returned error: %v", err)
+	}
+	if string(buf) != "ping" {
+		t.Errorf("read %q through the tunnel, want ping", buf)
+	}
+}
+
+func TestTunnel(t *testing.T) {
+	clientKey, clientPEM := newSigner(t)
+	server := startServer(t, clientKey.PublicKey())
+	echo := startEcho(t)
+
+	tun := openTunnel(t, server, clientPEM)
+	ping(t, tun, echo)
+}
+
+func TestTunnelReconnects(t *testing.T) {
+	clientKey, clientPEM := newSigner(t)
+	server := startServer(t, clientKey.PublicKey())
+	echo := startEcho(t)
+
+	tun := openTunnel(t, server, clientPEM)
+	ping(t, tun, echo)
+
+	// SSH 连接断开后下一次拨号重新连接
+	server.drop()
+	ping(t, tun, echo)
+	if got := server.accepted(); got != 1 {
+		t.Errorf("server has %d ssh connections after the reconnect, want 1", got)
+	}
+
+	// 服务器拒绝转发时不重新连接
+	closed, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("Failed to listen: %v", err)
+	}
+	closed.Close()
+	if _, err := tun.DialContext(context.Background(), "tcp", closed.Addr().String()); err == nil {
+		t.Fatal("DialContext to a closed port returned no error")
+	}
+	if got := server.accepted(); got != 1 {
+		t.Errorf("server has %d ssh connections after a refused forward, want 1", got)
+	}
+
+	tun.Close()
+	if _, err := tun.DialContext(context.Background(), "tcp", echo); !errors.Is(err, net.ErrClosed) {
+		t.Errorf("DialContext after Close error = %v, want net.ErrClosed", err)
+	}
+}
+
+func TestTunnelRejectsUnknownHost(t *testing.T) {
+	clientKey, clientPEM := newSigner(t)
+	server := startServer(t, clientKey.PublicKey())
+	keyFile := writeFile(t, "id_ed25519", clientPEM)
+
+	// known_hosts 中没有该主机
+	emptyKnownHosts := writeFile(t, "known_hosts", nil)
+	_, err := Open(Config{Host: server.addr, User: "backup", KeyFile: keyFile, KnownHostsFile: emptyKnownHosts, Timeout: 5 * time.Second})
+	if err == nil || !strings.Contains(err.Error(), "not in the known_hosts file") {
+		t.Errorf("Open() error = %v, want unknown host error", err)
+	}
+
+	// known_hosts 中记录的 key 与服务器不符
+	otherKey, _ := newSigner(t)
+	wrongKnownHosts := writeFile(t, "known_hosts",
+		[]byte(knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, otherKey.PublicKey())+"\n"))
+	if _, err := Open(Config{Host: server.addr, User: "backup", KeyFile: keyFile, KnownHostsFile: wrongKnownHosts, Timeout: 5 * time.Second}); err == nil {
+		t.Error("expected error for a mismatched host key")
+	}
+}
+
+func TestTunnelRejectsUnauthorizedKey(t *testing.T) {
+	authorizedKey, _ := newSigner(t)
+	server := startServer(t, authorizedKey.PublicKey())
+	_, otherPEM := newSigner(t)
+
+	knownHosts := writeFile(t, "known_hosts",
+		[]byte(knownhosts.Line([]string{knownhosts.Normalize(server.addr)}, server.hostKey.PublicKey())+"\n"))
+	_, err := Open(Config{Host: server.addr, User: "backup", KeyFile: writeFile(t, "id_ed25519", otherPEM), KnownHostsFile: knownHosts, Timeout: 5 * time.Second})
+	if err == nil {
+		t.Error("expected authentication error")
+	}
+}
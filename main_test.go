@@ -2,9 +2,13 @@ package main
 
 import (
 	"flag"
+	"motors-backup/internal/config"
+	"motors-backup/internal/exporter"
 	"os"
+	"path/filepath"
 	"strings"
 	"testing"
+	"time"
 )
 
 func TestFlagParsing(t *testing.T) {
@@ -25,6 +29,7 @@ func TestFlagParsing(t *testing.T) {
 		expectedCreateDatabase  bool
 		expectedTableNames      []string
 		expectedWhereCondition  string
+		expectedOutputDir       string
 	}{
 		{
 			name:                    "basic table export",
@@ -89,6 +94,16 @@ func TestFlagParsing(t *testing.T) {
 			expectedTableNames:      []string{"users"},
 			expectedWhereCondition:  "id>100",
 		},
+		{
+			name:                    "output directory",
+			args:                    []string{"motors-backup", "--output-dir=/backups/shop", "users"},
+			expectedIgnoreTables:    []string{},
+			expectedIgnoreTableData: []string{},
+			expectedCreateDatabase:  true,
+			expectedTableNames:      []string{"users"},
+			expectedWhereCondition:  "",
+			expectedOutputDir:       "/backups/shop",
+		},
 	}
 
 	for _, tc := range testCases {
@@ -100,10 +115,11 @@ func TestFlagParsing(t *testing.T) {
 			os.Args = tc.args
 
 			// 调用parseFlags函数
-			tableNames, createDatabase, ignoreTables, ignoreTableDataList, whereCondition, err := parseFlags()
+			opts, err := parseFlags()
 			if err != nil {
 				t.Fatalf("parseFlags returned error: %v", err)
 			}
+			tableNames, createDatabase, ignoreTables, ignoreTableDataList, whereCondition := opts.tableNames, opts.createDatabase, opts.ignoreTables, opts.ignoreTableData, opts.whereCondition
 
 			// 验证create-database标志
 			if createDatabase != tc.expectedCreateDatabase {
@@ -147,10 +163,240 @@ func TestFlagParsing(t *testing.T) {
 			if !strings.EqualFold(whereCondition, tc.expectedWhereCondition) {
 				t.Errorf("whereCondition = %s, want %s", whereCondition, tc.expectedWhereCondition)
 			}
+
+			// 验证输出目录
+			if opts.outputDir != tc.expectedOutputDir {
+				t.Errorf("outputDir = %s, want %s", opts.outputDir, tc.expectedOutputDir)
+			}
 		})
 	}
 }
 
+func TestOutputToggleFlags(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	parse := func(args ...string) (*dumpOptions, error) {
+		flag.CommandLine = flag.NewFlagSet("motors-backup", flag.ExitOnError)
+		os.Args = append([]string{"motors-backup"}, args...)
+		return parseFlags()
+	}
+
+	opts, err := parse("--no-data", "--skip-add-drop-table", "--skip-add-locks", "--skip-comments", "--add-drop-database")
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if !opts.noData || opts.noCreateInfo || !opts.noDropTable || !opts.noLocks || !opts.noComments || !opts.dropDatabase {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+
+	opts, err = parse("--add-drop-table=false", "--no-create-info", "--compact")
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if !opts.noDropTable || !opts.noCreateInfo || !opts.compact {
+		t.Errorf("unexpected options: %+v", opts)
+	}
+
+	opts, err = parse("--skip-triggers", "--routines")
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if got := opts.dumperOptions(); !got.NoTriggers || !got.Routines {
+		t.Errorf("unexpected options: %+v", got)
+	}
+
+	for _, args := range [][]string{
+		{"--insert-mode=upsert", "--add-drop-table"},
+		{"--insert-mode=replace", "--add-drop-database"},
+		{"--create-database=false", "--add-drop-database"},
+	} {
+		if _, err := parse(args...); err == nil {
+			t.Errorf("parseFlags(%v) returned no error", args)
+		}
+	}
+	if _, err := parse("--insert-mode=ignore"); err != nil {
+		t.Errorf("--insert-mode=ignore returned error: %v", err)
+	}
+}
+
+func TestDDLFlags(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	path := filepath.Join(t.TempDir(), "motors-backup.yml")
+	err := os.WriteFile(path, []byte(`
+profiles:
+  production:
+    ddl:
+      strip_auto_increment: true
+      engine: InnoDB
+      charsets:
+        utf8mb3: utf8mb4
+      definer: keep
+`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	flag.CommandLine = flag.NewFlagSet("motors-backup", flag.ExitOnError)
+	os.Args = []string{"motors-backup", "--config=" + path, "--ddl-charset=latin1=utf8mb4",
+		"--ddl-collation=utf8mb4_0900_ai_ci=utf8mb4_unicode_ci", "--ddl-definer=strip"}
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if !opts.ddl.StripAutoIncrement || opts.ddl.Engine != "InnoDB" {
+		t.Errorf("profile ddl not applied: %+v", opts.ddl)
+	}
+	// 命令行参数优先于 profile
+	if opts.ddl.Definer != "strip" || len(opts.ddl.Charsets) != 1 || opts.ddl.Charsets["latin1"] != "utf8mb4" ||
+		opts.ddl.Collations["utf8mb4_0900_ai_ci"] != "utf8mb4_unicode_ci" {
+		t.Errorf("flags should take precedence: %+v", opts.ddl)
+	}
+
+	for _, args := range [][]string{
+		{"--ddl-definer=root"},
+		{"--ddl-engine=Inno DB"},
+	} {
+		flag.CommandLine = flag.NewFlagSet("motors-backup", flag.ExitOnError)
+		os.Args = append([]string{"motors-backup"}, args...)
+		if _, err := parseFlags(); err == nil {
+			t.Errorf("parseFlags(%v) returned no error", args)
+		}
+	}
+
+	var m mapping
+	if err := m.Set("utf8mb3"); err == nil {
+		t.Error("mapping.Set without = returned no error")
+	}
+	if err := m.Set(" utf8mb3 = utf8mb4 "); err != nil || m.String() != "utf8mb3=utf8mb4" {
+		t.Errorf("mapping = %s, %v", m.String(), err)
+	}
+}
+
+func TestFormatFlags(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	parse := func(args ...string) (*dumpOptions, error) {
+		flag.CommandLine = flag.NewFlagSet("motors-backup", flag.ExitOnError)
+		os.Args = append([]string{"motors-backup"}, args...)
+		return parseFlags()
+	}
+
+	opts, err := parse("--format=csv", "--output-dir=/tmp/out", "--csv-delimiter=;", `--csv-null=\N`,
+		"--csv-line-ending=crlf", "--csv-header=false")
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	want := exporter.DelimitedOptions{Delimiter: ';', Null: `\N`, CRLF: true, NoHeader: true}
+	if !opts.tableFiles() || opts.delimited != want {
+		t.Errorf("unexpected options: format %s, %+v", opts.format, opts.delimited)
+	}
+
+	opts, err = parse("--format=tsv", "--dry-run", `--csv-delimiter=\t`)
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if opts.delimited.Delimiter != '\t' || opts.delimited.CRLF || opts.delimited.NoHeader {
+		t.Errorf("unexpected options: %+v", opts.delimited)
+	}
+
+	for _, args := range [][]string{
+		{"--format=xml"},
+		{"--format=csv"},
+		{"--format=csv", "--output-dir=/tmp/out", "--no-data"},
+		{"--format=csv", "--output-dir=/tmp/out", "--insert-mode=upsert"},
+		{"--format=csv", "--output-dir=/tmp/out", "--routines"},
+		{"--format=csv", "--output-dir=/tmp/out", "--csv-delimiter=ab"},
+		{"--format=csv", "--output-dir=/tmp/out", "--csv-delimiter=\""},
+		{"--format=csv", "--output-dir=/tmp/out", "--csv-null=a,b"},
+		{"--format=csv", "--output-dir=/tmp/out", "--csv-line-ending=cr"},
+		// 表名以逗号分隔，多余的参数不会被静默忽略
+		{"--format=csv", "--output-dir=/tmp/out", "users", "orders"},
+	} {
+		if _, err := parse(args...); err == nil {
+			t.Errorf("parseFlags(%v) returned no error", args)
+		}
+	}
+
+	opts, err = parse()
+	if err != nil || opts.tableFiles() {
+		t.Errorf("default format should be sql: %v %v", opts, err)
+	}
+}
+
+func TestJSONLFlags(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	parse := func(args ...string) (*dumpOptions, error) {
+		flag.CommandLine = flag.NewFlagSet("motors-backup", flag.ExitOnError)
+		os.Args = append([]string{"motors-backup"}, args...)
+		return parseFlags()
+	}
+
+	// 合并的流可以写到标准输出
+	opts, err := parse("--format=ndjson", "--jsonl-combined", "--jsonl-bool")
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if opts.format != "jsonl" || opts.tableFiles() || !opts.json.TinyIntAsBool {
+		t.Errorf("unexpected options: format %s, tableFiles %v, %+v", opts.format, opts.tableFiles(), opts.json)
+	}
+	if got := opts.dumperOptions(); got.Format != "jsonl" || !got.JSON.TinyIntAsBool {
+		t.Errorf("unexpected dumper options: %+v", got)
+	}
+
+	opts, err = parse("--format=jsonl", "--output-dir=/tmp/out")
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if !opts.tableFiles() {
+		t.Error("--format=jsonl without --jsonl-combined should write a file per table")
+	}
+
+	for _, args := range [][]string{
+		{"--format=jsonl"},
+		{"--format=csv", "--output-dir=/tmp/out", "--jsonl-combined"},
+		{"--format=jsonl", "--jsonl-combined", "--no-data"},
+	} {
+		if _, err := parse(args...); err == nil {
+			t.Errorf("parseFlags(%v) returned no error", args)
+		}
+	}
+}
+
+func TestTablesFile(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+
+	path := filepath.Join(t.TempDir(), "tables.txt")
+	if err := os.WriteFile(path, []byte("# 日志表\nlog_2024_*\nre:^audit_\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	flag.CommandLine = flag.NewFlagSet("motors-backup", flag.ExitOnError)
+	os.Args = []string{"motors-backup", "--tables-file=" + path, "users"}
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if want := "users,log_2024_*,re:^audit_"; strings.Join(opts.tableNames, ",") != want {
+		t.Errorf("tableNames = %v, want %s", opts.tableNames, want)
+	}
+}
+
 func TestIgnoreListContains(t *testing.T) {
 	il := ignoreList{"users", "logs"}
 
@@ -165,4 +411,182 @@ func TestIgnoreListContains(t *testing.T) {
 	if il.Contains("orders") {
 		t.Error("Expected ignoreList not to contain 'orders'")
 	}
+
+	patterns := ignoreList{"log_2024_*", "re:^tmp_"}
+	if !patterns.Contains("log_2024_01") || !patterns.Contains("tmp_import") || patterns.Contains("log_2023_12") {
+		t.Error("Expected ignoreList to match globs and regexps")
+	}
+	if err := patterns.Set("re:("); err == nil {
+		t.Error("Expected error for an invalid regexp")
+	}
+}
+
+func TestResolveTables(t *testing.T) {
+	allTables := []string{"users", "orders", "logs", "sessions"}
+
+	testCases := []struct {
+		name     string
+		opts     *dumpOptions
+		expected []string
+		wantErr  bool
+	}{
+		{
+			name:     "all tables",
+			opts:     &dumpOptions{},
+			expected: []string{"users", "orders", "logs", "sessions"},
+		},
+		{
+			name:     "selected tables keep their order",
+			opts:     &dumpOptions{tableNames: []string{"orders", "users"}},
+			expected: []string{"orders", "users"},
+		},
+		{
+			name:     "unknown tables are dropped",
+			opts:     &dumpOptions{tableNames: []string{"users", "missing"}},
+			expected: []string{"users"},
+		},
+		{
+			name:     "ignored tables are excluded",
+			opts:     &dumpOptions{ignoreTables: ignoreList{"logs", "sessions"}},
+			expected: []string{"users", "orders"},
+		},
+		{
+			name:     "globs and regexps",
+			opts:     &dumpOptions{tableNames: []string{"s*", "re:^(users|orders)$", "users"}, ignoreTables: ignoreList{"re:^o"}},
+			expected: []string{"sessions", "users"},
+		},
+		{
+			name:    "no matching tables",
+			opts:    &dumpOptions{tableNames: []string{"missing"}},
+			wantErr: true,
+		},
+		{
+			name:    "invalid regexp",
+			opts:    &dumpOptions{tableNames: []string{"re:("}},
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			tables, err := resolveTables(allTables, "shop", tc.opts)
+			if tc.wantErr {
+				if err == nil {
+					t.Error("Expected error, got nil")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("resolveTables returned error: %v", err)
+			}
+			if strings.Join(tables, ",") != strings.Join(tc.expected, ",") {
+				t.Errorf("tables = %v, want %v", tables, tc.expected)
+			}
+		})
+	}
+}
+
+func TestProfilePrecedence(t *testing.T) {
+	oldArgs := os.Args
+	defer func() {
+		os.Args = oldArgs
+	}()
+	t.Setenv("MOTORS_BACKUP_SIGN_KEY", "")
+	t.Setenv("MOTORS_BACKUP_METRICS_TEXTFILE", "")
+
+	path := filepath.Join(t.TempDir(), "motors-backup.yml")
+	err := os.WriteFile(path, []byte(`
+default: production
+profiles:
+  production:
+    tables: [users, orders]
+    ignore_table_data: [logs]
+    create_database: false
+    where: "id > 0"
+    table_where:
+      orders: "created_at > '2024-01-01'"
+    masking:
+      users:
+        email: "'hidden'"
+    output:
+      dir: /backups/shop
+      progress: none
+    retry:
+      retries: 0
+      delay: 5s
+`), 0o644)
+	if err != nil {
+		t.Fatalf("Failed to write config file: %v", err)
+	}
+
+	flag.CommandLine = flag.NewFlagSet("motors-backup", flag.ExitOnError)
+	os.Args = []string{"motors-backup", "--config=" + path, "--create-database=true", "--output-dir=/tmp/out", "--retry-delay=2s"}
+	opts, err := parseFlags()
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+
+	// profile 中的值
+	if strings.Join(opts.tableNames, ",") != "users,orders" || !opts.ignoreTableData.Contains("logs") {
+		t.Errorf("profile tables not applied: %v %v", opts.tableNames, opts.ignoreTableData)
+	}
+	if opts.whereFor("users") != "id > 0" || opts.whereFor("orders") != "created_at > '2024-01-01'" {
+		t.Errorf("unexpected where conditions: %q %q", opts.whereFor("users"), opts.whereFor("orders"))
+	}
+	if opts.masking["users"]["email"] != "'hidden'" || opts.progress != "none" {
+		t.Errorf("unexpected masking or progress: %v %s", opts.masking, opts.progress)
+	}
+
+	if opts.retry.Retries != 0 || opts.retry.MaxDelay != 30*time.Second {
+		t.Errorf("unexpected retry policy: %+v", opts.retry)
+	}
+
+	// 命令行参数优先于 profile
+	if !opts.createDatabase || opts.outputDir != "/tmp/out" || opts.retry.Delay != 2*time.Second {
+		t.Errorf("flags should take precedence: createDatabase=%v outputDir=%s retry=%+v", opts.createDatabase, opts.outputDir, opts.retry)
+	}
+
+	// --where 取代 profile 中的全部条件
+	flag.CommandLine = flag.NewFlagSet("motors-backup", flag.ExitOnError)
+	os.Args = []string{"motors-backup", "--config=" + path, "--where=id<10", "users"}
+	opts, err = parseFlags()
+	if err != nil {
+		t.Fatalf("parseFlags returned error: %v", err)
+	}
+	if opts.whereFor("orders") != "id<10" || strings.Join(opts.tableNames, ",") != "users" {
+		t.Errorf("--where and table argument should replace the profile: %q %v", opts.whereFor("orders"), opts.tableNames)
+	}
+
+	// 环境变量优先于 profile
+	t.Setenv("MOTORS_BACKUP_METRICS_TEXTFILE", "/tmp/env.prom")
+	explicit := explicitFlags(flag.NewFlagSet("motors-backup", flag.ExitOnError))
+	opts = &dumpOptions{metricsTextfile: "/tmp/env.prom"}
+	applyProfile(opts, &config.Profile{Output: config.Output{MetricsTextfile: "/tmp/profile.prom"}}, explicit)
+	if opts.metricsTextfile != "/tmp/env.prom" {
+		t.Errorf("metricsTextfile = %s, want the environment value", opts.metricsTextfile)
+	}
+}
+
+func TestNeedsPasswordPrompt(t *testing.T) {
+	tests := []struct {
+		name     string
+		password string
+		force    bool
+		terminal bool
+		want     bool
+	}{
+		{name: "terminal without password", terminal: true, want: true},
+		{name: "terminal with password", password: "secret", terminal: true},
+		{name: "no terminal without password"},
+		{name: "forced with password", password: "secret", force: true, terminal: true, want: true},
+		{name: "forced without terminal", force: true, want: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfg := &config.Config{DBPassword: tt.password}
+			if got := needsPasswordPrompt(cfg, tt.force, tt.terminal); got != tt.want {
+				t.Errorf("needsPasswordPrompt() = %v, want %v", got, tt.want)
+			}
+		})
+	}
 }
@@ -0,0 +1,812 @@
+// Package motorsbackup dumps a MySQL database as SQL or JSON Lines to any io.Writer or its tables as
+// CSV, TSV and JSON Lines files, it is the library behind the motors-backup command
+package motorsbackup
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"log/slog"
+	"motors-backup/internal"
+	"motors-backup/internal/config"
+	dbConn "motors-backup/internal/db"
+	"motors-backup/internal/ddl"
+	"motors-backup/internal/exporter"
+	"motors-backup/internal/log"
+	"motors-backup/internal/manifest"
+	"motors-backup/internal/metrics"
+	"motors-backup/internal/output"
+	"motors-backup/internal/retry"
+	"motors-backup/internal/schema"
+	"motors-backup/internal/selector"
+	"slices"
+	"strings"
+	"time"
+)
+
+// Config describes the database connection, see ConfigFromEnv
+type Config = config.Config
+
+// RetryPolicy describes how transient errors such as lost connections and deadlocks are retried,
+// the zero value disables retrying
+type RetryPolicy = retry.Policy
+
+// Failure describes an object skipped because of Options.ContinueOnError
+type Failure = manifest.Failure
+
+// BinlogPosition is the binary log position and GTID set recorded with Options.SourceData
+type BinlogPosition = manifest.BinlogPosition
+
+// Source data modes, see Options.SourceData
+const (
+	SourceDataStatement = internal.SourceDataStatement
+	SourceDataComment   = internal.SourceDataComment
+)
+
+// Insert modes, see Options.InsertMode
+const (
+	InsertModeInsert  = exporter.InsertModeInsert
+	InsertModeReplace = exporter.InsertModeReplace
+	InsertModeIgnore  = exporter.InsertModeIgnore
+	InsertModeUpsert  = exporter.InsertModeUpsert
+)
+
+// Output formats, see Options.Format
+const (
+	FormatSQL   = exporter.FormatSQL
+	FormatCSV   = exporter.FormatCSV
+	FormatTSV   = exporter.FormatTSV
+	FormatJSONL = exporter.FormatJSONL
+)
+
+// DelimitedOptions describes the CSV and TSV output, see Options.Delimited
+type DelimitedOptions = exporter.DelimitedOptions
+
+// JSONOptions describes the JSON Lines output, see Options.JSON
+type JSONOptions = exporter.JSONOptions
+
+// TableField is the key of the table name in the JSON objects Dump writes with FormatJSONL
+const TableField = exporter.TableField
+
+// DDLTransformer describes how CREATE statements are rewritten, see Options.DDL
+type DDLTransformer = ddl.Transformer
+
+// Definer policies, see DDLTransformer.Definer
+const (
+	DefinerCurrentUser = ddl.DefinerCurrentUser
+	DefinerKeep        = ddl.DefinerKeep
+	DefinerStrip       = ddl.DefinerStrip
+)
+
+// Failure kinds
+const (
+	ObjectTableStructure = manifest.ObjectTableStructure
+	ObjectTableData      = manifest.ObjectTableData
+	ObjectView           = manifest.ObjectView
+	ObjectTrigger        = manifest.ObjectTrigger
+	ObjectRoutine        = manifest.ObjectRoutine
+)
+
+// ConfigFromEnv reads the connection settings from the DB_* environment variables and the .env file
+func ConfigFromEnv() (*Config, error) {
+	return config.Load(nil, nil)
+}
+
+// DefaultRetryPolicy retries three times with an exponential backoff starting at one second
+func DefaultRetryPolicy() RetryPolicy {
+	return retry.DefaultPolicy()
+}
+
+// SetLogger replaces the logger used for retries, failures and warnings, the default logs text to stderr
+func SetLogger(logger *slog.Logger) {
+	log.Logger = logger
+}
+
+// Options 描述导出哪些表以及如何导出，零值导出所有表
+type Options struct {
+	// Tables 为要导出的表，按给定顺序导出，为空时导出所有表。表名可以是 glob 模式（log_2024_*）
+	// 或以 re: 开头的正则表达式，IgnoreTables 和 IgnoreTableData 也一样
+	Tables []string
+	// IgnoreTables are skipped completely, IgnoreTableData only export their structure
+	IgnoreTables    []string
+	IgnoreTableData []string
+	// NoCreateDatabase omits the CREATE DATABASE statement
+	NoCreateDatabase bool
+
+	// Where 为所有表的 WHERE 条件，TableWhere 为单个表指定条件并优先于 Where
+	Where      string
+	TableWhere map[string]string
+	// Masking maps a table to its masked columns and the SQL expression selected instead of each column
+	Masking map[string]map[string]string
+	// Format is FormatSQL (the default) or FormatJSONL for Dump, FormatCSV, FormatTSV or FormatJSONL
+	// for DumpTables
+	Format string
+	// Delimited describes the CSV and TSV output, the zero value writes a header row and empty
+	// fields for NULL
+	Delimited DelimitedOptions
+	// JSON describes the JSON Lines output
+	JSON JSONOptions
+	// InsertMode writes the rows as INSERT (the default), REPLACE, INSERT IGNORE or upsert statements.
+	// Other modes than InsertModeInsert keep existing tables: CREATE TABLE IF NOT EXISTS without DROP TABLE
+	InsertMode string
+
+	// NoData only writes the structure of the tables, NoCreateInfo only their data without CREATE TABLE,
+	// triggers, views and routines
+	NoData       bool
+	NoCreateInfo bool
+	// NoTriggers omits the triggers written after the data of each table, Routines writes the stored
+	// procedures and functions after the views
+	NoTriggers bool
+	Routines   bool
+	// NoDropTable omits DROP TABLE IF EXISTS before CREATE TABLE, DropDatabase writes
+	// DROP DATABASE IF EXISTS before CREATE DATABASE
+	NoDropTable  bool
+	DropDatabase bool
+	// NoLocks omits LOCK TABLES around the data of each table
+	NoLocks bool
+	// NoComments omits the comments, the trailer marking the dump as complete or incomplete is kept
+	NoComments bool
+	// Compact writes the smallest dump like mysqldump --compact: no comments, DROP TABLE, locks,
+	// ALTER TABLE ... DISABLE KEYS or session variables in the header and footer
+	Compact bool
+	// DDL rewrites the CREATE statements so that the dump loads into other servers, the zero value
+	// only replaces the definers of views, triggers and routines with CURRENT_USER()
+	DDL DDLTransformer
+
+	Retry RetryPolicy
+	// SourceData reads everything in a consistent snapshot and writes its binary log position to the
+	// dump header, as statements with SourceDataStatement or as comments with SourceDataComment.
+	// Retries are disabled because the snapshot is lost with its connection
+	SourceData int
+	// ContinueOnError skips failed table structures, table data, triggers, views and routines, they are listed in Result.Failures
+	ContinueOnError bool
+	// Progress receives progress events, may be nil
+	Progress Progress
+}
+
+// Progress receives the progress of Dump, the methods are called from the goroutine running Dump
+type Progress interface {
+	// Start is called once the tables are known with the estimated number of rows to export
+	Start(estimatedRows int64)
+	StartTable(table string, estimatedRows int64)
+	AddRow()
+	FinishTable()
+	// Stop is called when the export ends, whether it succeeded or not
+	Stop()
+}
+
+// Result describes what Dump wrote
+type Result struct {
+	Host          string
+	Database      string
+	ServerVersion string
+	// ServerFlavor is mysql, mariadb or percona
+	ServerFlavor string
+	StartedAt    time.Time
+	// CompletedAt is zero when the dump failed
+	CompletedAt time.Time
+	// Bytes is the size of the output written
+	Bytes    int64
+	Tables   []TableResult
+	Failures []Failure
+	// Binlog is the position of the snapshot, only set with Options.SourceData
+	Binlog *BinlogPosition
+}
+
+// Duration returns how long the dump took
+func (r *Result) Duration() time.Duration {
+	if r.CompletedAt.IsZero() {
+		return 0
+	}
+	return r.CompletedAt.Sub(r.StartedAt)
+}
+
+// TableResult describes an exported table
+type TableResult struct {
+	Name       string
+	Rows       int64
+	Bytes      int64
+	Duration   time.Duration
+	SchemaOnly bool
+	// Retries counts the retries after transient errors
+	Retries int
+}
+
+// Dumper dumps one database
+type Dumper struct {
+	cfg  *Config
+	db   *sql.DB
+	opts Options
+}
+
+// New returns a Dumper that opens its own connection with cfg for every Dump
+func New(cfg *Config, opts Options) *Dumper {
+	return &Dumper{cfg: cfg, opts: opts}
+}
+
+// NewWithDB returns a Dumper that uses an existing connection pool, database must be the default
+// database of the pool. The pool is not closed by Dump
+func NewWithDB(db *sql.DB, database string, opts Options) *Dumper {
+	return &Dumper{cfg: &config.Config{DBName: database}, db: db, opts: opts}
+}
+
+// Dump writes the SQL dump to w. The result is never nil, when an error is returned it describes
+// what was written before the error and the output ends with a "-- DUMP INCOMPLETE" comment.
+// With FormatJSONL the rows of all tables are written to w, every object names its table in the
+// TableField key, and nothing marks a failed dump but the error
+func (d *Dumper) Dump(ctx context.Context, w io.Writer) (*Result, error) {
+	counter := output.NewCountingWriter(w)
+	result := &Result{
+		Host:      d.cfg.DBHost,
+		Database:  d.cfg.DBName,
+		StartedAt: time.Now(),
+	}
+
+	var err error
+	switch {
+	case d.opts.Format == FormatJSONL:
+		err = d.run(ctx, result, func(d *Dumper, database dbConn.Querier, _ *internal.MySQLInfo) error {
+			return d.dumpTables(ctx, func(string) (io.WriteCloser, error) {
+				return nopCloser{counter}, nil
+			}, true, database, result)
+		})
+	case !d.opts.sqlFormat():
+		err = fmt.Errorf("format %s writes a file per table, use DumpTables", d.opts.Format)
+	default:
+		err = d.run(ctx, result, func(d *Dumper, database dbConn.Querier, info *internal.MySQLInfo) error {
+			return d.dump(ctx, counter, database, info, result)
+		})
+	}
+	logRetriedTables(d.cfg.DBName, result.Tables)
+
+	if err != nil {
+		// 标记不完整的导出，被中断时记录中断的原因；JSON Lines 中无法写入注释
+		if d.opts.sqlFormat() {
+			reason := err
+			if ctx.Err() != nil {
+				reason = context.Cause(ctx)
+			}
+			internal.PrintDumpIncomplete(counter, reason)
+		}
+		result.Bytes = counter.Count()
+		return result, err
+	}
+
+	result.Bytes = counter.Count()
+	result.CompletedAt = time.Now()
+	return result, nil
+}
+
+// DumpTables writes the rows of every selected table as CSV, TSV or JSON Lines, see Options.Format, to the
+// writer create returns for the table. The writer is closed after the table, tables in
+// IgnoreTableData get no writer. The result is never nil, when an error is returned it describes
+// the tables written before the error. The writers of tables listed in Result.Failures hold
+// incomplete data
+func (d *Dumper) DumpTables(ctx context.Context, create func(table string) (io.WriteCloser, error)) (*Result, error) {
+	result := &Result{
+		Host:      d.cfg.DBHost,
+		Database:  d.cfg.DBName,
+		StartedAt: time.Now(),
+	}
+
+	var err error
+	if d.opts.sqlFormat() {
+		err = fmt.Errorf("DumpTables requires a format writing a file per table such as %s, use Dump for SQL", FormatCSV)
+	} else {
+		err = d.run(ctx, result, func(d *Dumper, database dbConn.Querier, info *internal.MySQLInfo) error {
+			return d.dumpTables(ctx, create, false, database, result)
+		})
+	}
+	logRetriedTables(d.cfg.DBName, result.Tables)
+	for _, table := range result.Tables {
+		result.Bytes += table.Bytes
+	}
+	if err != nil {
+		return result, err
+	}
+	result.CompletedAt = time.Now()
+	return result, nil
+}
+
+// run 检查选项并连接数据库，启用 SourceData 时 fn 在一致性快照中读取
+func (d *Dumper) run(ctx context.Context, result *Result, fn func(d *Dumper, database dbConn.Querier, info *internal.MySQLInfo) error) error {
+	worker := func(database *sql.DB, info *internal.MySQLInfo) error {
+		result.ServerVersion = info.Version
+		result.ServerFlavor = string(info.Server.Flavor)
+		if d.opts.SourceData == internal.SourceDataOff {
+			return fn(d, database, info)
+		}
+
+		snapshot, err := internal.StartSnapshot(ctx, database, info.Server)
+		if err != nil {
+			return internal.NewStageError(metrics.StageSnapshot, d.cfg.DBName, "", err)
+		}
+		defer snapshot.Close()
+		result.Binlog = &snapshot.Position
+		log.Logger.Info("consistent snapshot started", log.KeyDatabase, d.cfg.DBName,
+			"binlog_file", snapshot.Position.File, "binlog_position", snapshot.Position.Position, "gtid_set", snapshot.Position.GTIDSet)
+
+		// 快照随连接断开而丢失，重连后无法继续在同一快照中读取
+		inSnapshot := *d
+		inSnapshot.opts.Retry = RetryPolicy{}
+		return fn(&inSnapshot, snapshot.Conn, info)
+	}
+
+	if err := d.opts.validate(); err != nil {
+		return err
+	}
+	if d.db != nil {
+		return internal.RunExport(ctx, d.cfg, d.db, worker)
+	}
+	return internal.StartExport(ctx, d.cfg, d.opts.Retry, worker)
+}
+
+// validate 检查不依赖服务器的选项
+func (o *Options) validate() error {
+	switch {
+	case o.SourceData < internal.SourceDataOff || o.SourceData > internal.SourceDataComment:
+		return fmt.Errorf("invalid source data mode %d, must be 1 or 2", o.SourceData)
+	case o.Format != "" && !slices.Contains(exporter.Formats, o.Format):
+		return fmt.Errorf("invalid format %q, must be one of %s", o.Format, strings.Join(exporter.Formats, ", "))
+	case o.InsertMode != "" && !slices.Contains(exporter.InsertModes, o.InsertMode):
+		return fmt.Errorf("invalid insert mode %q, must be one of %s", o.InsertMode, strings.Join(exporter.InsertModes, ", "))
+	case o.DropDatabase && o.NoCreateDatabase:
+		return fmt.Errorf("DropDatabase requires the CREATE DATABASE statement")
+	case o.DropDatabase && o.mergesIntoExisting():
+		return fmt.Errorf("DropDatabase can not be combined with insert mode %s", o.InsertMode)
+	case !o.sqlFormat() && o.NoData:
+		return fmt.Errorf("NoData exports nothing with format %s", o.Format)
+	case !o.sqlFormat() && o.Routines:
+		return fmt.Errorf("Routines are only written with format %s", FormatSQL)
+	case o.Format == FormatCSV || o.Format == FormatTSV:
+		if err := o.Delimited.Validate(o.Format); err != nil {
+			return err
+		}
+	}
+	return o.DDL.Validate()
+}
+
+// dump 依次导出数据库、表结构、表数据、触发器、视图和存储过程
+func (d *Dumper) dump(ctx context.Context, w *output.CountingWriter, database dbConn.Querier, info *internal.MySQLInfo, result *Result) error {
+	cfg, opts := d.cfg, d.opts
+	format := opts.format()
+	internal.PrintEnvironmentSettings(w, cfg, info, format)
+	if result.Binlog != nil {
+		internal.PrintSourceData(w, info.Server, *result.Binlog, opts.SourceData, format)
+	}
+
+	// 如果启用了create-database参数，则执行创建数据库操作
+	err := d.retryStage(ctx, metrics.StageDatabase, "", nil, func() error {
+		return internal.DumpCreateDatabase(ctx, w, cfg, database, info.Server, !opts.NoCreateDatabase, format)
+	})
+	if err != nil {
+		return internal.NewStageError(metrics.StageDatabase, cfg.DBName, "", fmt.Errorf("error creating database: %w", err))
+	}
+
+	var allTables []string
+	err = d.retryStage(ctx, metrics.StageListTables, "", nil, func() (err error) {
+		allTables, err = schema.ListAllTables(ctx, database)
+		return err
+	})
+	if err != nil {
+		return internal.NewStageError(metrics.StageListTables, cfg.DBName, "", fmt.Errorf("error listing all tables: %w", err))
+	}
+
+	tableNames, err := ResolveTables(allTables, cfg.DBName, opts)
+	if err != nil {
+		return err
+	}
+
+	progress, estimates, err := d.startProgress(ctx, database, tableNames)
+	if err != nil {
+		return err
+	}
+	defer progress.Stop()
+
+	// fail 在 ContinueOnError 时记录失败的对象并在输出中标记，否则返回错误中止导出
+	fail := func(kind, name string, err error) error {
+		if !opts.ContinueOnError || ctx.Err() != nil {
+			return err
+		}
+		result.Failures = append(result.Failures, Failure{Kind: kind, Name: name, Error: err.Error()})
+		internal.PrintObjectFailed(w, kind, name, err)
+		log.Logger.Error("object failed, continuing", append(log.Err(err), "kind", kind, "name", name)...)
+		return nil
+	}
+
+	// 执行导出操作
+	for _, tableName := range tableNames {
+		table := TableResult{Name: tableName, SchemaOnly: opts.schemaOnly(tableName)}
+		start, startBytes := time.Now(), w.Count()
+
+		onRetry := func(error) {
+			table.Retries++
+		}
+
+		// 如果不在忽略结构列表中，则导出表结构
+		var err error
+		if !opts.NoCreateInfo {
+			err = d.retryStage(ctx, metrics.StageTableStructure, tableName, onRetry, func() error {
+				return internal.DumpTableStructure(ctx, w, cfg, database, info.Server, tableName, format)
+			})
+		}
+		if err != nil {
+			err = internal.NewStageError(metrics.StageTableStructure, cfg.DBName, tableName, fmt.Errorf("error dumping table structure %s: %w", tableName, err))
+			if err := fail(ObjectTableStructure, tableName, err); err != nil {
+				return err
+			}
+			// 表结构导出失败时不再导出数据
+			continue
+		}
+
+		// 如果不在忽略数据列表中，则导出表数据
+		dataFailed := false
+		if !table.SchemaOnly {
+			progress.StartTable(tableName, estimates[tableName].Rows)
+			table.Rows, err = internal.DumpTable(ctx, w, cfg, database, tableName, d.exportOptions(tableName, progress, onRetry))
+			if err != nil {
+				if err := fail(ObjectTableData, tableName, fmt.Errorf("error dumping table %s: %w", tableName, err)); err != nil {
+					return err
+				}
+				dataFailed = true
+			}
+			progress.FinishTable()
+		}
+
+		// 触发器在数据之后创建，导入数据时不会触发
+		if !opts.NoCreateInfo && !opts.NoTriggers {
+			var triggers []string
+			err = d.retryStage(ctx, metrics.StageTriggers, tableName, nil, func() (err error) {
+				triggers, err = schema.ListTriggers(ctx, database, cfg.DBName, tableName)
+				return err
+			})
+			if err != nil {
+				err = internal.NewStageError(metrics.StageTriggers, cfg.DBName, tableName, fmt.Errorf("error listing triggers of table %s: %w", tableName, err))
+				if err := fail(ObjectTrigger, tableName, err); err != nil {
+					return err
+				}
+			}
+			for _, trigger := range triggers {
+				err := d.retryStage(ctx, metrics.StageTriggers, trigger, nil, func() error {
+					return internal.DumpTrigger(ctx, w, cfg, database, trigger, format)
+				})
+				if err != nil {
+					err = internal.NewStageError(metrics.StageTriggers, cfg.DBName, trigger, fmt.Errorf("error dumping trigger %s: %w", trigger, err))
+					if err := fail(ObjectTrigger, trigger, err); err != nil {
+						return err
+					}
+				}
+			}
+		}
+		if dataFailed {
+			continue
+		}
+
+		table.Bytes = w.Count() - startBytes
+		table.Duration = time.Since(start)
+		result.Tables = append(result.Tables, table)
+	}
+
+	if opts.NoCreateInfo {
+		internal.PrintRestoreConnectionSettings(w, format)
+		internal.PrintDumpCompleted(w)
+		return nil
+	}
+
+	var views []string
+	err = d.retryStage(ctx, metrics.StageViews, "", nil, func() (err error) {
+		views, err = schema.ListViews(ctx, database)
+		return err
+	})
+	if err != nil {
+		return internal.NewStageError(metrics.StageViews, cfg.DBName, "", fmt.Errorf("error dumping views: %w", err))
+	}
+	for _, view := range views {
+		err := d.retryStage(ctx, metrics.StageViews, view, nil, func() error {
+			return internal.DumpView(ctx, w, cfg, database, info.Server, view, format)
+		})
+		if err != nil {
+			err = internal.NewStageError(metrics.StageViews, cfg.DBName, view, fmt.Errorf("error dumping view %s: %w", view, err))
+			if err := fail(ObjectView, view, err); err != nil {
+				return err
+			}
+		}
+	}
+
+	if opts.Routines {
+		var routines []schema.Routine
+		err = d.retryStage(ctx, metrics.StageRoutines, "", nil, func() (err error) {
+			routines, err = schema.ListRoutines(ctx, database, cfg.DBName)
+			return err
+		})
+		if err != nil {
+			return internal.NewStageError(metrics.StageRoutines, cfg.DBName, "", fmt.Errorf("error dumping routines: %w", err))
+		}
+		for _, routine := range routines {
+			err := d.retryStage(ctx, metrics.StageRoutines, routine.Name, nil, func() error {
+				return internal.DumpRoutine(ctx, w, cfg, database, routine, format)
+			})
+			if err != nil {
+				err = internal.NewStageError(metrics.StageRoutines, cfg.DBName, routine.Name, fmt.Errorf("error dumping %s %s: %w", strings.ToLower(routine.Type), routine.Name, err))
+				if err := fail(ObjectRoutine, routine.Name, err); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	internal.PrintRestoreConnectionSettings(w, format)
+	internal.PrintDumpCompleted(w)
+
+	return nil
+}
+
+// dumpTables 将每个表的数据写入 create 返回的文件，只导出结构的表没有文件。withTable 时所有表
+// 写入同一个流，每个 JSON 对象记录所属的表
+func (d *Dumper) dumpTables(ctx context.Context, create func(table string) (io.WriteCloser, error), withTable bool, database dbConn.Querier, result *Result) error {
+	cfg, opts := d.cfg, d.opts
+
+	var allTables []string
+	err := d.retryStage(ctx, metrics.StageListTables, "", nil, func() (err error) {
+		allTables, err = schema.ListAllTables(ctx, database)
+		return err
+	})
+	if err != nil {
+		return internal.NewStageError(metrics.StageListTables, cfg.DBName, "", fmt.Errorf("error listing all tables: %w", err))
+	}
+	tableNames, err := ResolveTables(allTables, cfg.DBName, opts)
+	if err != nil {
+		return err
+	}
+
+	progress, estimates, err := d.startProgress(ctx, database, tableNames)
+	if err != nil {
+		return err
+	}
+	defer progress.Stop()
+
+	for _, tableName := range tableNames {
+		if opts.schemaOnly(tableName) {
+			continue
+		}
+		table := TableResult{Name: tableName}
+		start := time.Now()
+		onRetry := func(error) {
+			table.Retries++
+		}
+
+		file, err := create(tableName)
+		if err != nil {
+			return fmt.Errorf("failed to create the file of table %s: %w", tableName, err)
+		}
+		w := output.NewCountingWriter(file)
+		progress.StartTable(tableName, estimates[tableName].Rows)
+		exportOpts := d.exportOptions(tableName, progress, onRetry)
+		exportOpts.WithTable = withTable
+		table.Rows, err = internal.DumpTable(ctx, w, cfg, database, tableName, exportOpts)
+		if closeErr := file.Close(); err == nil && closeErr != nil {
+			err = fmt.Errorf("failed to close the file of table %s: %w", tableName, closeErr)
+		}
+		if err != nil {
+			err = fmt.Errorf("error dumping table %s: %w", tableName, err)
+			if !opts.ContinueOnError || ctx.Err() != nil {
+				return err
+			}
+			result.Failures = append(result.Failures, Failure{Kind: ObjectTableData, Name: tableName, Error: err.Error()})
+			log.Logger.Error("object failed, continuing", append(log.Err(err), "kind", ObjectTableData, "name", tableName)...)
+			progress.FinishTable()
+			continue
+		}
+		progress.FinishTable()
+
+		table.Bytes = w.Count()
+		table.Duration = time.Since(start)
+		result.Tables = append(result.Tables, table)
+	}
+	return nil
+}
+
+// startProgress 根据 information_schema 的估算行数初始化进度报告，返回各表的估算，调用方负责 Stop。
+// 未设置 Options.Progress 时不查询估算，返回忽略所有事件的 Progress
+func (d *Dumper) startProgress(ctx context.Context, database dbConn.Querier, tableNames []string) (Progress, map[string]schema.TableStat, error) {
+	opts := d.opts
+	estimates := make(map[string]schema.TableStat)
+	if opts.Progress != nil {
+		err := d.retryStage(ctx, metrics.StageListTables, "", nil, func() (err error) {
+			estimates, err = schema.GetTableStats(ctx, database, d.cfg.DBName)
+			return err
+		})
+		if err != nil {
+			return nil, nil, fmt.Errorf("error getting table stats: %w", err)
+		}
+	}
+	var totalEstimate int64
+	for _, tableName := range tableNames {
+		if !opts.schemaOnly(tableName) {
+			totalEstimate += estimates[tableName].Rows
+		}
+	}
+	progress := opts.Progress
+	if progress == nil {
+		progress = noProgress{}
+	}
+	progress.Start(totalEstimate)
+	return progress, estimates, nil
+}
+
+// exportOptions 得到导出一个表的数据的参数，onRetry 在每次重试前调用
+func (d *Dumper) exportOptions(table string, progress Progress, onRetry func(error)) exporter.Options {
+	opts := d.opts
+	return exporter.Options{
+		Where:         opts.whereFor(table),
+		Format:        opts.Format,
+		Delimited:     opts.Delimited,
+		JSON:          opts.JSON,
+		InsertMode:    opts.InsertMode,
+		NoComments:    opts.format().NoComments,
+		NoLocks:       opts.NoLocks || opts.Compact,
+		NoDisableKeys: opts.Compact,
+		OnRow:         progress.AddRow,
+		Masks:         opts.Masking[table],
+		Retry:         opts.Retry,
+		OnRetry: func(err error) {
+			metrics.ObserveRetry(d.cfg.DBName, metrics.StageTableData)
+			onRetry(err)
+		},
+	}
+}
+
+// retryStage 按重试策略重试 fn，fn 只能在查询成功后才写出内容，onRetry 可以为 nil
+func (d *Dumper) retryStage(ctx context.Context, stage, table string, onRetry func(error), fn func() error) error {
+	attrs := []any{log.KeyStage, stage, log.KeyDatabase, d.cfg.DBName}
+	if table != "" {
+		attrs = append(attrs, log.KeyTable, table)
+	}
+	return d.opts.Retry.Do(ctx, fn, func(err error) {
+		metrics.ObserveRetry(d.cfg.DBName, stage)
+		if onRetry != nil {
+			onRetry(err)
+		}
+	}, attrs...)
+}
+
+// logRetriedTables 汇总需要重试才导出成功的表
+func logRetriedTables(database string, tables []TableResult) {
+	var retried []string
+	for _, table := range tables {
+		if table.Retries > 0 {
+			retried = append(retried, fmt.Sprintf("%s (%d)", table.Name, table.Retries))
+		}
+	}
+	if len(retried) > 0 {
+		log.Logger.Warn("tables needed retries after transient errors",
+			log.KeyDatabase, database, "tables", strings.Join(retried, ", "))
+	}
+}
+
+// whereFor returns the WHERE condition of a table, a per-table condition replaces the global one
+func (o *Options) whereFor(table string) string {
+	if where, ok := o.TableWhere[table]; ok {
+		return where
+	}
+	return o.Where
+}
+
+// schemaOnly reports whether only the structure of a table is exported
+func (o *Options) schemaOnly(table string) bool {
+	return o.NoData || selector.MatchAny(o.IgnoreTableData, table)
+}
+
+// sqlFormat reports whether the rows are written as SQL statements into a single dump
+func (o *Options) sqlFormat() bool {
+	return o.Format == "" || o.Format == FormatSQL
+}
+
+// mergesIntoExisting 在 REPLACE、INSERT IGNORE 和 upsert 模式下合并到已有的表，不能删除表
+func (o *Options) mergesIntoExisting() bool {
+	return o.InsertMode != "" && o.InsertMode != InsertModeInsert
+}
+
+// format 根据选项得到导出内容的写法
+func (o *Options) format() internal.Format {
+	merge := o.mergesIntoExisting()
+	return internal.Format{
+		NoComments:     o.NoComments || o.Compact,
+		NoSetVariables: o.Compact,
+		DropDatabase:   o.DropDatabase,
+		NoDropTable:    o.NoDropTable || o.Compact || merge,
+		IfNotExists:    merge,
+		DDL:            &o.DDL,
+	}
+}
+
+// ResolveTables returns the tables of allTables selected by opts in the order they are exported,
+// it fails when none of the selected tables exists. Selectors that match no table are logged as warnings
+func ResolveTables(allTables []string, database string, opts Options) ([]string, error) {
+	include, err := selector.ParseAll(opts.Tables)
+	if err != nil {
+		return nil, err
+	}
+	ignore, err := selector.ParseAll(opts.IgnoreTables)
+	if err != nil {
+		return nil, err
+	}
+	ignoreData, err := selector.ParseAll(opts.IgnoreTableData)
+	if err != nil {
+		return nil, err
+	}
+
+	tableNames := allTables
+	if len(include) > 0 {
+		// 按选择器的顺序导出，模式匹配到的表按 allTables 的顺序排列，重复匹配的表只导出一次
+		tableNames = nil
+		for _, s := range include {
+			matched := matchTables(allTables, s)
+			if len(matched) == 0 {
+				warnUnmatched(database, "tables", s)
+			}
+			for _, table := range matched {
+				if !slices.Contains(tableNames, table) {
+					tableNames = append(tableNames, table)
+				}
+			}
+		}
+	}
+	if len(tableNames) == 0 {
+		return nil, fmt.Errorf("no tables found in database:%s", database)
+	}
+
+	for _, s := range ignore {
+		if len(matchTables(allTables, s)) == 0 {
+			warnUnmatched(database, "ignore_tables", s)
+		}
+	}
+	for _, s := range ignoreData {
+		if len(matchTables(allTables, s)) == 0 {
+			warnUnmatched(database, "ignore_table_data", s)
+		}
+	}
+
+	resolved := make([]string, 0, len(tableNames))
+	for _, tableName := range tableNames {
+		// 跳过忽略表列表中的表
+		if slices.ContainsFunc(ignore, func(s selector.Selector) bool { return s.Match(tableName) }) {
+			continue
+		}
+		resolved = append(resolved, tableName)
+	}
+	return resolved, nil
+}
+
+// matchTables 返回 allTables 中与选择器匹配的表
+func matchTables(allTables []string, s selector.Selector) []string {
+	var matched []string
+	for _, table := range allTables {
+		if s.Match(table) {
+			matched = append(matched, table)
+		}
+	}
+	return matched
+}
+
+// warnUnmatched 记录没有匹配任何表的选择器，通常是表名拼写错误
+func warnUnmatched(database, option string, s selector.Selector) {
+	log.Logger.Warn("table selector matched no table", log.KeyDatabase, database, "option", option, "selector", s.String())
+}
+
+// nopCloser 为所有表共用的输出流，由 Dump 的调用方关闭
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error { return nil }
+
+// noProgress 在未设置 Options.Progress 时忽略进度事件
+type noProgress struct{}
+
+func (noProgress) Start(int64)              {}
+func (noProgress) StartTable(string, int64) {}
+func (noProgress) AddRow()                  {}
+func (noProgress) FinishTable()             {}
+func (noProgress) Stop()                    {}
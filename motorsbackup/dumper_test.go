@@ -0,0 +1,174 @@
+package motorsbackup
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"log/slog"
+	"motors-backup/internal"
+	"motors-backup/internal/log"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestResolveTables(t *testing.T) {
+	allTables := []string{"users", "orders", "logs", "sessions"}
+
+	tables, err := ResolveTables(allTables, "shop", Options{Tables: []string{"orders", "missing", "users"}, IgnoreTables: []string{"users"}})
+	if err != nil {
+		t.Fatalf("ResolveTables returned error: %v", err)
+	}
+	if strings.Join(tables, ",") != "orders" {
+		t.Errorf("tables = %v, want [orders]", tables)
+	}
+
+	if _, err := ResolveTables(allTables, "shop", Options{Tables: []string{"missing"}}); err == nil {
+		t.Error("expected error when no selected table exists")
+	}
+}
+
+func TestResolveTablesWarnsUnmatched(t *testing.T) {
+	var buf bytes.Buffer
+	old := log.Logger
+	SetLogger(slog.New(slog.NewTextHandler(&buf, nil)))
+	defer SetLogger(old)
+
+	allTables := []string{"users", "log_2024_01", "tmp_a"}
+	tables, err := ResolveTables(allTables, "shop", Options{
+		Tables:          []string{"log_2024_*", "re:^tmp_", "log_2023_*"},
+		IgnoreTables:    []string{"re:^tmp_"},
+		IgnoreTableData: []string{"sessions"},
+	})
+	if err != nil {
+		t.Fatalf("ResolveTables returned error: %v", err)
+	}
+	if strings.Join(tables, ",") != "log_2024_01" {
+		t.Errorf("tables = %v, want [log_2024_01]", tables)
+	}
+	for _, want := range []string{"option=tables selector=log_2023_*", "option=ignore_table_data selector=sessions"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("log does not contain %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Count(buf.String(), "matched no table") != 2 {
+		t.Errorf("expected two warnings:\n%s", buf.String())
+	}
+}
+
+func TestWhereFor(t *testing.T) {
+	opts := Options{Where: "id > 0", TableWhere: map[string]string{"orders": "total > 0"}}
+	if got := opts.whereFor("users"); got != "id > 0" {
+		t.Errorf("whereFor(users) = %q, want the global condition", got)
+	}
+	if got := opts.whereFor("orders"); got != "total > 0" {
+		t.Errorf("whereFor(orders) = %q, want the table condition", got)
+	}
+}
+
+func TestFormat(t *testing.T) {
+	for _, tc := range []struct {
+		mode string
+		drop bool
+	}{
+		{"", true},
+		{InsertModeInsert, true},
+		{InsertModeReplace, false},
+		{InsertModeIgnore, false},
+		{InsertModeUpsert, false},
+	} {
+		opts := Options{InsertMode: tc.mode}
+		got := opts.format()
+		if got.NoDropTable == tc.drop || got.IfNotExists == tc.drop {
+			t.Errorf("format() for insert mode %q = %+v, want DROP TABLE %v", tc.mode, got, tc.drop)
+		}
+	}
+
+	tests := []struct {
+		opts Options
+		want internal.Format
+	}{
+		{opts: Options{}, want: internal.Format{}},
+		{opts: Options{NoComments: true, NoDropTable: true}, want: internal.Format{NoComments: true, NoDropTable: true}},
+		{opts: Options{DropDatabase: true}, want: internal.Format{DropDatabase: true}},
+		{opts: Options{Compact: true}, want: internal.Format{NoComments: true, NoSetVariables: true, NoDropTable: true}},
+	}
+	for _, tc := range tests {
+		got := tc.opts.format()
+		if got.DDL != &tc.opts.DDL {
+			t.Errorf("format() for %+v does not use Options.DDL", tc.opts)
+		}
+		got.DDL = nil
+		if got != tc.want {
+			t.Errorf("format() for %+v = %+v, want %+v", tc.opts, got, tc.want)
+		}
+	}
+}
+
+func TestSchemaOnly(t *testing.T) {
+	opts := Options{IgnoreTableData: []string{"logs"}}
+	if !opts.schemaOnly("logs") || opts.schemaOnly("users") {
+		t.Error("schemaOnly() should only be true for ignored table data")
+	}
+	opts.NoData = true
+	if !opts.schemaOnly("users") {
+		t.Error("schemaOnly() should be true for every table with NoData")
+	}
+}
+
+func TestOptionsValidate(t *testing.T) {
+	valid := []Options{
+		{},
+		{Format: FormatCSV, Delimited: DelimitedOptions{Delimiter: ';', Null: `\N`}},
+		{Format: FormatTSV, IgnoreTableData: []string{"logs"}},
+		{Format: FormatJSONL, JSON: JSONOptions{TinyIntAsBool: true}},
+		{Routines: true, NoTriggers: true},
+	}
+	for _, opts := range valid {
+		if err := opts.validate(); err != nil {
+			t.Errorf("validate() for %+v returned error: %v", opts, err)
+		}
+	}
+	invalid := []Options{
+		{Format: "xml"},
+		{Format: FormatCSV, NoData: true},
+		{Format: FormatJSONL, NoData: true},
+		{Format: FormatCSV, Delimited: DelimitedOptions{Delimiter: '"'}},
+		{Format: FormatTSV, Delimited: DelimitedOptions{Null: "a\tb"}},
+		{DropDatabase: true, NoCreateDatabase: true},
+		{Format: FormatCSV, DDL: DDLTransformer{Definer: "root"}},
+		{Format: FormatJSONL, Routines: true},
+	}
+	for _, opts := range invalid {
+		if err := opts.validate(); err == nil {
+			t.Errorf("validate() for %+v returned no error", opts)
+		}
+	}
+}
+
+func TestFormatRequiresMatchingMethod(t *testing.T) {
+	d := NewWithDB(nil, "shop", Options{Format: FormatCSV})
+	if _, err := d.Dump(context.Background(), io.Discard); err == nil || !strings.Contains(err.Error(), "DumpTables") {
+		t.Errorf("Dump with format csv returned %v, want an error pointing to DumpTables", err)
+	}
+	d = NewWithDB(nil, "shop", Options{})
+	result, err := d.DumpTables(context.Background(), func(string) (io.WriteCloser, error) {
+		t.Fatal("DumpTables created a file for the SQL format")
+		return nil, nil
+	})
+	if err == nil || result == nil || !result.CompletedAt.IsZero() {
+		t.Errorf("DumpTables with format sql returned %+v, %v", result, err)
+	}
+}
+
+func TestResultDuration(t *testing.T) {
+	start := time.Date(2026, 10, 19, 3, 0, 0, 0, time.UTC)
+	result := &Result{StartedAt: start}
+	if result.Duration() != 0 {
+		t.Errorf("Duration() of a failed dump = %v, want 0", result.Duration())
+	}
+	result.CompletedAt = start.Add(90 * time.Second)
+	if result.Duration() != 90*time.Second {
+		t.Errorf("Duration() = %v, want 1m30s", result.Duration())
+	}
+}
@@ -0,0 +1,77 @@
+package motorsbackup_test
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"io"
+	"motors-backup/motorsbackup"
+	"os"
+)
+
+func ExampleDumper() {
+	cfg, err := motorsbackup.ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	dumper := motorsbackup.New(cfg, motorsbackup.Options{
+		Tables:          []string{"users", "orders"},
+		IgnoreTableData: []string{"logs"},
+		Retry:           motorsbackup.DefaultRetryPolicy(),
+	})
+	result, err := dumper.Dump(context.Background(), os.Stdout)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	for _, table := range result.Tables {
+		fmt.Fprintf(os.Stderr, "%s: %d rows, %d bytes in %s\n", table.Name, table.Rows, table.Bytes, table.Duration)
+	}
+}
+
+func ExampleDumper_DumpTables() {
+	cfg, err := motorsbackup.ConfigFromEnv()
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+
+	dumper := motorsbackup.New(cfg, motorsbackup.Options{
+		Tables:    []string{"users", "orders"},
+		Format:    motorsbackup.FormatCSV,
+		Delimited: motorsbackup.DelimitedOptions{Null: `\N`},
+	})
+	// 每个表写入单独的文件，导出后由 DumpTables 关闭
+	_, err = dumper.DumpTables(context.Background(), func(table string) (io.WriteCloser, error) {
+		return os.Create(table + ".csv")
+	})
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+	}
+}
+
+func ExampleNewWithDB() {
+	// 数据库连接池的默认数据库必须是要导出的数据库
+	db, err := sql.Open("mysql", "backup:secret@tcp(db.internal:3306)/shop")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer db.Close()
+
+	file, err := os.Create("shop.sql")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	defer file.Close()
+
+	result, err := motorsbackup.NewWithDB(db, "shop", motorsbackup.Options{NoCreateDatabase: true}).Dump(context.Background(), file)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "wrote %d bytes in %s\n", result.Bytes, result.Duration())
+}
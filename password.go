@@ -0,0 +1,36 @@
+package main
+
+import (
+	"fmt"
+	"motors-backup/internal/config"
+	"os"
+
+	"golang.org/x/term"
+)
+
+// promptPassword 在终端上提示输入密码，输入内容不会回显。没有从环境变量、密码文件或 option file
+// 得到密码且 stdin 是终端时自动提示，force（--ask-password）总是提示
+func promptPassword(cfg *config.Config, force bool) error {
+	fd := int(os.Stdin.Fd())
+	terminal := term.IsTerminal(fd)
+	if !needsPasswordPrompt(cfg, force, terminal) {
+		return nil
+	}
+	if !terminal {
+		return fmt.Errorf("--ask-password requires a terminal on stdin, use DB_PASSWORD_FILE or --defaults-file instead")
+	}
+
+	fmt.Fprintf(os.Stderr, "Enter password for %s@%s: ", cfg.DBUser, cfg.Address())
+	password, err := term.ReadPassword(fd)
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return fmt.Errorf("failed to read password: %w", err)
+	}
+	cfg.DBPassword = string(password)
+	return nil
+}
+
+// needsPasswordPrompt 判断是否需要提示输入密码，terminal 表示 stdin 是否为终端
+func needsPasswordPrompt(cfg *config.Config, force, terminal bool) bool {
+	return force || (terminal && cfg.DBPassword == "")
+}
@@ -0,0 +1,119 @@
+package main
+
+import (
+	"flag"
+	"motors-backup/internal/config"
+	"motors-backup/internal/ddl"
+	"os"
+)
+
+// flagEnv 列出默认值来自环境变量的参数，环境变量同样优先于 profile
+var flagEnv = map[string]string{
+	"sign-key":         "MOTORS_BACKUP_SIGN_KEY",
+	"metrics-textfile": "MOTORS_BACKUP_METRICS_TEXTFILE",
+}
+
+// explicitFlags returns the flags given on the command line or through their environment variable
+func explicitFlags(fs *flag.FlagSet) map[string]bool {
+	explicit := make(map[string]bool)
+	fs.Visit(func(f *flag.Flag) {
+		explicit[f.Name] = true
+	})
+	for name, env := range flagEnv {
+		if os.Getenv(env) != "" {
+			explicit[name] = true
+		}
+	}
+	return explicit
+}
+
+// loadProfile 读取 --config 指定的配置文件并选出 profile，未指定配置文件时返回 nil
+func loadProfile(path, name string) (*config.Profile, error) {
+	if path == "" {
+		return nil, nil
+	}
+	file, err := config.LoadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return file.Profile(name)
+}
+
+// applyProfile fills the options that were not given explicitly from the profile,
+// explicit holds the flag names given on the command line, "table" stands for the table argument
+func applyProfile(opts *dumpOptions, p *config.Profile, explicit map[string]bool) {
+	opts.profile = p
+
+	if !explicit["table"] && len(p.Tables) > 0 {
+		opts.tableNames = p.Tables
+	}
+	if !explicit["ignore-table"] && len(p.IgnoreTables) > 0 {
+		opts.ignoreTables = p.IgnoreTables
+	}
+	if !explicit["ignore-table-data"] && len(p.IgnoreTableData) > 0 {
+		opts.ignoreTableData = p.IgnoreTableData
+	}
+	if !explicit["create-database"] && p.CreateDatabase != nil {
+		opts.createDatabase = *p.CreateDatabase
+	}
+	// --where 对所有表生效，同时取代 profile 中的 where 和 table_where
+	if !explicit["where"] {
+		if p.Where != "" {
+			opts.whereCondition = p.Where
+		}
+		opts.tableWhere = p.TableWhere
+	}
+	opts.masking = p.Masking
+	applyDDLProfile(&opts.ddl, &p.DDL, explicit)
+
+	if !explicit["output-dir"] && p.Output.Dir != "" {
+		opts.outputDir = p.Output.Dir
+	}
+	if !explicit["sign-key"] && p.Output.SignKey != "" {
+		opts.signKey = p.Output.SignKey
+	}
+	if !explicit["progress"] && p.Output.Progress != "" {
+		opts.progress = p.Output.Progress
+	}
+	if !explicit["metrics-textfile"] && p.Output.MetricsTextfile != "" {
+		opts.metricsTextfile = p.Output.MetricsTextfile
+	}
+
+	if !explicit["retries"] && p.Retry.Retries != nil {
+		opts.retry.Retries = *p.Retry.Retries
+	}
+	if !explicit["retry-delay"] && p.Retry.Delay > 0 {
+		opts.retry.Delay = p.Retry.Delay
+	}
+	if !explicit["retry-max-delay"] && p.Retry.MaxDelay > 0 {
+		opts.retry.MaxDelay = p.Retry.MaxDelay
+	}
+}
+
+// applyDDLProfile 合并 profile 中的 ddl 设置，命令行中的 --ddl-* 参数优先
+func applyDDLProfile(t, p *ddl.Transformer, explicit map[string]bool) {
+	if !explicit["ddl-strip-auto-increment"] && p.StripAutoIncrement {
+		t.StripAutoIncrement = true
+	}
+	if !explicit["ddl-engine"] && p.Engine != "" {
+		t.Engine = p.Engine
+	}
+	if !explicit["ddl-strip-row-format"] && p.StripRowFormat {
+		t.StripRowFormat = true
+	}
+	if !explicit["ddl-strip-data-directory"] && p.StripDataDirectory {
+		t.StripDataDirectory = true
+	}
+	if !explicit["ddl-strip-tablespace"] && p.StripTablespace {
+		t.StripTablespace = true
+	}
+	if !explicit["ddl-charset"] && len(p.Charsets) > 0 {
+		t.Charsets = p.Charsets
+	}
+	if !explicit["ddl-collation"] && len(p.Collations) > 0 {
+		t.Collations = p.Collations
+	}
+	if !explicit["ddl-definer"] && p.Definer != "" {
+		t.Definer = p.Definer
+	}
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"motors-backup/internal/config"
+	dbConn "motors-backup/internal/db"
+	"motors-backup/internal/log"
+	"motors-backup/internal/restore"
+	"os"
+	"strings"
+	"time"
+)
+
+// untilLayouts 为 --until 接受的时间格式，未带时区时按本地时间解释
+var untilLayouts = []string{"2006-01-02 15:04:05", "2006-01-02T15:04:05", "2006-01-02 15:04"}
+
+// runRestore 恢复目标时间之前最近的全量备份，再重放归档的 binlog 直到目标
+func runRestore(args []string) error {
+	fs := flag.NewFlagSet("restore", flag.ExitOnError)
+	backups := fs.String("backups", "", "Directory holding the backups, or a single backup directory (required)")
+	binlogDir := fs.String("binlog-dir", "", "Directory written by binlog-archive (required)")
+	until := fs.String("until", "", "Restore the state at this time, e.g. '2026-10-01 12:34:00' (local time) or RFC 3339")
+	untilGTID := fs.String("until-gtid", "", "Restore up to and including this transaction, e.g. 3e11fa47-71ca-11e1-9e33-c80aa9429562:23 or 0-1-100")
+	database := fs.String("database", "", "Database to restore when the catalog holds backups of several databases")
+	dryRun := fs.Bool("dry-run", false, "Only choose the backup and check the archive, restore nothing")
+	configPath := fs.String("config", os.Getenv("MOTORS_BACKUP_CONFIG"), "YAML config file with named profiles")
+	profileName := fs.String("profile", os.Getenv("MOTORS_BACKUP_PROFILE"), "Profile of the config file to use")
+	fs.Usage = func() {
+		fmt.Println("Usage: motors-backup restore --backups=<dir> --binlog-dir=<dir> (--until=<time> | --until-gtid=<gtid>) [options]")
+		fmt.Println()
+		fmt.Println("Restores the latest complete backup taken with --source-data before the target, then replays")
+		fmt.Println("the archived binary log of its database up to the target. The connection settings name the")
+		fmt.Println("server to restore into, the user needs the privileges to run BINLOG statements.")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+	if fs.NArg() > 0 {
+		fs.Usage()
+		return fmt.Errorf("restore takes no arguments")
+	}
+
+	if *backups == "" || *binlogDir == "" {
+		return fmt.Errorf("--backups and --binlog-dir are required")
+	}
+	target, err := parseTarget(*until, *untilGTID)
+	if err != nil {
+		return err
+	}
+
+	catalog, err := restore.LoadCatalog(*backups)
+	if err != nil {
+		return err
+	}
+	backup, err := restore.Select(catalog, *database, target)
+	if err != nil {
+		return err
+	}
+	m := backup.Manifest
+	fmt.Printf("Backup:  %s (database %s, completed %s)\n", backup.Dir, m.Database, m.CompletedAt.Local().Format(time.DateTime))
+	fmt.Printf("Start:   %s:%d\n", m.Binlog.File, m.Binlog.Position)
+
+	ctx, stop := notifyInterrupt()
+	defer stop()
+
+	// 先读取一遍归档，确认没有缺失的文件并找到停止的位置，再修改服务器
+	plan, err := restore.Replay(ctx, *binlogDir, *m.Binlog, m.Database, target, nil)
+	if err != nil {
+		return err
+	}
+	if target.GTID != "" && !plan.ReachedTarget {
+		return fmt.Errorf("transaction %s was not found in the archive after %s:%d", target.GTID, m.Binlog.File, m.Binlog.Position)
+	}
+	if !plan.ReachedTarget {
+		log.Logger.Warn("the archive ends before the target, replaying all archived events",
+			"target", target.String(), "last_event", plan.End.Local().Format(time.DateTime))
+	}
+	printReplayResult(plan, "Stop:   ")
+	if *dryRun {
+		return nil
+	}
+
+	cfg, err := loadRestoreConfig(*configPath, *profileName)
+	if err != nil {
+		return err
+	}
+	db, err := dbConn.Connect(ctx, cfg)
+	if err != nil {
+		return err
+	}
+	defer dbConn.Close(db)
+	conn, err := db.Conn(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	// 导出文件中的 USE 需要数据库已存在
+	if _, err := conn.ExecContext(ctx, "CREATE DATABASE IF NOT EXISTS `"+strings.ReplaceAll(m.Database, "`", "``")+"`"); err != nil {
+		return err
+	}
+	if err := restore.LoadDump(ctx, conn, backup); err != nil {
+		return err
+	}
+	result, err := restore.Replay(ctx, *binlogDir, *m.Binlog, m.Database, target, conn)
+	if err != nil {
+		return err
+	}
+	log.Logger.Info("point-in-time restore completed", "database", m.Database, "transactions", result.Transactions,
+		"file", result.Stop.File, "position", result.Stop.Position, "gtid", result.LastGTID)
+	printReplayResult(result, "Restored:")
+	return nil
+}
+
+// parseTarget 解析 --until 和 --until-gtid，二者只能指定一个
+func parseTarget(until, untilGTID string) (restore.Target, error) {
+	switch {
+	case until != "" && untilGTID != "":
+		return restore.Target{}, fmt.Errorf("--until and --until-gtid can not be used together")
+	case untilGTID != "":
+		return restore.Target{GTID: untilGTID}, nil
+	case until == "":
+		return restore.Target{}, fmt.Errorf("--until or --until-gtid is required")
+	}
+
+	if t, err := time.Parse(time.RFC3339, until); err == nil {
+		return restore.Target{Time: t}, nil
+	}
+	for _, layout := range untilLayouts {
+		if t, err := time.ParseInLocation(layout, until, time.Local); err == nil {
+			return restore.Target{Time: t}, nil
+		}
+	}
+	return restore.Target{}, fmt.Errorf("invalid --until %q, expected a time like '2026-10-01 12:34:00'", until)
+}
+
+// loadRestoreConfig 读取恢复目标服务器的连接参数，数据库由备份决定
+func loadRestoreConfig(configPath, profileName string) (*config.Config, error) {
+	profile, err := loadProfile(configPath, profileName)
+	if err != nil {
+		return nil, err
+	}
+	cfg, err := config.Load(profile, nil)
+	if err != nil {
+		return nil, err
+	}
+	cfg.DBName = ""
+	return cfg, nil
+}
+
+func printReplayResult(result *restore.Result, label string) {
+	fmt.Printf("%s %s:%d, %d transaction(s)", label, result.Stop.File, result.Stop.Position, result.Transactions)
+	if result.Transactions > 0 {
+		fmt.Printf(", last at %s", result.LastTime.Local().Format(time.DateTime))
+	}
+	if result.LastGTID != "" {
+		fmt.Printf(", last GTID %s", result.LastGTID)
+	}
+	fmt.Println()
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseTarget(t *testing.T) {
+	local := time.Date(2026, 10, 1, 12, 34, 0, 0, time.Local)
+	tests := []struct {
+		until, gtid string
+		want        time.Time
+		err         bool
+	}{
+		{until: "2026-10-01 12:34:00", want: local},
+		{until: "2026-10-01 12:34", want: local},
+		{until: "2026-10-01T12:34:00Z", want: time.Date(2026, 10, 1, 12, 34, 0, 0, time.UTC)},
+		{gtid: "0-1-100"},
+		{until: "yesterday", err: true},
+		{until: "2026-10-01 12:34:00", gtid: "0-1-100", err: true},
+		{err: true},
+	}
+	for _, tt := range tests {
+		target, err := parseTarget(tt.until, tt.gtid)
+		if tt.err {
+			if err == nil {
+				t.Errorf("parseTarget(%q, %q) returned no error", tt.until, tt.gtid)
+			}
+			continue
+		}
+		if err != nil || !target.Time.Equal(tt.want) || target.GTID != tt.gtid {
+			t.Errorf("parseTarget(%q, %q) = %+v, %v", tt.until, tt.gtid, target, err)
+		}
+	}
+}
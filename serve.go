@@ -0,0 +1,141 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"motors-backup/internal/config"
+	"motors-backup/internal/daemon"
+	"motors-backup/internal/log"
+	"motors-backup/internal/metrics"
+	"motors-backup/internal/progress"
+	"motors-backup/internal/retry"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+	"time"
+)
+
+// runServe 以守护进程模式运行，按 jobs 文件中的 cron 表达式执行备份
+func runServe(args []string) error {
+	fs := flag.NewFlagSet("serve", flag.ExitOnError)
+	jobsFile := fs.String("jobs", getEnvOrDefault("MOTORS_BACKUP_JOBS", "jobs.yml"), "Path of the jobs file")
+	listen := fs.String("listen", "", "Address of the status endpoint, overrides the jobs file (default \":8080\")")
+	fs.Usage = func() {
+		fmt.Println("Usage: motors-backup serve [options]")
+		fmt.Println()
+		fmt.Println("Options:")
+		fs.PrintDefaults()
+		fmt.Println()
+		fmt.Println("Endpoints:")
+		fmt.Println("  GET /status    Last run status of every job as JSON")
+		fmt.Println("  GET /metrics   Prometheus metrics")
+		fmt.Println("  GET /healthz   Liveness probe")
+	}
+	if err := fs.Parse(args); err != nil {
+		return err
+	}
+
+	file, err := daemon.LoadFile(*jobsFile)
+	if err != nil {
+		return err
+	}
+
+	addr := *listen
+	if addr == "" {
+		addr = file.Listen
+	}
+	if addr == "" {
+		addr = ":8080"
+	}
+
+	base, err := config.Load(nil, nil)
+	if err != nil {
+		return err
+	}
+	// ctx 在第二次收到信号时取消正在运行的任务
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	d, err := daemon.New(file.Jobs, func(job daemon.Job) (string, error) {
+		return runJob(ctx, base, job)
+	})
+	if err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/", d.Handler())
+	mux.Handle("/metrics", metrics.Default.Handler())
+	server := &http.Server{Addr: addr, Handler: mux}
+	serverErr := make(chan error, 1)
+	go func() {
+		log.Logger.Info("status endpoint listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			serverErr <- err
+		}
+	}()
+
+	d.Start()
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	select {
+	case sig := <-signals:
+		log.Logger.Info("received signal, waiting for running jobs to finish, send it again to cancel them", "signal", sig.String())
+	case err = <-serverErr:
+		log.Logger.Error("status endpoint failed", log.Err(err)...)
+	}
+	go func() {
+		sig := <-signals
+		log.Logger.Warn("received second signal, canceling running jobs", "signal", sig.String())
+		cancel()
+	}()
+
+	d.Stop()
+	if shutdownErr := server.Close(); shutdownErr != nil {
+		log.Logger.Error("failed to close status endpoint", log.Err(shutdownErr)...)
+	}
+	return err
+}
+
+// runJob 执行单个任务，每次运行写入 destination 下独立的备份目录
+func runJob(ctx context.Context, base *config.Config, job daemon.Job) (string, error) {
+	cfg := *base
+	if job.Database != "" {
+		cfg.DBName = job.Database
+	}
+
+	dir := filepath.Join(job.Destination, fmt.Sprintf("%s-%s", job.Name, time.Now().Format("20060102-150405")))
+	if _, err := writeBackup(ctx, dir, &cfg, jobDumpOptions(job)); err != nil {
+		return "", err
+	}
+	return dir, nil
+}
+
+// jobDumpOptions 将任务定义转换为导出参数
+func jobDumpOptions(job daemon.Job) *dumpOptions {
+	createDatabase := true
+	if job.CreateDatabase != nil {
+		createDatabase = *job.CreateDatabase
+	}
+	// 任务中的 sign_key 优先，未设置时使用环境变量
+	signKey := job.SignKey
+	if signKey == "" {
+		signKey = os.Getenv("MOTORS_BACKUP_SIGN_KEY")
+	}
+	return &dumpOptions{
+		tableNames:      job.Tables,
+		createDatabase:  createDatabase,
+		ignoreTables:    job.IgnoreTables,
+		ignoreTableData: job.IgnoreTableData,
+		whereCondition:  job.Where,
+		progress:        progress.ModeNone,
+		jobName:         job.Name,
+		signKey:         signKey,
+		sourceData:      job.SourceData,
+		retry:           retry.DefaultPolicy(),
+	}
+}
@@ -0,0 +1,17 @@
+package main
+
+import (
+	"motors-backup/internal/daemon"
+	"testing"
+)
+
+func TestJobDumpOptionsSignKey(t *testing.T) {
+	t.Setenv("MOTORS_BACKUP_SIGN_KEY", "/secrets/env.key")
+
+	if got := jobDumpOptions(daemon.Job{Name: "shop", SignKey: "/secrets/job.key"}).signKey; got != "/secrets/job.key" {
+		t.Errorf("signKey = %s, the sign_key of the job should take precedence", got)
+	}
+	if got := jobDumpOptions(daemon.Job{Name: "shop"}).signKey; got != "/secrets/env.key" {
+		t.Errorf("signKey = %s, want the environment value for a job without sign_key", got)
+	}
+}
@@ -0,0 +1,50 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"motors-backup/internal/log"
+	"os"
+	"os/signal"
+	"syscall"
+)
+
+// interruptedError 记录中断导出的信号
+type interruptedError struct {
+	signal os.Signal
+}
+
+func (e *interruptedError) Error() string {
+	return fmt.Sprintf("interrupted by %s", e.signal)
+}
+
+// exitCode follows the shell convention of 128 plus the signal number: 130 for SIGINT, 143 for SIGTERM
+func (e *interruptedError) exitCode() int {
+	if sig, ok := e.signal.(syscall.Signal); ok {
+		return 128 + int(sig)
+	}
+	return exitFailed
+}
+
+// notifyInterrupt returns a context that is canceled with an *interruptedError on SIGINT or SIGTERM,
+// after the first signal the default handling is restored so that a second one terminates at once
+func notifyInterrupt() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancelCause(context.Background())
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+
+	go func() {
+		select {
+		case sig := <-signals:
+			signal.Stop(signals)
+			log.Logger.Warn("received signal, canceling the export", "signal", sig.String())
+			cancel(&interruptedError{signal: sig})
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		cancel(context.Canceled)
+	}
+}
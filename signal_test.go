@@ -0,0 +1,31 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestNotifyInterrupt(t *testing.T) {
+	ctx, stop := notifyInterrupt()
+	defer stop()
+
+	if err := syscall.Kill(syscall.Getpid(), syscall.SIGTERM); err != nil {
+		t.Fatalf("Failed to send SIGTERM: %v", err)
+	}
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatal("context was not canceled by SIGTERM")
+	}
+
+	var interrupted *interruptedError
+	if !errors.As(context.Cause(ctx), &interrupted) {
+		t.Fatalf("Cause() = %v, want an interruptedError", context.Cause(ctx))
+	}
+	if code := interrupted.exitCode(); code != 143 {
+		t.Errorf("exitCode() = %d, want 143", code)
+	}
+}